@@ -15,6 +15,7 @@ type Event struct {
 	Attempts    int                 `json:"attempts"`
 	LastAttempt *time.Time          `json:"last_attempt"`
 	Priority    int                 `json:"priority"`
+	CreatedAt   time.Time           `json:"created_at"`
 }
 
 // We are using the term `Event` as it represents an event in the
@@ -23,3 +24,58 @@ type Event struct {
 func (Event) TableName() string {
 	return "event_queue"
 }
+
+// EventRetry is when a failed event is next attempted.
+type EventRetry struct {
+	EventID       uuid.UUID `json:"event_id" gorm:"primaryKey"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
+
+func (EventRetry) TableName() string {
+	return "event_queue_retries"
+}
+
+// DeadLetterEvent is an event that has exhausted all of its attempts
+// and has been moved out of the event_queue.
+type DeadLetterEvent struct {
+	ID          uuid.UUID           `json:"id"`
+	Name        string              `json:"name"`
+	Properties  types.JSONStringMap `json:"properties"`
+	Error       string              `json:"error"`
+	Attempts    int                 `json:"attempts"`
+	LastAttempt *time.Time          `json:"last_attempt"`
+	Priority    int                 `json:"priority"`
+	CreatedAt   time.Time           `json:"created_at"`
+	DeadAt      time.Time           `json:"dead_at"`
+}
+
+func (DeadLetterEvent) TableName() string {
+	return "event_queue_dead_letter"
+}
+
+func NewDeadLetterEvent(e Event) DeadLetterEvent {
+	return DeadLetterEvent{
+		ID:          e.ID,
+		Name:        e.Name,
+		Properties:  e.Properties,
+		Error:       e.Error,
+		Attempts:    e.Attempts,
+		LastAttempt: e.LastAttempt,
+		Priority:    e.Priority,
+		CreatedAt:   e.CreatedAt,
+		DeadAt:      time.Now(),
+	}
+}
+
+// DeadLetterEventQuery selects events from the dead letter queue.
+// All the non-empty fields are combined with AND.
+type DeadLetterEventQuery struct {
+	IDs   []uuid.UUID `json:"ids,omitempty"`
+	Name  string      `json:"name,omitempty"`
+	Error string      `json:"error,omitempty"` // Matches events whose error contains the given text
+	Limit int         `json:"limit,omitempty"`
+}
+
+func (q DeadLetterEventQuery) IsEmpty() bool {
+	return len(q.IDs) == 0 && q.Name == "" && q.Error == ""
+}
@@ -80,6 +80,7 @@ type IncidentHistory struct {
 	Type         string     `json:"type,omitempty"`
 	Description  string     `json:"description,omitempty"`
 	HypothesisID *uuid.UUID `json:"hypothesis_id,omitempty"`
+	ResponderID  *uuid.UUID `json:"responder_id,omitempty"`
 	CreatedBy    *uuid.UUID `json:"created_by,omitempty"`
 	CreatedAt    *time.Time `json:"created_at,omitempty"`
 	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
@@ -90,6 +91,18 @@ func (ih IncidentHistory) TableName() string {
 
 }
 
+// IncidentFingerprint is the fingerprint of the group of resources of an incident opened by a rule.
+type IncidentFingerprint struct {
+	IncidentID     uuid.UUID  `json:"incident_id" gorm:"primaryKey"`
+	Fingerprint    string     `json:"fingerprint"`
+	IncidentRuleID *uuid.UUID `json:"incident_rule_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (IncidentFingerprint) TableName() string {
+	return "incident_fingerprints"
+}
+
 type Comment struct {
 	ID                uuid.UUID  `json:"id,omitempty" gorm:"default:generate_ulid()"`
 	IncidentID        uuid.UUID  `json:"incident_id,omitempty"`
@@ -133,9 +146,9 @@ func (hypothesis *Hypothesis) BeforeCreate(tx *gorm.DB) (err error) {
 // +kubebuilder:object:generate=true
 type Filter struct {
 	Status []string `json:"status,omitempty"`
-	// Only match incidents with the given status, use * to match all
+	// Only match components with a config analysis of the given severity, use * to match all
 	Severity []string `json:"severity,omitempty"`
-	// Only match incidents with the given category (cost,performance,security,availability), use * to match all
+	// Only match components with a config analysis of the given category (cost,performance,security,availability), use * to match all
 	Category []string `json:"category,omitempty"`
 	// How long the health check must be failing for, before opening an incident
 	Age *time.Duration `json:"age,omitempty"`
@@ -166,9 +179,52 @@ type AutoClose struct {
 
 // +kubebuilder:object:generate=true
 type HoursOfOperation struct {
-	Start  string `json:"start"`
-	End    string `json:"end"`
-	Negate bool   `json:"negate"`
+	// Start of the window in HH:MM
+	Start string `json:"start"`
+	// End of the window in HH:MM. A window that ends before it starts spans midnight.
+	End string `json:"end"`
+	// Timezone of the window, e.g. Europe/Berlin. Defaults to UTC.
+	Timezone string `json:"timezone,omitempty"`
+	// Negate excludes the window instead of including it
+	Negate bool `json:"negate"`
+}
+
+// Contains returns true if the time of day of t falls in the window.
+// Negate isn't taken into account.
+func (h HoursOfOperation) Contains(t time.Time) (bool, error) {
+	location := time.UTC
+	if h.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(h.Timezone); err != nil {
+			return false, fmt.Errorf("invalid timezone %q: %w", h.Timezone, err)
+		}
+	}
+
+	start, err := parseTimeOfDay(h.Start)
+	if err != nil {
+		return false, err
+	}
+	end, err := parseTimeOfDay(h.End)
+	if err != nil {
+		return false, err
+	}
+
+	t = t.In(location)
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if start <= end {
+		return now >= start && now < end, nil
+	}
+	return now >= start || now < end, nil
+}
+
+// parseTimeOfDay returns the time since midnight of a HH:MM or HH:MM:SS time.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
 }
 
 type IncidentRule struct {
@@ -192,14 +248,29 @@ func (incidentRule *IncidentRule) BeforeCreate(tx *gorm.DB) (err error) {
 
 // +kubebuilder:object:generate=true
 type IncidentTemplate struct {
-	Title          string         `json:"title,omitempty"`
-	Description    string         `json:"description,omitempty"`
-	Type           IncidentType   `json:"type,omitempty"`
+	// Title, Description, Type and Severity are templated with
+	// the matched resource, e.g. {{ .component.name }} or $(.component.name)
+	Title          string         `json:"title,omitempty" template:"true"`
+	Description    string         `json:"description,omitempty" template:"true"`
+	Type           IncidentType   `json:"type,omitempty" template:"true"`
 	Status         IncidentStatus `json:"status,omitempty"`
-	Severity       string         `json:"severity,omitempty"`
+	Severity       string         `json:"severity,omitempty" template:"true"`
 	CreatedBy      string         `json:"created_by,omitempty"`
 	CommanderID    string         `json:"commander_id,omitempty"`
 	CommunicatorID string         `json:"communicator_id,omitempty"`
+	// Expressions are CEL expressions that take precedence over the templated fields
+	Expressions IncidentTemplateExpressions `json:"expressions,omitempty"`
+}
+
+// IncidentTemplateExpressions are CEL expressions evaluated against the matched resource.
+// e.g. severity: component.labels.tier == "1" ? "Critical" : "Low"
+//
+// +kubebuilder:object:generate=true
+type IncidentTemplateExpressions struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Severity    string `json:"severity,omitempty"`
 }
 
 func (t IncidentTemplate) GenerateIncident() Incident {
@@ -225,21 +296,58 @@ func (t IncidentTemplate) GenerateIncident() Incident {
 	return incident
 }
 
+// +kubebuilder:object:generate=true
+type CheckSelector struct {
+	// Canary is the name or id of the check's canary
+	Canary string            `json:"canary,omitempty"`
+	Name   string            `json:"name,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// ConsecutiveFailures is how many times in a row the check must have failed. Defaults to 1.
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+type ConfigAnalysisSelector struct {
+	Analyzer Items `json:"analyzer,omitempty"`
+	// Severity of the analysis, e.g. critical or high
+	Severity Items `json:"severity,omitempty"`
+	// ConfigType is the type of the analyzed config item, e.g. AWS::EC2::Instance
+	ConfigType Items `json:"configType,omitempty"`
+}
+
 // +kubebuilder:object:generate=true
 type IncidentRuleSpec struct {
-	Name            string              `json:"name,omitempty"`
-	Components      []ComponentSelector `json:"components,omitempty"`
-	Template        IncidentTemplate    `json:"template,omitempty"`
-	Filter          Filter              `json:"filter,omitempty"`
-	AutoAssignOwner bool                `json:"autoAssignOwner,omitempty"`
-	// order of processing rules
+	Name       string              `json:"name,omitempty"`
+	Components []ComponentSelector `json:"components,omitempty"`
+	// Checks selects the failing canary checks
+	Checks []CheckSelector `json:"checks,omitempty"`
+	// ConfigAnalysis selects the open config analyses
+	ConfigAnalysis  []ConfigAnalysisSelector `json:"configAnalysis,omitempty"`
+	Template        IncidentTemplate         `json:"template,omitempty"`
+	Filter          Filter                   `json:"filter,omitempty"`
+	AutoAssignOwner bool                     `json:"autoAssignOwner,omitempty"`
+	// order of processing rules, lowest first
 	Priority int `json:"priority,omitempty"`
 	// stop processing other incident rules, when matched
+	// even if the rule's incident is already open
 	BreakOnMatch       bool               `json:"breakOnMatch,omitempty"`
 	HoursOfOperation   []HoursOfOperation `json:"hoursOfOperation,omitempty"`
 	AutoClose          *AutoClose         `json:"autoClose,omitempty"`
 	AutoResolve        *AutoClose         `json:"autoResolve,omitempty"`
 	IncidentResponders IncidentResponders `json:"responders,omitempty"`
+	// Group adds the matching resources of a group to its open incident
+	// instead of opening an incident per resource
+	Group *IncidentGrouping `json:"group,omitempty"`
+}
+
+// IncidentGrouping groups the resources matched by rules into one incident.
+// Resources are grouped across rules that group by the same attributes.
+type IncidentGrouping struct {
+	// By are the attributes the resources are grouped by: namespace, parent or labels.<key>
+	By []string `json:"by,omitempty"`
+	// Window is how long after the incident of a group was opened its resources are added to it.
+	// Defaults to as long as the incident is open.
+	Window *time.Duration `json:"window,omitempty"`
 }
 
 func (rule IncidentRuleSpec) String() string {
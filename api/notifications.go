@@ -3,8 +3,11 @@ package api
 import (
 	"context"
 	"database/sql/driver"
+	"time"
 
 	"github.com/flanksource/duty/types"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"gorm.io/gorm/schema"
@@ -50,3 +53,126 @@ func (t *NotificationConfig) HydrateConnection(ctx *Context) error {
 
 	return nil
 }
+
+// NotificationSettings throttles the sends of a notification.
+type NotificationSettings struct {
+	NotificationID uuid.UUID `json:"notification_id" gorm:"primaryKey"`
+	// RepeatInterval is how long after a send the notifications of the same group are suppressed, e.g. 1h
+	RepeatInterval string `json:"repeat_interval,omitempty"`
+	// GroupBy are the paths of the event's resource whose values group the notifications,
+	// e.g. check.id or canary.namespace. Defaults to the resource of the event.
+	GroupBy pq.StringArray `json:"group_by,omitempty" gorm:"type:text[]"`
+	// RateLimit is the maximum number of sends to a recipient per RateLimitPeriod
+	RateLimit int `json:"rate_limit,omitempty"`
+	// RateLimitPeriod defaults to 1h
+	RateLimitPeriod string `json:"rate_limit_period,omitempty"`
+	// DigestSchedule is the cron schedule the notifications are sent as a digest with,
+	// e.g. @daily or "CRON_TZ=Europe/London 0 9 * * 1-5". The notifications are sent right away without it.
+	DigestSchedule string `json:"digest_schedule,omitempty"`
+	// DigestTemplate is the message of the digest. It defaults to a list of the buffered messages.
+	DigestTemplate string    `json:"digest_template,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// IsDigest returns whether the notifications are sent as digests.
+func (t *NotificationSettings) IsDigest() bool {
+	return t != nil && t.DigestSchedule != ""
+}
+
+func (NotificationSettings) TableName() string {
+	return "notification_settings"
+}
+
+// NotificationSendState is the last send of a group of a notification to a recipient.
+type NotificationSendState struct {
+	NotificationID uuid.UUID  `json:"notification_id" gorm:"primaryKey"`
+	Recipient      string     `json:"recipient" gorm:"primaryKey"`
+	GroupKey       string     `json:"group_key" gorm:"primaryKey"`
+	LastSentAt     *time.Time `json:"last_sent_at,omitempty"`
+	// Suppressed is the number of notifications of the group suppressed since the last send
+	Suppressed      int        `json:"suppressed"`
+	SuppressedSince *time.Time `json:"suppressed_since,omitempty"`
+}
+
+func (NotificationSendState) TableName() string {
+	return "notification_send_state"
+}
+
+// NotificationRateLimit counts the sends of a notification to a recipient in the current rate limit period.
+type NotificationRateLimit struct {
+	NotificationID uuid.UUID `json:"notification_id" gorm:"primaryKey"`
+	Recipient      string    `json:"recipient" gorm:"primaryKey"`
+	WindowStart    time.Time `json:"window_start"`
+	Count          int       `json:"count"`
+}
+
+func (NotificationRateLimit) TableName() string {
+	return "notification_rate_limits"
+}
+
+// The statuses of a notification send
+const (
+	NotificationSendSent       = "sent"
+	NotificationSendFailed     = "failed"
+	NotificationSendSuppressed = "suppressed"
+	// NotificationSendDigested is a notification buffered for the next digest
+	NotificationSendDigested = "digested"
+)
+
+// NotificationSend is an attempt to send a notification.
+type NotificationSend struct {
+	ID             uuid.UUID `json:"id" gorm:"default:generate_ulid()"`
+	NotificationID uuid.UUID `json:"notification_id"`
+	// Recipient is person:<id>, team:<id>/<notification name> or the name of the custom service
+	Recipient  string     `json:"recipient"`
+	PersonID   *uuid.UUID `json:"person_id,omitempty"`
+	TeamID     *uuid.UUID `json:"team_id,omitempty"`
+	EventName  string     `json:"event_name"`
+	ResourceID string     `json:"resource_id,omitempty"`
+	IncidentID *uuid.UUID `json:"incident_id,omitempty"`
+	Message    string     `json:"message,omitempty"`
+	// Service is the shoutrrr service the message was sent with, e.g. slack or smtp
+	Service string `json:"service,omitempty"`
+	// Response of the service, i.e. its error if the send failed
+	Response  string    `json:"response,omitempty"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at" gorm:"<-:false"`
+}
+
+func (NotificationSend) TableName() string {
+	return "notification_sends"
+}
+
+// NotificationSendQuery selects notification sends.
+// All the non-empty fields are combined with AND.
+type NotificationSendQuery struct {
+	NotificationID string
+	IncidentID     string
+	Recipient      string
+	PersonID       string
+	TeamID         string
+	Status         string
+	Limit          int
+}
+
+// NotificationDigestEntry is a notification buffered until the next digest to its recipient.
+type NotificationDigestEntry struct {
+	ID             uuid.UUID `json:"id" gorm:"default:generate_ulid()"`
+	NotificationID uuid.UUID `json:"notification_id"`
+	// Recipient is person:<id>, team:<id>/<notification name> or the name of the custom service
+	Recipient string     `json:"recipient"`
+	PersonID  *uuid.UUID `json:"person_id,omitempty"`
+	TeamID    *uuid.UUID `json:"team_id,omitempty"`
+	// NotificationName is the name of the team's notification or of the custom service
+	NotificationName string     `json:"notification_name,omitempty"`
+	EventName        string     `json:"event_name"`
+	ResourceID       string     `json:"resource_id,omitempty"`
+	IncidentID       *uuid.UUID `json:"incident_id,omitempty"`
+	// Message is what the notification would have sent on its own
+	Message   string    `json:"message,omitempty"`
+	CreatedAt time.Time `json:"created_at" gorm:"<-:false"`
+}
+
+func (NotificationDigestEntry) TableName() string {
+	return "notification_digest_entries"
+}
@@ -1,12 +1,21 @@
 package api
 
 import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/flanksource/duty/types"
 	"github.com/google/uuid"
 )
 
 // +kubebuilder:object:generate=true
 type IncidentResponders struct {
+	// Team is the name or id of the team the responders are added for.
+	// Its responder clients file the tickets of the Jira, ServiceNow and GitHub responders.
+	Team        string          `json:"team,omitempty"`
 	Email       []Email         `json:"email,omitempty"`
 	Jira        []Jira          `json:"jira,omitempty"`
 	AWS         []CloudProvider `json:"aws,omitempty"`
@@ -19,6 +28,84 @@ type IncidentResponders struct {
 	GithubIssue []GithubIssue   `json:"github,omitempty"`
 }
 
+// RuleResponder is a responder defined by an incident rule.
+type RuleResponder struct {
+	// Type is the type of the responders row
+	Type string
+	// Properties are the properties of the responder, including its responderType
+	Properties map[string]string
+}
+
+// All returns the responders with the properties expected by the responder clients.
+func (r IncidentResponders) All() []RuleResponder {
+	var responders []RuleResponder
+	add := func(responderType, responderTypeProperty string, spec any) {
+		properties := structToProperties(spec)
+		properties["responderType"] = responderTypeProperty
+		responders = append(responders, RuleResponder{Type: responderType, Properties: properties})
+	}
+
+	for _, v := range r.Email {
+		add("Email", "email", v)
+	}
+	for _, v := range r.Jira {
+		add("Jira", "jira", v)
+	}
+	for _, v := range r.AWS {
+		add("AWS", "aws", v)
+	}
+	for _, v := range r.AMS {
+		add("AMS", "ams", v)
+	}
+	for _, v := range r.GCP {
+		add("GCP", "gcp", v)
+	}
+	for _, v := range r.ServiceNow {
+		add("ServiceNow", "servicenow", v)
+	}
+	for _, v := range r.Slack {
+		add("Slack", "slack", v)
+	}
+	for _, v := range r.Teams {
+		add("Teams", "teams", v)
+	}
+	for _, v := range r.TeamsUser {
+		add("TeamsUser", "teamsUser", v)
+	}
+	for _, v := range r.GithubIssue {
+		add("GithubIssue", "github", v)
+	}
+	return responders
+}
+
+// structToProperties flattens the json fields of v into string properties.
+// Lists are joined with commas and objects are kept as json.
+func structToProperties(v any) map[string]string {
+	properties := make(map[string]string)
+
+	b, _ := json.Marshal(v)
+	var fields map[string]any
+	_ = json.Unmarshal(b, &fields)
+	for k, field := range fields {
+		switch value := field.(type) {
+		case string:
+			properties[k] = value
+		case []any:
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				items = append(items, fmt.Sprint(item))
+			}
+			properties[k] = strings.Join(items, ",")
+		case map[string]any:
+			b, _ := json.Marshal(value)
+			properties[k] = string(b)
+		default:
+			properties[k] = fmt.Sprint(value)
+		}
+	}
+	return properties
+}
+
 type Responder struct {
 	ID         uuid.UUID           `json:"id,omitempty"`
 	Properties types.JSONStringMap `json:"properties" gorm:"type:jsonstringmap;<-:false"`
@@ -29,6 +116,63 @@ type Responder struct {
 	Team       Team                `json:"team,omitempty"`
 }
 
+// ClientName returns the name of the team's responder client the responder is filed with.
+// Empty until the responder is filed.
+func (r Responder) ClientName() string {
+	return r.Properties[ResponderClientProperty]
+}
+
+// ResponderClientProperty is the property of a responder that holds the name of its client.
+const ResponderClientProperty = "responderClient"
+
+// IdempotencyKey identifies the external issue of the responder.
+// Responders attach it to the issue so that it can be looked up on retries.
+func (r Responder) IdempotencyKey() string {
+	return "mission-control-" + r.ID.String()
+}
+
+// ResponderReservation is written before the external issue of a responder is created.
+type ResponderReservation struct {
+	ResponderID    uuid.UUID `json:"responder_id" gorm:"primaryKey"`
+	IdempotencyKey string    `json:"idempotency_key"`
+	ExternalID     string    `json:"external_id,omitempty" gorm:"default:null"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (ResponderReservation) TableName() string {
+	return "responder_reservations"
+}
+
+// ResponderSyncState is the last known state of the responder's external issue.
+type ResponderSyncState struct {
+	ResponderID    uuid.UUID `json:"responder_id" gorm:"primaryKey"`
+	ExternalStatus string    `json:"external_status,omitempty"`
+	// CommentsSyncedAt is the start of the last comment sync.
+	CommentsSyncedAt *time.Time `json:"comments_synced_at,omitempty"`
+	// CommentsFullSyncedAt is the start of the last comment sync that fetched all the comments.
+	CommentsFullSyncedAt *time.Time `json:"comments_full_synced_at,omitempty"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+}
+
+func (ResponderSyncState) TableName() string {
+	return "responder_sync_state"
+}
+
+// CommentResponderDeletion is an external comment of a deleted comment
+// that's yet to be deleted on the responder.
+type CommentResponderDeletion struct {
+	ID          uuid.UUID `json:"id" gorm:"default:generate_ulid()"`
+	CommentID   uuid.UUID `json:"comment_id"`
+	ResponderID uuid.UUID `json:"responder_id"`
+	ExternalID  string    `json:"external_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (CommentResponderDeletion) TableName() string {
+	return "comment_responder_deletions"
+}
+
 type NotificationSpec struct {
 	Icon  string `json:"icon,omitempty"`
 	Emoji string `json:"emoji,omitempty"`
@@ -48,14 +192,131 @@ type Slack struct {
 	Channel          string `json:"channel"`
 }
 
+// ResponderClient is a client of an external issue tracker.
+// Exactly one of the clients must be set.
+type ResponderClient struct {
+	// Name identifies the client in the team. Responders record the name of the client they were filed with.
+	Name       string            `json:"name"`
+	Jira       *JiraClient       `json:"jira,omitempty"`
+	MSPlanner  *MSPlannerClient  `json:"ms_planner,omitempty"`
+	ServiceNow *ServiceNowClient `json:"servicenow,omitempty"`
+	Github     *GithubClient     `json:"github,omitempty"`
+}
+
+// Type returns the responderType of the issues filed with the client.
+func (r ResponderClient) Type() string {
+	switch {
+	case r.Jira != nil:
+		return "jira"
+	case r.MSPlanner != nil:
+		return "ms_planner"
+	case r.ServiceNow != nil:
+		return "servicenow"
+	case r.Github != nil:
+		return "github"
+	}
+	return ""
+}
+
+// StatusMapping returns the status mapping of the client.
+func (r ResponderClient) StatusMapping() StatusMapping {
+	switch {
+	case r.Jira != nil:
+		return r.Jira.StatusMapping
+	case r.MSPlanner != nil:
+		return r.MSPlanner.StatusMapping
+	case r.ServiceNow != nil:
+		return r.ServiceNow.StatusMapping
+	case r.Github != nil:
+		return r.Github.StatusMapping
+	}
+	return nil
+}
+
+// WebhookSecret returns the secret that verifies the webhooks pushed by the client.
+// Only Jira and MS Planner clients push webhooks.
+func (r ResponderClient) WebhookSecret() (types.EnvVar, bool) {
+	switch {
+	case r.Jira != nil:
+		return r.Jira.WebhookSecret, true
+	case r.MSPlanner != nil:
+		return r.MSPlanner.WebhookSecret, true
+	}
+	return types.EnvVar{}, false
+}
+
 type ResponderClients struct {
-	Jira      *JiraClient      `json:"jira,omitempty"`
-	AWS       *AWSClient       `json:"aws,omitempty"`
-	MSPlanner *MSPlannerClient `json:"ms_planner,omitempty"`
+	Jira       *JiraClient       `json:"jira,omitempty"`
+	AWS        *AWSClient        `json:"aws,omitempty"`
+	MSPlanner  *MSPlannerClient  `json:"ms_planner,omitempty"`
+	ServiceNow *ServiceNowClient `json:"servicenow,omitempty"`
+	Github     *GithubClient     `json:"github,omitempty"`
+
+	// Clients are additional named clients.
+	// Used when a team files into several instances of the same issue tracker.
+	Clients []ResponderClient `json:"clients,omitempty"`
+}
+
+// All returns all the clients of the team.
+// The clients set directly on ResponderClients are named after their type.
+func (r ResponderClients) All() []ResponderClient {
+	var clients []ResponderClient
+	for _, client := range []ResponderClient{
+		{Jira: r.Jira},
+		{MSPlanner: r.MSPlanner},
+		{ServiceNow: r.ServiceNow},
+		{Github: r.Github},
+	} {
+		if client.Type() != "" {
+			client.Name = client.Type()
+			clients = append(clients, client)
+		}
+	}
+
+	for _, client := range r.Clients {
+		if client.Type() != "" {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// Validate checks that the clients can be told apart by their names.
+// The clients set directly on ResponderClients are named after their type,
+// so the named clients can't use their names either.
+func (r ResponderClients) Validate() error {
+	names := make(map[string]struct{})
+	for _, client := range r.All() {
+		if _, ok := names[client.Name]; ok {
+			return fmt.Errorf("duplicate responder client name %q", client.Name)
+		}
+		names[client.Name] = struct{}{}
+	}
+	return nil
+}
+
+// Get returns the client with the given name.
+func (r ResponderClients) Get(name string) (ResponderClient, bool) {
+	for _, client := range r.All() {
+		if client.Name == name {
+			return client, true
+		}
+	}
+	return ResponderClient{}, false
+}
+
+// ForType returns the first client that files issues of the given responderType.
+func (r ResponderClients) ForType(responderType string) (ResponderClient, bool) {
+	for _, client := range r.All() {
+		if client.Type() == responderType {
+			return client, true
+		}
+	}
+	return ResponderClient{}, false
 }
 
 func (r ResponderClients) IsEmpty() bool {
-	return r.Jira == nil && r.AWS == nil && r.MSPlanner == nil
+	return r.AWS == nil && len(r.All()) == 0
 }
 
 type ServiceNow struct {
@@ -98,8 +359,43 @@ type GenericTicketing struct {
 }
 
 type ResponderClientBase struct {
-	Defaults map[string]string `json:"defaults"`
-	Values   map[string]string `json:"values"`
+	Defaults      map[string]string `json:"defaults"`
+	Values        map[string]string `json:"values"`
+	StatusMapping StatusMapping     `json:"status_mapping,omitempty"`
+}
+
+// StatusMapping maps the incident statuses to the states of the responder's issues.
+//
+// When the incident moves to a status, the issue is moved to the first of its states.
+// When the issue is moved to any of the states, the responder is marked with the status.
+type StatusMapping map[IncidentStatus][]string
+
+// IssueState returns the state the issue is moved to for the incident status.
+func (m StatusMapping) IssueState(status IncidentStatus) (string, bool) {
+	if states := m[status]; len(states) > 0 {
+		return states[0], true
+	}
+	return "", false
+}
+
+// IncidentStatus returns the incident status the issue state maps to.
+// A state mapped to several statuses maps to the first of them by name,
+// so that the status doesn't depend on the order the map is iterated in.
+func (m StatusMapping) IncidentStatus(state string) (IncidentStatus, bool) {
+	statuses := make([]IncidentStatus, 0, len(m))
+	for status := range m {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i] < statuses[j] })
+
+	for _, status := range statuses {
+		for _, s := range m[status] {
+			if strings.EqualFold(s, state) {
+				return status, true
+			}
+		}
+	}
+	return "", false
 }
 
 type JiraClient struct {
@@ -107,6 +403,9 @@ type JiraClient struct {
 	Url                 string       `json:"url,omitempty"`
 	Username            types.EnvVar `yaml:"username" json:"username"`
 	Password            types.EnvVar `yaml:"password" json:"password"`
+	// WebhookSecret verifies the webhooks pushed by Jira.
+	// It's either the secret the webhook is signed with or the value of its secret query param.
+	WebhookSecret types.EnvVar `yaml:"webhook_secret" json:"webhook_secret,omitempty"`
 }
 
 type MSPlannerClient struct {
@@ -116,6 +415,25 @@ type MSPlannerClient struct {
 	GroupID             string       `json:"group_id"`
 	Username            types.EnvVar `yaml:"username" json:"username"`
 	Password            types.EnvVar `yaml:"password" json:"password"`
+	// WebhookSecret is the clientState of the Microsoft Graph subscription
+	// that pushes the changes of the group's conversations.
+	WebhookSecret types.EnvVar `yaml:"webhook_secret" json:"webhook_secret,omitempty"`
+}
+
+type ServiceNowClient struct {
+	ResponderClientBase `json:",inline"`
+	Url                 string       `json:"url,omitempty"`
+	Username            types.EnvVar `yaml:"username" json:"username"`
+	Password            types.EnvVar `yaml:"password" json:"password"`
+}
+
+type GithubClient struct {
+	ResponderClientBase `json:",inline"`
+	// Url of the GitHub API. Defaults to https://api.github.com
+	Url   string       `json:"url,omitempty"`
+	Token types.EnvVar `yaml:"token" json:"token"`
+	// Owner limits the repositories exposed in the config to the given user or organization
+	Owner string `json:"owner,omitempty"`
 }
 
 type Jira struct {
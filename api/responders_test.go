@@ -0,0 +1,160 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatusMapping(t *testing.T) {
+	mapping := StatusMapping{
+		IncidentStatusResolved: {"Done", "Won't Do"},
+		IncidentStatusOpen:     {"To Do"},
+		IncidentStatusClosed:   {},
+	}
+
+	for status, want := range map[IncidentStatus]string{
+		IncidentStatusResolved:  "Done",
+		IncidentStatusOpen:      "To Do",
+		IncidentStatusClosed:    "",
+		IncidentStatusCancelled: "",
+	} {
+		state, ok := mapping.IssueState(status)
+		if state != want || ok != (want != "") {
+			t.Errorf("IssueState(%s) = %s, %v; want %s", status, state, ok, want)
+		}
+	}
+
+	for state, want := range map[string]IncidentStatus{
+		"won't do": IncidentStatusResolved,
+		"Done":     IncidentStatusResolved,
+		"TO DO":    IncidentStatusOpen,
+		"Backlog":  "",
+	} {
+		status, ok := mapping.IncidentStatus(state)
+		if status != want || ok != (want != "") {
+			t.Errorf("IncidentStatus(%s) = %s, %v; want %s", state, status, ok, want)
+		}
+	}
+}
+
+func TestStatusMappingAmbiguousState(t *testing.T) {
+	mapping := StatusMapping{
+		IncidentStatusResolved: {"Done"},
+		IncidentStatusClosed:   {"Done"},
+		IncidentStatusOpen:     {"To Do"},
+	}
+
+	// The state maps to the same status however the map is iterated
+	for i := 0; i < 20; i++ {
+		if status, _ := mapping.IncidentStatus("Done"); status != IncidentStatusClosed {
+			t.Fatalf("IncidentStatus(Done) = %s, want %s", status, IncidentStatusClosed)
+		}
+	}
+}
+
+func TestResponderClientsAll(t *testing.T) {
+	clients := ResponderClients{
+		Jira:      &JiraClient{Url: "https://jira.example.com"},
+		MSPlanner: &MSPlannerClient{GroupID: "platform"},
+		Clients: []ResponderClient{
+			{Name: "jira-ops", Jira: &JiraClient{Url: "https://ops.example.com"}},
+			{Name: "empty"},
+		},
+	}
+
+	var names []string
+	for _, client := range clients.All() {
+		names = append(names, client.Name)
+	}
+	if strings.Join(names, ",") != "jira,ms_planner,jira-ops" {
+		t.Errorf("All() = %v", names)
+	}
+
+	if client, ok := clients.Get("jira-ops"); !ok || client.Jira.Url != "https://ops.example.com" {
+		t.Errorf("Get(jira-ops) = %v, %v", client, ok)
+	}
+	if client, ok := clients.ForType("jira"); !ok || client.Name != "jira" {
+		t.Errorf("ForType(jira) = %v, %v", client, ok)
+	}
+	if _, ok := clients.ForType("github"); ok {
+		t.Errorf("ForType(github) should not find a client")
+	}
+	if (ResponderClients{Clients: []ResponderClient{{Name: "empty"}}}).IsEmpty() != true {
+		t.Errorf("IsEmpty() should ignore clients without a configured issue tracker")
+	}
+}
+
+func TestIncidentRespondersAll(t *testing.T) {
+	responders := IncidentResponders{
+		Team:        "platform",
+		Email:       []Email{{To: "oncall@example.com", Subject: "Incident"}},
+		Jira:        []Jira{{Project: "OPS", IssueType: "Bug"}},
+		GithubIssue: []GithubIssue{{Repository: "flanksource/demo", Labels: []string{"incident", "p1"}}},
+	}
+
+	all := responders.All()
+	if len(all) != 3 {
+		t.Fatalf("All() returned %d responders, want 3", len(all))
+	}
+
+	tests := []struct {
+		responderType string
+		properties    map[string]string
+	}{
+		{"Email", map[string]string{"responderType": "email", "to": "oncall@example.com", "subject": "Incident"}},
+		{"Jira", map[string]string{"responderType": "jira", "project": "OPS", "issueType": "Bug", "summary": ""}},
+		{"GithubIssue", map[string]string{"responderType": "github", "repository": "flanksource/demo", "labels": "incident,p1"}},
+	}
+	for i, tt := range tests {
+		if all[i].Type != tt.responderType {
+			t.Errorf("All()[%d].Type = %s, want %s", i, all[i].Type, tt.responderType)
+		}
+		for k, v := range tt.properties {
+			if got := all[i].Properties[k]; got != v {
+				t.Errorf("All()[%d].Properties[%s] = %q, want %q", i, k, got, v)
+			}
+		}
+	}
+}
+
+func TestResponderClientsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		clients ResponderClients
+		wantErr string
+	}{
+		{
+			name: "distinct names",
+			clients: ResponderClients{
+				Jira:    &JiraClient{},
+				Clients: []ResponderClient{{Name: "jira-ops", Jira: &JiraClient{}}},
+			},
+		},
+		{
+			name: "named client shadowing a top-level client",
+			clients: ResponderClients{
+				Jira:    &JiraClient{},
+				Clients: []ResponderClient{{Name: "jira", Jira: &JiraClient{}}},
+			},
+			wantErr: `duplicate responder client name "jira"`,
+		},
+		{
+			name: "duplicate named clients",
+			clients: ResponderClients{
+				Clients: []ResponderClient{{Name: "ops", Jira: &JiraClient{}}, {Name: "ops", Github: &GithubClient{}}},
+			},
+			wantErr: `duplicate responder client name "ops"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.clients.Validate()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Validate() error = %v", err)
+			} else if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"time"
+
+	"github.com/flanksource/duty/types"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Silence is a maintenance window that suppresses the incidents of the rules
+// and the notifications of the resources it targets.
+type Silence struct {
+	ID          uuid.UUID `json:"id" gorm:"default:generate_ulid()"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	// Components, Checks and Teams are the names or ids of the targets
+	Components pq.StringArray `json:"components,omitempty" gorm:"type:text[]"`
+	Checks     pq.StringArray `json:"checks,omitempty" gorm:"type:text[]"`
+	Teams      pq.StringArray `json:"teams,omitempty" gorm:"type:text[]"`
+	// Labels targets the resources with all of the labels
+	Labels types.JSONStringMap `json:"labels,omitempty"`
+	// From and Until bound the window. A window without a schedule is active between them.
+	From  *time.Time `json:"from,omitempty"`
+	Until *time.Time `json:"until,omitempty"`
+	// Schedule is the cron schedule of when a recurring window starts, e.g. "0 22 * * SAT"
+	Schedule string `json:"schedule,omitempty"`
+	// Duration of a recurring window, e.g. "4h"
+	Duration string `json:"duration,omitempty"`
+	// Timezone of the schedule, e.g. Europe/Berlin. Defaults to UTC.
+	Timezone  string     `json:"timezone,omitempty"`
+	CreatedBy *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+func (Silence) TableName() string {
+	return "silences"
+}
+
+// SilenceSuppression is the audit record of the actions a silence suppressed,
+// e.g. the incidents a rule didn't open for a component.
+type SilenceSuppression struct {
+	SilenceID uuid.UUID `json:"silence_id" gorm:"primaryKey"`
+	// Action is what was suppressed: incident_rule or notification
+	Action string `json:"action" gorm:"primaryKey"`
+	// SourceID is the rule or the notification whose action was suppressed
+	SourceID string `json:"source_id" gorm:"primaryKey"`
+	// ResourceID is the component, check, config analysis or incident the action was for
+	ResourceID string `json:"resource_id" gorm:"primaryKey"`
+	// Recipient is the person, team or custom notification that wasn't notified
+	Recipient   string    `json:"recipient" gorm:"primaryKey"`
+	Description string    `json:"description,omitempty"`
+	Count       int       `json:"count"`
+	FirstAt     time.Time `json:"first_at"`
+	LastAt      time.Time `json:"last_at"`
+}
+
+func (SilenceSuppression) TableName() string {
+	return "silence_suppressions"
+}
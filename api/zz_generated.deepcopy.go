@@ -24,6 +24,28 @@ func (in *AutoClose) DeepCopy() *AutoClose {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CheckSelector) DeepCopyInto(out *CheckSelector) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CheckSelector.
+func (in *CheckSelector) DeepCopy() *CheckSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(CheckSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ComponentSelector) DeepCopyInto(out *ComponentSelector) {
 	*out = *in
@@ -51,6 +73,36 @@ func (in *ComponentSelector) DeepCopy() *ComponentSelector {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigAnalysisSelector) DeepCopyInto(out *ConfigAnalysisSelector) {
+	*out = *in
+	if in.Analyzer != nil {
+		in, out := &in.Analyzer, &out.Analyzer
+		*out = make(Items, len(*in))
+		copy(*out, *in)
+	}
+	if in.Severity != nil {
+		in, out := &in.Severity, &out.Severity
+		*out = make(Items, len(*in))
+		copy(*out, *in)
+	}
+	if in.ConfigType != nil {
+		in, out := &in.ConfigType, &out.ConfigType
+		*out = make(Items, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigAnalysisSelector.
+func (in *ConfigAnalysisSelector) DeepCopy() *ConfigAnalysisSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigAnalysisSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Filter) DeepCopyInto(out *Filter) {
 	*out = *in
@@ -121,6 +173,31 @@ func (in *HoursOfOperation) DeepCopy() *HoursOfOperation {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IncidentGrouping) DeepCopyInto(out *IncidentGrouping) {
+	*out = *in
+	if in.By != nil {
+		in, out := &in.By, &out.By
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Window != nil {
+		in, out := &in.Window, &out.Window
+		*out = new(timex.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IncidentGrouping.
+func (in *IncidentGrouping) DeepCopy() *IncidentGrouping {
+	if in == nil {
+		return nil
+	}
+	out := new(IncidentGrouping)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IncidentResponders) DeepCopyInto(out *IncidentResponders) {
 	*out = *in
@@ -198,6 +275,20 @@ func (in *IncidentRuleSpec) DeepCopyInto(out *IncidentRuleSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Checks != nil {
+		in, out := &in.Checks, &out.Checks
+		*out = make([]CheckSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ConfigAnalysis != nil {
+		in, out := &in.ConfigAnalysis, &out.ConfigAnalysis
+		*out = make([]ConfigAnalysisSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	out.Template = in.Template
 	in.Filter.DeepCopyInto(&out.Filter)
 	if in.HoursOfOperation != nil {
@@ -216,6 +307,11 @@ func (in *IncidentRuleSpec) DeepCopyInto(out *IncidentRuleSpec) {
 		**out = **in
 	}
 	in.IncidentResponders.DeepCopyInto(&out.IncidentResponders)
+	if in.Group != nil {
+		in, out := &in.Group, &out.Group
+		*out = new(IncidentGrouping)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IncidentRuleSpec.
@@ -231,6 +327,7 @@ func (in *IncidentRuleSpec) DeepCopy() *IncidentRuleSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IncidentTemplate) DeepCopyInto(out *IncidentTemplate) {
 	*out = *in
+	out.Expressions = in.Expressions
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IncidentTemplate.
@@ -242,3 +339,18 @@ func (in *IncidentTemplate) DeepCopy() *IncidentTemplate {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IncidentTemplateExpressions) DeepCopyInto(out *IncidentTemplateExpressions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IncidentTemplateExpressions.
+func (in *IncidentTemplateExpressions) DeepCopy() *IncidentTemplateExpressions {
+	if in == nil {
+		return nil
+	}
+	out := new(IncidentTemplateExpressions)
+	in.DeepCopyInto(out)
+	return out
+}
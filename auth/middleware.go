@@ -45,7 +45,7 @@ func (k *KratosHandler) KratosMiddleware() (*kratosMiddleware, error) {
 	}, nil
 }
 
-var skipAuthPaths = []string{"/health", "/metrics"}
+var skipAuthPaths = []string{"/health", "/metrics", "/responder/webhook/:team_id"}
 
 func canSkipAuth(c echo.Context) bool {
 	return collections.Contains(skipAuthPaths, c.Path())
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/flanksource/duty/models"
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+	"github.com/google/uuid"
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Test auto closing the incidents of checks and config analyses", ginkgo.Ordered, func() {
+	var (
+		john             *models.Person
+		incidentRule     *api.IncidentRule
+		check            *models.Check
+		analysis         *models.ConfigAnalysis
+		checkIncident    *models.Incident
+		analysisIncident *models.Incident
+	)
+
+	// healthyIncidents returns the ids of the incidents of the rule that have recovered for an hour
+	healthyIncidents := func() []uuid.UUID {
+		incidents, err := db.GetHealthyRuleIncidents(context.Background(), incidentRule.ID,
+			[]api.IncidentStatus{api.IncidentStatusOpen}, time.Now().Add(-time.Hour))
+		Expect(err).To(BeNil())
+
+		ids := make([]uuid.UUID, 0, len(incidents))
+		for _, incident := range incidents {
+			ids = append(ids, incident.ID)
+		}
+		return ids
+	}
+
+	addCheckStatus := func(passed bool, ago time.Duration) {
+		err := db.Gorm.Exec("INSERT INTO check_statuses(check_id, status, time, created_at) VALUES (?, ?, ?, NOW())",
+			check.ID, passed, time.Now().Add(-ago)).Error
+		Expect(err).To(BeNil())
+	}
+
+	ginkgo.It("should create the rule's incidents", func() {
+		john = &models.Person{ID: uuid.New(), Name: "John Auto Close"}
+		Expect(db.Gorm.Create(john).Error).To(BeNil())
+
+		incidentRule = &api.IncidentRule{
+			ID:   uuid.New(),
+			Name: "Auto close rule",
+			// Matches no components so that the rule's incidents are only the ones created here
+			Spec: &api.IncidentRuleSpec{
+				Name:       "Auto close rule",
+				Components: []api.ComponentSelector{{Namespace: "autoCloseTests"}},
+			},
+			CreatedAt: time.Now(),
+		}
+		Expect(db.Gorm.Create(incidentRule).Error).To(BeNil())
+
+		canary := &models.Canary{ID: uuid.New(), Name: "auto-close", Namespace: "default", Spec: []byte("{}")}
+		Expect(db.Gorm.Create(canary).Error).To(BeNil())
+
+		check = &models.Check{ID: uuid.New(), CanaryID: canary.ID, Name: "auto-close-check", Type: "http"}
+		Expect(db.Gorm.Create(check).Error).To(BeNil())
+
+		configItem := &models.ConfigItem{ID: uuid.New(), ConfigClass: "MyConfigClass"}
+		Expect(db.Gorm.Create(configItem).Error).To(BeNil())
+
+		analysis = &models.ConfigAnalysis{ID: uuid.New(), ConfigID: configItem.ID, Analyzer: "auto-close-analyzer", Status: "open"}
+		Expect(db.Gorm.Create(analysis).Error).To(BeNil())
+
+		for _, evidence := range []*models.Evidence{{CheckID: &check.ID}, {ConfigAnalysisID: &analysis.ID}} {
+			incident := &models.Incident{
+				ID:             uuid.New(),
+				Title:          "Auto closed incident",
+				CreatedBy:      john.ID,
+				Type:           models.IncidentTypeAvailability,
+				Status:         models.IncidentStatusOpen,
+				Severity:       "Blocker",
+				IncidentRuleID: &incidentRule.ID,
+			}
+			Expect(db.Gorm.Create(incident).Error).To(BeNil())
+
+			hypothesis := &models.Hypothesis{ID: uuid.New(), IncidentID: incident.ID, Title: incident.Title, CreatedBy: john.ID, Type: "root"}
+			Expect(db.Gorm.Create(hypothesis).Error).To(BeNil())
+
+			evidence.ID, evidence.HypothesisID, evidence.CreatedBy = uuid.New(), hypothesis.ID, john.ID
+			Expect(db.Gorm.Create(evidence).Error).To(BeNil())
+
+			if evidence.CheckID != nil {
+				checkIncident = incident
+			} else {
+				analysisIncident = incident
+			}
+		}
+	})
+
+	ginkgo.It("should not close the incidents of failing checks and open analyses", func() {
+		addCheckStatus(false, 2*time.Hour)
+		Expect(healthyIncidents()).To(BeEmpty())
+	})
+
+	ginkgo.It("should close the incident of a check that has passed since its last failure", func() {
+		addCheckStatus(true, 90*time.Minute)
+		addCheckStatus(true, 30*time.Minute)
+		Expect(healthyIncidents()).To(ConsistOf(checkIncident.ID))
+	})
+
+	ginkgo.It("should not close the incident of a check that failed again", func() {
+		addCheckStatus(false, 10*time.Minute)
+		Expect(healthyIncidents()).To(BeEmpty())
+	})
+
+	ginkgo.It("should close the incident of a resolved analysis", func() {
+		err := db.Gorm.Model(analysis).Updates(map[string]any{"status": "resolved", "last_observed": time.Now().Add(-2 * time.Hour)}).Error
+		Expect(err).To(BeNil())
+		Expect(healthyIncidents()).To(ConsistOf(analysisIncident.ID))
+	})
+
+	ginkgo.It("should not close the incident of an analysis resolved within the timeout", func() {
+		err := db.Gorm.Model(analysis).Update("last_observed", time.Now()).Error
+		Expect(err).To(BeNil())
+		Expect(healthyIncidents()).To(BeEmpty())
+	})
+})
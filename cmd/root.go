@@ -7,6 +7,7 @@ import (
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/incident-commander/api"
 	"github.com/flanksource/incident-commander/db"
+	"github.com/flanksource/incident-commander/events"
 	"github.com/flanksource/incident-commander/k8s"
 	"github.com/flanksource/incident-commander/mail"
 	"github.com/flanksource/incident-commander/rules"
@@ -40,6 +41,7 @@ var httpPort, metricsPort, devGuiPort int
 var publicEndpoint = "http://localhost:8080"
 var configDb, kratosAPI, kratosAdminAPI, postgrestURI string
 var enableAuth, disablePostgrest bool
+var shutdownTimeout time.Duration
 
 func ServerFlags(flags *pflag.FlagSet) {
 	flags.IntVar(&httpPort, "httpPort", 8080, "Port to expose a health dashboard")
@@ -54,7 +56,8 @@ func ServerFlags(flags *pflag.FlagSet) {
 	flags.StringVar(&kratosAdminAPI, "kratos-admin", "http://kratos-admin:80", "Kratos Admin API service")
 	flags.StringVar(&postgrestURI, "postgrest-uri", "http://localhost:3000", "URL for the PostgREST instance to use. If localhost is supplied, a PostgREST instance will be started")
 	flags.BoolVar(&enableAuth, "enable-auth", false, "Enable authentication via Kratos")
-	flags.DurationVar(&rules.Period, "rules-period", 5*time.Minute, "Period to run the rules")
+	flags.DurationVar(&rules.Period, "rules-period", 30*time.Minute, "Period to run the rules against the whole topology. The rules are also run as the components change status")
+	flags.DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "Time to wait for in-flight requests, jobs and events to complete on shutdown")
 	flags.BoolVar(&disablePostgrest, "disable-postgrest", false, "Disable PostgREST. Deprecated (Use --postgrest-uri '' to disable PostgREST)")
 	flags.StringVar(&mail.FromAddress, "email-from-address", "no-reply@flanksource.com", "Email address of the sender")
 	flags.StringVar(&db.PostgresDBAnonRole, "postgrest-anon-role", "postgrest_anon", "PostgREST anonymous role")
@@ -66,6 +69,17 @@ func ServerFlags(flags *pflag.FlagSet) {
 	flags.StringVar(&api.UpstreamConf.AgentName, "upstream-name", "", "name of the cluster")
 	flags.StringSliceVar(&api.UpstreamConf.Labels, "upstream-labels", nil, `labels in the format: "key1=value1,key2=value2"`)
 	flags.IntVar(&upstream.ReconcilePageSize, "upstream-page-size", 500, "upstream reconcilation page size")
+
+	// Flags for retrying failed events
+	flags.DurationVar(&events.ResponderBackoff.Base, "responder-retry-base", events.ResponderBackoff.Base, "Delay before retrying a failed responder event. Doubles with every attempt")
+	flags.DurationVar(&events.ResponderBackoff.Max, "responder-retry-max", events.ResponderBackoff.Max, "Maximum delay before retrying a failed responder event")
+	flags.DurationVar(&events.NotificationSendBackoff.Base, "notification-retry-base", events.NotificationSendBackoff.Base, "Delay before retrying a failed notification. Doubles with every attempt")
+	flags.DurationVar(&events.NotificationSendBackoff.Max, "notification-retry-max", events.NotificationSendBackoff.Max, "Maximum delay before retrying a failed notification")
+	flags.DurationVar(&events.UpstreamPushBackoff.Base, "upstream-push-retry-base", events.UpstreamPushBackoff.Base, "Delay before retrying a failed upstream push. Doubles with every attempt")
+	flags.DurationVar(&events.UpstreamPushBackoff.Max, "upstream-push-retry-max", events.UpstreamPushBackoff.Max, "Maximum delay before retrying a failed upstream push")
+	flags.Float64Var(&events.ResponderBackoff.Jitter, "responder-retry-jitter", events.ResponderBackoff.Jitter, "Fraction of the responder retry delay that's randomized")
+	flags.Float64Var(&events.NotificationSendBackoff.Jitter, "notification-retry-jitter", events.NotificationSendBackoff.Jitter, "Fraction of the notification retry delay that's randomized")
+	flags.Float64Var(&events.UpstreamPushBackoff.Jitter, "upstream-push-retry-jitter", events.UpstreamPushBackoff.Jitter, "Fraction of the upstream push retry delay that's randomized")
 }
 
 func init() {
@@ -1,8 +1,15 @@
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
 	"github.com/flanksource/incident-commander/rules"
+	"github.com/flanksource/incident-commander/utils"
+	jsoniter "github.com/json-iterator/go"
 	"github.com/spf13/cobra"
 )
 
@@ -20,6 +27,56 @@ var incidentRules = &cobra.Command{
 	},
 }
 
+var dryRunDays int
+
+var dryRunIncidentRules = &cobra.Command{
+	Use:    "dry-run <file>...",
+	Short:  "Print the components the incident rules would match and the incidents they would create",
+	Args:   cobra.MinimumNArgs(1),
+	PreRun: PreRun,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := api.NewContext(db.Gorm, nil)
+		for _, file := range args {
+			data, err := readFile(file)
+			if err != nil {
+				logger.Fatalf("Failed to read file %s: %v", file, err)
+			}
+			objects, err := utils.GetUnstructuredObjects(data)
+			if err != nil {
+				logger.Fatalf("Failed to parse file %s: %v", file, err)
+			}
+
+			for _, object := range objects {
+				if object.GetKind() != "IncidentRule" {
+					continue
+				}
+
+				request := rules.DryRunRequest{Name: object.GetName(), Days: dryRunDays}
+				spec, err := json.Marshal(object.Object["spec"])
+				if err != nil {
+					logger.Fatalf("Failed to marshal spec: %v", err)
+				}
+				if err := jsoniter.Unmarshal(spec, &request.Spec); err != nil {
+					logger.Fatalf("Failed to parse spec of rule %s: %v", object.GetName(), err)
+				}
+
+				result, err := rules.DryRun(ctx, request)
+				if err != nil {
+					logger.Fatalf("Failed to dry run rule %s: %v", object.GetName(), err)
+				}
+
+				output, err := json.MarshalIndent(map[string]any{"name": object.GetName(), "result": result}, "", "  ")
+				if err != nil {
+					logger.Fatalf("Failed to marshal result: %v", err)
+				}
+				fmt.Println(string(output))
+			}
+		}
+	},
+}
+
 func init() {
+	dryRunIncidentRules.Flags().IntVar(&dryRunDays, "days", 0, "Days of component status history to simulate the rules over")
+	incidentRules.AddCommand(dryRunIncidentRules)
 	Run.AddCommand(incidentRules)
 }
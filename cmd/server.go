@@ -2,10 +2,15 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/duty/schema/openapi"
@@ -15,7 +20,6 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/spf13/cobra"
 	"gorm.io/gorm"
-	ctrl "sigs.k8s.io/controller-runtime"
 
 	"github.com/flanksource/incident-commander/api"
 	v1 "github.com/flanksource/incident-commander/api/v1"
@@ -25,7 +29,10 @@ import (
 	"github.com/flanksource/incident-commander/events"
 	"github.com/flanksource/incident-commander/jobs"
 	"github.com/flanksource/incident-commander/logs"
+	"github.com/flanksource/incident-commander/notification"
 	"github.com/flanksource/incident-commander/rbac"
+	"github.com/flanksource/incident-commander/responder"
+	"github.com/flanksource/incident-commander/rules"
 	"github.com/flanksource/incident-commander/snapshot"
 	"github.com/flanksource/incident-commander/upstream"
 	"github.com/flanksource/incident-commander/utils"
@@ -121,6 +128,24 @@ func createHTTPServer(gormDB *gorm.DB) *echo.Echo {
 	upstreamGroup.GET("/canary/pull/:agent_name", canary.Pull)
 	upstreamGroup.GET("/status/:agent_name", upstream.Status)
 
+	// Responder webhooks authenticate with the secrets of the team's responder clients
+	e.POST("/responder/webhook/:team_id", responder.HandleWebhook)
+
+	deadLetterGroup := e.Group("/events/dead-letter")
+	deadLetterGroup.GET("", events.ListDeadLetterEvents, rbac.Authorization(rbac.ObjectEventQueue, rbac.ActionRead))
+	deadLetterGroup.GET("/:id", events.GetDeadLetterEvent, rbac.Authorization(rbac.ObjectEventQueue, rbac.ActionRead))
+	deadLetterGroup.POST("/requeue", events.RequeueDeadLetterEvents, rbac.Authorization(rbac.ObjectEventQueue, rbac.ActionWrite))
+	deadLetterGroup.POST("/:id/requeue", events.RequeueDeadLetterEvents, rbac.Authorization(rbac.ObjectEventQueue, rbac.ActionWrite))
+	deadLetterGroup.POST("/purge", events.PurgeDeadLetterEvents, rbac.Authorization(rbac.ObjectEventQueue, rbac.ActionWrite))
+	deadLetterGroup.DELETE("/:id", events.PurgeDeadLetterEvents, rbac.Authorization(rbac.ObjectEventQueue, rbac.ActionWrite))
+
+	e.POST("/rules/dry-run", rules.DryRunHandler, rbac.Authorization(rbac.ObjectRule, rbac.ActionRead))
+
+	notificationGroup := e.Group("/notification")
+	notificationGroup.GET("/sends", notification.ListSends, rbac.Authorization(rbac.ObjectNotification, rbac.ActionRead))
+	notificationGroup.GET("/sends/:id", notification.GetSend, rbac.Authorization(rbac.ObjectNotification, rbac.ActionRead))
+	notificationGroup.POST("/preview", events.PreviewNotificationHandler, rbac.Authorization(rbac.ObjectNotification, rbac.ActionWrite))
+
 	forward(e, "/config", configDb)
 	forward(e, "/canary", api.CanaryCheckerPath)
 	forward(e, "/kratos", kratosAPI)
@@ -130,7 +155,7 @@ func createHTTPServer(gormDB *gorm.DB) *echo.Echo {
 	return e
 }
 
-func launchKopper() {
+func launchKopper(ctx context.Context) {
 	mgr, err := kopper.Manager(&kopper.ManagerOptions{
 		AddToSchemeFunc: v1.AddToScheme,
 	})
@@ -156,7 +181,7 @@ func launchKopper() {
 		logger.Fatalf("Unable to create controller for IncidentRule: %v", err)
 	}
 
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		logger.Fatalf("error running manager: %v", err)
 	}
 }
@@ -183,23 +208,59 @@ var Serve = &cobra.Command{
 			}
 		}
 
-		go jobs.Start()
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
 
-		events.StartConsumers(db.Gorm, events.Config{
+		go jobs.Start(ctx)
+
+		consumers := events.StartConsumers(ctx, db.Gorm, events.Config{
 			UpstreamPush: api.UpstreamConf,
 		})
 
-		go launchKopper()
+		go launchKopper(ctx)
 
 		e := createHTTPServer(db.Gorm)
 		listenAddr := fmt.Sprintf(":%d", httpPort)
 		logger.Infof("Listening on %s", listenAddr)
-		if err := e.Start(listenAddr); err != nil {
-			logger.Fatalf("Failed to start server: %v", err)
-		}
+		go func() {
+			if err := e.Start(listenAddr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Fatalf("Failed to start server: %v", err)
+			}
+		}()
+
+		<-ctx.Done()
+		logger.Infof("Shutting down. Waiting up to %s for in-flight work to complete", shutdownTimeout)
+		shutdown(e, consumers)
 	},
 }
 
+// shutdown drains the http server, the cron jobs and the event consumers.
+// Anything still running after the shutdown timeout is abandoned
+// and any open database transaction is rolled back by postgres.
+func shutdown(e *echo.Echo, consumers *sync.WaitGroup) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := e.Shutdown(ctx); err != nil {
+		logger.Errorf("Error shutting down the http server: %v", err)
+	}
+
+	jobs.Stop(ctx)
+
+	consumersDone := make(chan struct{})
+	go func() {
+		consumers.Wait()
+		close(consumersDone)
+	}()
+
+	select {
+	case <-consumersDone:
+		logger.Infof("Event consumers stopped")
+	case <-ctx.Done():
+		logger.Warnf("Timed out waiting for the event consumers to complete their batches")
+	}
+}
+
 func forward(e *echo.Echo, prefix string, target string, middlewares ...echo.MiddlewareFunc) {
 	middlewares = append(middlewares, ModifyKratosRequestHeaders, proxyMiddleware(e, prefix, target))
 	e.Group(prefix).Use(middlewares...)
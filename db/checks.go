@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+
+	"github.com/flanksource/duty/models"
+	"github.com/google/uuid"
+)
+
+// FailingCheck is an unhealthy check with the number of times in a row it failed.
+type FailingCheck struct {
+	models.Check
+	ConsecutiveFailures int
+}
+
+// GetFailingChecks returns the unhealthy checks of the given ids, or all of them if there are none,
+// with the name and namespace of their canary.
+func GetFailingChecks(ctx context.Context, checkIDs []uuid.UUID) ([]FailingCheck, error) {
+	var checks []models.Check
+	query := Gorm.WithContext(ctx).Where("status = ? AND deleted_at IS NULL", models.CheckStatusUnhealthy)
+	if len(checkIDs) > 0 {
+		query = query.Where("id IN ?", checkIDs)
+	}
+	if err := query.Find(&checks).Error; err != nil {
+		return nil, err
+	}
+	if len(checks) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(checks))
+	canaryIDs := make([]uuid.UUID, 0, len(checks))
+	for _, check := range checks {
+		ids = append(ids, check.ID)
+		canaryIDs = append(canaryIDs, check.CanaryID)
+	}
+
+	var canaries []models.Canary
+	if err := Gorm.WithContext(ctx).Select("id", "name", "namespace").Where("id IN ?", canaryIDs).Find(&canaries).Error; err != nil {
+		return nil, err
+	}
+	canariesByID := make(map[uuid.UUID]models.Canary, len(canaries))
+	for _, canary := range canaries {
+		canariesByID[canary.ID] = canary
+	}
+
+	var failures []struct {
+		CheckID  uuid.UUID
+		Failures int
+	}
+	err := Gorm.WithContext(ctx).Raw(`
+        SELECT check_id, COUNT(*) AS failures FROM check_statuses
+        WHERE check_id IN ? AND NOT status AND time > COALESCE(
+            (SELECT MAX(time) FROM check_statuses AS passed WHERE passed.check_id = check_statuses.check_id AND passed.status),
+            '-infinity'
+        )
+        GROUP BY check_id`, ids).Scan(&failures).Error
+	if err != nil {
+		return nil, err
+	}
+	failuresByID := make(map[uuid.UUID]int, len(failures))
+	for _, f := range failures {
+		failuresByID[f.CheckID] = f.Failures
+	}
+
+	failing := make([]FailingCheck, 0, len(checks))
+	for _, check := range checks {
+		canary := canariesByID[check.CanaryID]
+		check.CanaryName, check.Namespace = canary.Name, canary.Namespace
+		failing = append(failing, FailingCheck{Check: check, ConsecutiveFailures: failuresByID[check.ID]})
+	}
+	return failing, nil
+}
@@ -1,7 +1,14 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
 	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/duty/models"
 	"github.com/flanksource/incident-commander/api"
 	"github.com/google/uuid"
 	"gorm.io/gorm/clause"
@@ -46,6 +53,99 @@ func GetComponentsWithSelector(selector api.ComponentSelector) []uuid.UUID {
 	return compIds
 }
 
+// GetTopologyComponents returns the components of the given ids
+// as they're returned by the topology, i.e. with their analysis.
+func GetTopologyComponents(ctx context.Context, componentIDs []uuid.UUID) (models.Components, error) {
+	var components models.Components
+	if len(componentIDs) == 0 {
+		return components, nil
+	}
+
+	rows, err := Gorm.WithContext(ctx).Raw(`SELECT to_jsonb(topology) FROM topology WHERE id IN ?`, componentIDs).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+
+		var component models.Component
+		if err := json.Unmarshal(raw, &component); err != nil {
+			return nil, fmt.Errorf("error unmarshaling component: %w", err)
+		}
+		components = append(components, &component)
+	}
+	return components, rows.Err()
+}
+
+// GetComponentStatusSince returns when each of the components entered its current status.
+func GetComponentStatusSince(ctx context.Context, componentIDs []uuid.UUID) (map[uuid.UUID]time.Time, error) {
+	since := make(map[uuid.UUID]time.Time, len(componentIDs))
+	if len(componentIDs) == 0 {
+		return since, nil
+	}
+
+	var rows []struct {
+		ComponentID uuid.UUID
+		Since       time.Time
+	}
+	err := Gorm.WithContext(ctx).Raw(`
+        SELECT component_id, MAX(created_at) AS since FROM component_status_history
+        WHERE component_id IN ?
+        GROUP BY component_id`, componentIDs).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		since[row.ComponentID] = row.Since
+	}
+	return since, nil
+}
+
+// ComponentStatusChange is a component entering a status.
+type ComponentStatusChange struct {
+	ComponentID uuid.UUID
+	Status      string
+	CreatedAt   time.Time
+}
+
+// GetComponentStatusHistory returns the status changes of the components since the given time,
+// preceded by the change to the status each component was in at that time.
+// The changes are ordered by component and time.
+func GetComponentStatusHistory(ctx context.Context, since time.Time) ([]ComponentStatusChange, error) {
+	var changes []ComponentStatusChange
+	err := Gorm.WithContext(ctx).Raw(`
+        SELECT component_id, status, created_at FROM (
+            SELECT DISTINCT ON (component_id) component_id, status, created_at FROM component_status_history
+            WHERE created_at < ?
+            ORDER BY component_id, created_at DESC
+        ) AS initial
+        UNION ALL
+        SELECT component_id, status, created_at FROM component_status_history
+        WHERE created_at >= ?
+        ORDER BY component_id, created_at`, since, since).Scan(&changes).Error
+	return changes, err
+}
+
+// DeleteOldComponentStatusHistory deletes the status changes older than the given time,
+// keeping the latest of each component so that its status at that time is still known.
+func DeleteOldComponentStatusHistory(ctx context.Context, before time.Time) (int64, error) {
+	tx := Gorm.WithContext(ctx).Exec(`
+        DELETE FROM component_status_history
+        WHERE created_at < @before AND EXISTS (
+            SELECT 1 FROM component_status_history AS newer
+            WHERE newer.component_id = component_status_history.component_id
+                AND newer.created_at > component_status_history.created_at
+                AND newer.created_at < @before
+        )`, sql.Named("before", before))
+	return tx.RowsAffected, tx.Error
+}
+
 func PersistTeamComponents(teamComps []api.TeamComponent) error {
 	if len(teamComps) == 0 {
 		return nil
@@ -1,5 +1,12 @@
 package db
 
+import (
+	"context"
+
+	"github.com/flanksource/duty/models"
+	"github.com/google/uuid"
+)
+
 func LookupRelatedConfigIDs(configID string, maxDepth int) ([]string, error) {
 	var configIDs []string
 
@@ -24,3 +31,63 @@ func LookupRelatedConfigIDs(configID string, maxDepth int) ([]string, error) {
 
 	return configIDs, nil
 }
+
+// OpenConfigAnalysis is an open config analysis with the name and tags of its config item.
+type OpenConfigAnalysis struct {
+	models.ConfigAnalysis
+	ConfigName string
+	ConfigTags map[string]string
+}
+
+// GetOpenConfigAnalyses returns the open analyses of the given ids, or all of them if there are none,
+// with the type, name and tags of their config item.
+func GetOpenConfigAnalyses(ctx context.Context, analysisIDs []uuid.UUID) ([]OpenConfigAnalysis, error) {
+	var analyses []models.ConfigAnalysis
+	query := Gorm.WithContext(ctx).Where("status = ?", "open")
+	if len(analysisIDs) > 0 {
+		query = query.Where("id IN ?", analysisIDs)
+	}
+	if err := query.Find(&analyses).Error; err != nil {
+		return nil, err
+	}
+	if len(analyses) == 0 {
+		return nil, nil
+	}
+
+	configIDs := make([]uuid.UUID, 0, len(analyses))
+	for _, analysis := range analyses {
+		configIDs = append(configIDs, analysis.ConfigID)
+	}
+
+	var configs []models.ConfigItem
+	if err := Gorm.WithContext(ctx).Select("id", "type", "name", "tags").Where("id IN ? AND deleted_at IS NULL", configIDs).Find(&configs).Error; err != nil {
+		return nil, err
+	}
+	configsByID := make(map[uuid.UUID]models.ConfigItem, len(configs))
+	for _, config := range configs {
+		configsByID[config.ID] = config
+	}
+
+	open := make([]OpenConfigAnalysis, 0, len(analyses))
+	for _, analysis := range analyses {
+		// The analyses of deleted config items are stale
+		config, ok := configsByID[analysis.ConfigID]
+		if !ok {
+			continue
+		}
+
+		if config.Type != nil {
+			analysis.ConfigType = *config.Type
+		}
+		var name string
+		if config.Name != nil {
+			name = *config.Name
+		}
+		var tags map[string]string
+		if config.Tags != nil {
+			tags = *config.Tags
+		}
+		open = append(open, OpenConfigAnalysis{ConfigAnalysis: analysis, ConfigName: name, ConfigTags: tags})
+	}
+	return open, nil
+}
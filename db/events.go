@@ -0,0 +1,97 @@
+package db
+
+import (
+	"github.com/flanksource/incident-commander/api"
+	"gorm.io/gorm"
+)
+
+func deadLetterEventsQuery(ctx *api.Context, query api.DeadLetterEventQuery) *gorm.DB {
+	q := ctx.DB().Model(&api.DeadLetterEvent{})
+	if len(query.IDs) > 0 {
+		q = q.Where("id IN ?", query.IDs)
+	}
+	if query.Name != "" {
+		q = q.Where("name = ?", query.Name)
+	}
+	if query.Error != "" {
+		q = q.Where("error ILIKE ?", "%"+query.Error+"%")
+	}
+
+	return q
+}
+
+func ListDeadLetterEvents(ctx *api.Context, query api.DeadLetterEventQuery) ([]api.DeadLetterEvent, error) {
+	q := deadLetterEventsQuery(ctx, query).Order("dead_at DESC")
+	if query.Limit > 0 {
+		q = q.Limit(query.Limit)
+	}
+
+	var events []api.DeadLetterEvent
+	err := q.Find(&events).Error
+	return events, err
+}
+
+func GetDeadLetterEvent(ctx *api.Context, id string) (*api.DeadLetterEvent, error) {
+	var event api.DeadLetterEvent
+	tx := ctx.DB().Where("id = ?", id).Limit(1).Find(&event)
+	if tx.Error != nil {
+		return nil, tx.Error
+	} else if tx.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &event, nil
+}
+
+// RequeueDeadLetterEvents moves the matching dead events back to the event_queue
+// with their attempts and backoff reset and returns the number of events requeued.
+// Events that are already pending in the event_queue are dropped from the dead letter queue.
+func RequeueDeadLetterEvents(ctx *api.Context, query api.DeadLetterEventQuery) (int64, error) {
+	var count int64
+	err := ctx.DB().Transaction(func(tx *gorm.DB) error {
+		ids := deadLetterEventsQuery(ctx, query).Select("id")
+		res := tx.Exec(`
+            WITH requeued AS (
+                DELETE FROM event_queue_dead_letter WHERE id IN (?)
+                RETURNING id, name, properties, priority, created_at
+            ), retries AS (
+                DELETE FROM event_queue_retries WHERE event_id IN (SELECT id FROM requeued)
+            )
+            INSERT INTO event_queue (id, name, properties, priority, created_at)
+            SELECT id, name, properties, priority, created_at FROM requeued
+            ON CONFLICT (name, properties) DO NOTHING
+        `, ids)
+		if res.Error != nil {
+			return res.Error
+		}
+		count = res.RowsAffected
+
+		return tx.Exec("NOTIFY event_queue_updates, 'update'").Error
+	})
+
+	return count, err
+}
+
+// PurgeDeadLetterEvents deletes the matching dead events
+// and returns the number of events deleted.
+func PurgeDeadLetterEvents(ctx *api.Context, query api.DeadLetterEventQuery) (int64, error) {
+	res := ctx.DB().Where("id IN (?)", deadLetterEventsQuery(ctx, query).Select("id")).Delete(&api.DeadLetterEvent{})
+	return res.RowsAffected, res.Error
+}
+
+// DeadLetterExhaustedEvents moves the events that have
+// more than maxAttempts attempts from the event_queue to the dead letter queue.
+func DeadLetterExhaustedEvents(ctx *api.Context, maxAttempts int) (int64, error) {
+	res := ctx.DB().Exec(`
+        WITH exhausted AS (
+            DELETE FROM event_queue WHERE attempts > ?
+            RETURNING id, name, properties, error, attempts, priority, last_attempt, created_at
+        ), retries AS (
+            DELETE FROM event_queue_retries WHERE event_id IN (SELECT id FROM exhausted)
+        )
+        INSERT INTO event_queue_dead_letter (id, name, properties, error, attempts, priority, last_attempt, created_at)
+        SELECT id, name, properties, error, attempts, priority, last_attempt, created_at FROM exhausted
+        ON CONFLICT (id) DO NOTHING
+    `, maxAttempts)
+	return res.RowsAffected, res.Error
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"context"
+	"database/sql"
 	"time"
 
 	"github.com/flanksource/duty/models"
@@ -55,3 +57,111 @@ func DeleteIncidentRule(id string) error {
 		Delete(&api.IncidentRule{}, "id = ?", id).
 		Error
 }
+
+// HealthyRuleIncident is an incident opened by a rule whose resources have all recovered.
+type HealthyRuleIncident struct {
+	ID uuid.UUID
+	// Resources are the names of the incident's components, checks and config analyses
+	Resources string
+}
+
+// GetHealthyRuleIncidents returns the incidents of the rule with any of the given statuses
+// whose resources have all recovered, or been deleted, since healthySince:
+// the components are healthy, the checks have passed since their last failure
+// and the config analyses are no longer open.
+func GetHealthyRuleIncidents(ctx context.Context, ruleID uuid.UUID, statuses []api.IncidentStatus, healthySince time.Time) ([]HealthyRuleIncident, error) {
+	var incidents []HealthyRuleIncident
+	err := Gorm.WithContext(ctx).Raw(`
+        WITH status_since AS (
+            SELECT component_id, MAX(created_at) AS since FROM component_status_history GROUP BY component_id
+        )
+        SELECT incidents.id, string_agg(DISTINCT COALESCE(components.name, checks.name, config_analysis.analyzer), ', ') AS resources
+        FROM incidents
+        INNER JOIN hypotheses ON hypotheses.incident_id = incidents.id
+        INNER JOIN evidences ON evidences.hypothesis_id = hypotheses.id
+        LEFT JOIN components ON components.id = evidences.component_id
+        LEFT JOIN status_since ON status_since.component_id = components.id
+        LEFT JOIN checks ON checks.id = evidences.check_id
+        LEFT JOIN LATERAL (
+            -- The first pass since the last failure, none if the latest status failed
+            SELECT MIN(time) AS since FROM check_statuses
+            WHERE check_id = checks.id AND status AND time > COALESCE(
+                (SELECT MAX(time) FROM check_statuses AS failed WHERE failed.check_id = checks.id AND NOT failed.status),
+                '-infinity'
+            )
+        ) AS check_passing ON evidences.check_id IS NOT NULL
+        LEFT JOIN config_analysis ON config_analysis.id = evidences.config_analysis_id
+        WHERE incidents.incident_rule_id = @rule_id AND incidents.status IN @statuses
+            AND COALESCE(evidences.component_id, evidences.check_id, evidences.config_analysis_id) IS NOT NULL
+        GROUP BY incidents.id
+        HAVING BOOL_AND(COALESCE(
+            CASE
+            WHEN evidences.component_id IS NOT NULL THEN
+                components.deleted_at IS NOT NULL OR
+                (components.status = 'healthy' AND COALESCE(status_since.since, components.updated_at) <= @healthy_since)
+            WHEN evidences.check_id IS NOT NULL THEN
+                checks.deleted_at IS NOT NULL OR check_passing.since <= @healthy_since
+            ELSE
+                config_analysis.id IS NULL OR
+                (config_analysis.status <> 'open' AND COALESCE(config_analysis.last_observed, config_analysis.first_observed) <= @healthy_since)
+            END,
+            FALSE
+        ))`,
+		sql.Named("rule_id", ruleID), sql.Named("statuses", statuses), sql.Named("healthy_since", healthySince),
+	).Scan(&incidents).Error
+	return incidents, err
+}
+
+// GroupedIncident is an open incident of a group of resources.
+type GroupedIncident struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	// HypothesisID is the hypothesis the evidences of the group are added to
+	HypothesisID uuid.UUID
+	// Resources are the ids of the components, checks and config analyses of the incident
+	Resources map[string]struct{}
+}
+
+// GetOpenGroupedIncidents returns the latest open incident of each fingerprint.
+func GetOpenGroupedIncidents(ctx context.Context) (map[string]*GroupedIncident, error) {
+	var rows []struct {
+		Fingerprint  string
+		IncidentID   uuid.UUID
+		CreatedAt    time.Time
+		HypothesisID *uuid.UUID
+		ResourceID   *uuid.UUID
+	}
+	err := Gorm.WithContext(ctx).Raw(`
+        SELECT
+            incident_fingerprints.fingerprint,
+            incidents.id AS incident_id,
+            incidents.created_at,
+            hypotheses.id AS hypothesis_id,
+            COALESCE(evidences.component_id, evidences.check_id, evidences.config_analysis_id) AS resource_id
+        FROM incident_fingerprints
+        INNER JOIN incidents ON incidents.id = incident_fingerprints.incident_id
+        LEFT JOIN hypotheses ON hypotheses.incident_id = incidents.id
+        LEFT JOIN evidences ON evidences.hypothesis_id = hypotheses.id
+        WHERE incidents.status = ?
+        ORDER BY incidents.created_at, incidents.id, hypotheses.created_at`, api.IncidentStatusOpen).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	incidents := make(map[string]*GroupedIncident)
+	for _, row := range rows {
+		incident, ok := incidents[row.Fingerprint]
+		if !ok || incident.ID != row.IncidentID {
+			// The rows are ordered by creation, so the latest incident of the fingerprint is kept
+			incident = &GroupedIncident{ID: row.IncidentID, CreatedAt: row.CreatedAt, Resources: make(map[string]struct{})}
+			incidents[row.Fingerprint] = incident
+		}
+		if incident.HypothesisID == uuid.Nil && row.HypothesisID != nil {
+			incident.HypothesisID = *row.HypothesisID
+		}
+		if row.ResourceID != nil {
+			incident.Resources[row.ResourceID.String()] = struct{}{}
+		}
+	}
+	return incidents, nil
+}
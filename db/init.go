@@ -76,6 +76,10 @@ func Init(connection string) error {
 		if err = duty.Migrate(ConnectionString, opts); err != nil {
 			return err
 		}
+
+		if err = RunMigrations(Gorm); err != nil {
+			return err
+		}
 	}
 
 	system := api.Person{}
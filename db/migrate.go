@@ -0,0 +1,44 @@
+package db
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+
+	"github.com/flanksource/commons/logger"
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// RunMigrations applies the mission control specific sql scripts on top of
+// the schema managed by duty. Every script must be idempotent as all of them
+// are run on each startup.
+func RunMigrations(gormDB *gorm.DB) error {
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		script, err := migrations.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		logger.Tracef("Running script %s", file)
+		if _, err := sqlDB.Exec(string(script)); err != nil {
+			return fmt.Errorf("failed to run script %s: %w", file, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,16 @@
+-- Events that have exhausted all their attempts are moved out of event_queue
+-- into this table, where they can be inspected, requeued or purged.
+CREATE TABLE IF NOT EXISTS event_queue_dead_letter (
+  id uuid PRIMARY KEY,
+  name text NOT NULL,
+  properties jsonb,
+  error text,
+  attempts integer NOT NULL DEFAULT 0,
+  priority integer NOT NULL DEFAULT 100,
+  last_attempt timestamptz,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  dead_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS event_queue_dead_letter_name_idx ON event_queue_dead_letter (name);
+CREATE INDEX IF NOT EXISTS event_queue_dead_letter_dead_at_idx ON event_queue_dead_letter (dead_at);
@@ -0,0 +1,9 @@
+-- Failed events are retried with a backoff. The consumers only pick up
+-- events whose next attempt has elapsed.
+-- The schedule is kept apart from the event_queue table managed by duty.
+CREATE TABLE IF NOT EXISTS event_queue_retries (
+  event_id uuid PRIMARY KEY,
+  next_attempt_at timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS event_queue_retries_next_attempt_at_idx ON event_queue_retries (next_attempt_at);
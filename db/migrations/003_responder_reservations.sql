@@ -0,0 +1,10 @@
+-- A reservation is committed before a responder's external issue is created.
+-- A retry finds the reservation and looks up the issue on the external system
+-- instead of creating a duplicate.
+CREATE TABLE IF NOT EXISTS responder_reservations (
+  responder_id uuid PRIMARY KEY REFERENCES responders(id) ON DELETE CASCADE,
+  idempotency_key text NOT NULL UNIQUE,
+  external_id text,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  updated_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,7 @@
+-- The last known state of a responder's external issue.
+-- It's compared against on every sync to detect changes made on the external system.
+CREATE TABLE IF NOT EXISTS responder_sync_state (
+  responder_id uuid PRIMARY KEY REFERENCES responders(id) ON DELETE CASCADE,
+  external_status text,
+  updated_at timestamptz NOT NULL DEFAULT now()
+);
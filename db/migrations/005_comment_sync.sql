@@ -0,0 +1,64 @@
+-- Cursors of the comment sync of a responder.
+-- comments_synced_at is the start of the last sync. Only the comments updated since are fetched.
+-- comments_full_synced_at is the start of the last sync that fetched all the comments to detect deletions.
+ALTER TABLE responder_sync_state ADD COLUMN IF NOT EXISTS comments_synced_at timestamptz;
+ALTER TABLE responder_sync_state ADD COLUMN IF NOT EXISTS comments_full_synced_at timestamptz;
+
+-- External comments of deleted comments that are yet to be deleted on the responders.
+CREATE TABLE IF NOT EXISTS comment_responder_deletions (
+  id uuid PRIMARY KEY DEFAULT generate_ulid(),
+  comment_id uuid NOT NULL,
+  responder_id uuid NOT NULL REFERENCES responders(id) ON DELETE CASCADE,
+  external_id text NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS comment_responder_deletions_comment_id_idx ON comment_responder_deletions(comment_id);
+
+-- Enqueue comment edits so that they're propagated to the responders.
+-- An edit pulled from a responder sets incident_commander.comment_origin to the responder's id
+-- in its transaction, which is recorded as the event's origin so that the edit isn't pushed back to it.
+CREATE OR REPLACE FUNCTION insert_comment_update_in_event_queue() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO event_queue(name, properties) VALUES ('incident.comment.updated', jsonb_strip_nulls(jsonb_build_object(
+        'id', NEW.id,
+        'origin', NULLIF(current_setting('incident_commander.comment_origin', true), '')
+    )))
+    ON CONFLICT (name, properties) DO NOTHING;
+    NOTIFY event_queue_updates, 'update';
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER comment_update_enqueue
+AFTER UPDATE OF comment ON comments
+FOR EACH ROW WHEN (OLD.comment IS DISTINCT FROM NEW.comment)
+EXECUTE PROCEDURE insert_comment_update_in_event_queue();
+
+-- Keep the external comments of a deleted comment so that they can be deleted on the responders.
+-- The references to the comment are removed as they'd otherwise block the deletion.
+CREATE OR REPLACE FUNCTION insert_comment_deletion_in_event_queue() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO comment_responder_deletions(comment_id, responder_id, external_id)
+    SELECT OLD.id, responder_id, external_id FROM comment_responders
+    WHERE comment_id = OLD.id AND external_id IS NOT NULL;
+
+    IF OLD.responder_id IS NOT NULL AND OLD.external_id IS NOT NULL THEN
+        INSERT INTO comment_responder_deletions(comment_id, responder_id, external_id)
+        VALUES (OLD.id, OLD.responder_id, OLD.external_id);
+    END IF;
+
+    DELETE FROM comment_responders WHERE comment_id = OLD.id;
+    UPDATE incident_histories SET comment_id = NULL WHERE comment_id = OLD.id;
+
+    INSERT INTO event_queue(name, properties) VALUES ('incident.comment.deleted', jsonb_build_object('id', OLD.id))
+    ON CONFLICT (name, properties) DO NOTHING;
+    NOTIFY event_queue_updates, 'update';
+    RETURN OLD;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER comment_delete_enqueue
+BEFORE DELETE ON comments
+FOR EACH ROW
+EXECUTE PROCEDURE insert_comment_deletion_in_event_queue();
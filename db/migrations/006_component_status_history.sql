@@ -0,0 +1,29 @@
+-- Status transitions of components.
+-- The latest row of a component is when it entered its current status.
+CREATE TABLE IF NOT EXISTS component_status_history (
+  id uuid PRIMARY KEY DEFAULT generate_ulid(),
+  component_id uuid NOT NULL REFERENCES components(id) ON DELETE CASCADE,
+  status text NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS component_status_history_component_id_created_at_idx ON component_status_history(component_id, created_at DESC);
+
+-- Components that predate the history are taken to have been in their status since their last update
+INSERT INTO component_status_history(component_id, status, created_at)
+SELECT id, status, COALESCE(updated_at, created_at, now()) FROM components
+WHERE NOT EXISTS (SELECT 1 FROM component_status_history WHERE component_id = components.id);
+
+CREATE OR REPLACE FUNCTION insert_component_status_history() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' OR OLD.status IS DISTINCT FROM NEW.status THEN
+        INSERT INTO component_status_history(component_id, status) VALUES (NEW.id, NEW.status);
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER component_status_history_insert
+AFTER INSERT OR UPDATE OF status ON components
+FOR EACH ROW
+EXECUTE PROCEDURE insert_component_status_history();
@@ -0,0 +1,17 @@
+-- Enqueue the status changes of components so that the incident rules are evaluated against them.
+-- A component that changes status again before its event is consumed is evaluated once.
+CREATE OR REPLACE FUNCTION insert_component_status_in_event_queue() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' OR OLD.status IS DISTINCT FROM NEW.status THEN
+        INSERT INTO event_queue(name, properties) VALUES ('component.status.updated', jsonb_build_object('id', NEW.id))
+        ON CONFLICT (name, properties) DO NOTHING;
+        NOTIFY event_queue_updates, 'update';
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER component_status_enqueue
+AFTER INSERT OR UPDATE OF status ON components
+FOR EACH ROW
+EXECUTE PROCEDURE insert_component_status_in_event_queue();
@@ -0,0 +1,33 @@
+-- Enqueue the failures of checks so that the incident rules are evaluated against them.
+-- The failures are enqueued, instead of the status changes, as the rules can select
+-- the checks that failed a number of times in a row.
+CREATE OR REPLACE FUNCTION insert_check_failure_in_event_queue() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO event_queue(name, properties) VALUES ('check.status.failed', jsonb_build_object('id', NEW.check_id))
+    ON CONFLICT (name, properties) DO NOTHING;
+    NOTIFY event_queue_updates, 'update';
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER check_failure_enqueue
+AFTER INSERT ON check_statuses
+FOR EACH ROW WHEN (NOT NEW.status)
+EXECUTE PROCEDURE insert_check_failure_in_event_queue();
+
+-- Enqueue the open config analyses so that the incident rules are evaluated against them.
+CREATE OR REPLACE FUNCTION insert_config_analysis_in_event_queue() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' OR OLD.status IS DISTINCT FROM NEW.status OR OLD.severity IS DISTINCT FROM NEW.severity THEN
+        INSERT INTO event_queue(name, properties) VALUES ('config_analysis.updated', jsonb_build_object('id', NEW.id))
+        ON CONFLICT (name, properties) DO NOTHING;
+        NOTIFY event_queue_updates, 'update';
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER config_analysis_enqueue
+AFTER INSERT OR UPDATE OF status, severity ON config_analysis
+FOR EACH ROW WHEN (NEW.status = 'open')
+EXECUTE PROCEDURE insert_config_analysis_in_event_queue();
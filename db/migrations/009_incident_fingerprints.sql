@@ -0,0 +1,10 @@
+-- Fingerprints of the groups of the incidents opened by rules that group their resources.
+-- The matching resources of a group are added to the open incident of its fingerprint.
+CREATE TABLE IF NOT EXISTS incident_fingerprints (
+  incident_id uuid PRIMARY KEY REFERENCES incidents(id) ON DELETE CASCADE,
+  fingerprint text NOT NULL,
+  incident_rule_id uuid REFERENCES incident_rules(id) ON DELETE SET NULL,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS incident_fingerprints_fingerprint_idx ON incident_fingerprints(fingerprint);
@@ -0,0 +1,34 @@
+-- Maintenance windows that suppress the incidents of the rules and the notifications of their targets.
+CREATE TABLE IF NOT EXISTS silences (
+  id uuid PRIMARY KEY DEFAULT generate_ulid(),
+  name text NOT NULL,
+  description text,
+  components text[],
+  checks text[],
+  teams text[],
+  labels jsonb,
+  "from" timestamptz,
+  until timestamptz,
+  schedule text,
+  duration text,
+  timezone text,
+  created_by uuid REFERENCES people(id),
+  created_at timestamptz NOT NULL DEFAULT now(),
+  updated_at timestamptz NOT NULL DEFAULT now(),
+  deleted_at timestamptz
+);
+
+-- Audit of the actions suppressed by the silences.
+-- Repeated suppressions of the same action are counted instead of recorded again.
+CREATE TABLE IF NOT EXISTS silence_suppressions (
+  silence_id uuid NOT NULL REFERENCES silences(id) ON DELETE CASCADE,
+  action text NOT NULL,
+  source_id text NOT NULL,
+  resource_id text NOT NULL,
+  recipient text NOT NULL DEFAULT '',
+  description text,
+  count integer NOT NULL DEFAULT 1,
+  first_at timestamptz NOT NULL DEFAULT now(),
+  last_at timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (silence_id, action, source_id, resource_id, recipient)
+);
@@ -0,0 +1,47 @@
+-- Throttling of the notifications, kept apart from the notifications table managed by duty.
+CREATE TABLE IF NOT EXISTS notification_settings (
+  notification_id uuid PRIMARY KEY REFERENCES notifications(id) ON DELETE CASCADE,
+  -- How long after a send the notifications of the same group are suppressed, e.g. 1h
+  repeat_interval text,
+  -- Paths of the event's resource whose values group the notifications, e.g. check.id or canary.namespace
+  group_by text[],
+  -- Maximum number of sends to a recipient per rate limit period
+  rate_limit integer,
+  rate_limit_period text,
+  updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- The notifications are cached, so the caches are purged on changes of their settings like on changes of the notifications
+CREATE OR REPLACE FUNCTION notification_settings_trigger_function() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO event_queue(name, properties) VALUES ('notification.update', jsonb_build_object('id', COALESCE(NEW.notification_id, OLD.notification_id)))
+    ON CONFLICT (name, properties) DO NOTHING;
+    NOTIFY event_queue_updates, 'update';
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER notification_settings_update_enqueue
+AFTER INSERT OR UPDATE OR DELETE ON notification_settings
+FOR EACH ROW
+EXECUTE PROCEDURE notification_settings_trigger_function();
+
+-- The last send of each group of a notification to a recipient, and what was suppressed since
+CREATE TABLE IF NOT EXISTS notification_send_state (
+  notification_id uuid NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+  recipient text NOT NULL,
+  group_key text NOT NULL,
+  last_sent_at timestamptz,
+  suppressed integer NOT NULL DEFAULT 0,
+  suppressed_since timestamptz,
+  PRIMARY KEY (notification_id, recipient, group_key)
+);
+
+-- The sends of a notification to a recipient in the current rate limit period
+CREATE TABLE IF NOT EXISTS notification_rate_limits (
+  notification_id uuid NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+  recipient text NOT NULL,
+  window_start timestamptz NOT NULL,
+  count integer NOT NULL DEFAULT 0,
+  PRIMARY KEY (notification_id, recipient)
+);
@@ -0,0 +1,25 @@
+-- Every attempt to send a notification, with what was sent to whom and how it went.
+CREATE TABLE IF NOT EXISTS notification_sends (
+  id uuid PRIMARY KEY DEFAULT generate_ulid(),
+  notification_id uuid NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+  -- person:<id>, team:<id>/<notification name> or the name of the custom service
+  recipient text NOT NULL,
+  person_id uuid,
+  team_id uuid,
+  event_name text NOT NULL,
+  -- The resource of the event, e.g. the check or the incident
+  resource_id text,
+  incident_id uuid,
+  message text,
+  -- The shoutrrr service the message was sent with, e.g. slack or smtp
+  service text,
+  -- The response of the service, i.e. its error if the send failed
+  response text,
+  -- sent, failed, suppressed or digested
+  status text NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS notification_sends_notification_id_idx ON notification_sends(notification_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS notification_sends_incident_id_idx ON notification_sends(incident_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS notification_sends_recipient_idx ON notification_sends(recipient, created_at DESC);
@@ -0,0 +1,25 @@
+-- Digest delivery of the notifications: the notifications are buffered and sent as periodic summaries.
+-- Cron schedule of the digests, e.g. @daily or 0 9 * * 1-5. Notifications without it are sent right away.
+ALTER TABLE notification_settings ADD COLUMN IF NOT EXISTS digest_schedule text;
+-- Template of the digest message. Defaults to a list of the buffered messages.
+ALTER TABLE notification_settings ADD COLUMN IF NOT EXISTS digest_template text;
+
+-- The notifications buffered until the next digest to their recipient
+CREATE TABLE IF NOT EXISTS notification_digest_entries (
+  id uuid PRIMARY KEY DEFAULT generate_ulid(),
+  notification_id uuid NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+  -- person:<id>, team:<id>/<notification name> or the name of the custom service
+  recipient text NOT NULL,
+  person_id uuid,
+  team_id uuid,
+  -- Name of the team's notification or of the custom service
+  notification_name text,
+  event_name text NOT NULL,
+  resource_id text,
+  incident_id uuid,
+  -- The message the notification would have sent on its own
+  message text,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS notification_digest_entries_recipient_idx ON notification_digest_entries(notification_id, recipient, created_at);
@@ -0,0 +1,20 @@
+-- Enqueue a status update for each responder with an issue when the status of its incident changes,
+-- so that a failing responder neither holds back the others nor the notifications of the status change.
+-- The issue is moved to the incident's status at the time the event is consumed,
+-- so a responder is updated once for the status changes made before its event is consumed.
+CREATE OR REPLACE FUNCTION insert_responder_status_update_in_event_queue() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO event_queue(name, properties)
+    SELECT 'responder.status.update', jsonb_build_object('id', responders.id)
+    FROM responders
+    WHERE responders.incident_id = NEW.id AND responders.external_id IS NOT NULL
+    ON CONFLICT (name, properties) DO NOTHING;
+    NOTIFY event_queue_updates, 'update';
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER responder_status_update_enqueue
+AFTER UPDATE OF status ON incidents
+FOR EACH ROW WHEN (OLD.status IS DISTINCT FROM NEW.status)
+EXECUTE PROCEDURE insert_responder_status_update_in_event_queue();
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
@@ -28,13 +29,29 @@ func (incidentRule *IncidentRule) GetSpec() (*api.IncidentRuleSpec, error) {
 	if incidentRule.spec != nil {
 		return incidentRule.spec, nil
 	}
-	incidentRule.spec = &api.IncidentRuleSpec{}
-	if err := jsoniter.Unmarshal(incidentRule.Spec, incidentRule.spec); err != nil {
-		return nil, err
+	spec := &api.IncidentRuleSpec{}
+	if err := jsoniter.Unmarshal(incidentRule.Spec, spec); err != nil {
+		// The specs persisted from the CRDs have their durations in nanoseconds instead of e.g. "1h"
+		spec = &api.IncidentRuleSpec{}
+		if jsonErr := json.Unmarshal(incidentRule.Spec, spec); jsonErr != nil {
+			return nil, err
+		}
 	}
+	incidentRule.spec = spec
 	return incidentRule.spec, nil
 }
 
+// SetSpec sets the spec of a rule that isn't read from the database.
+func (incidentRule *IncidentRule) SetSpec(spec api.IncidentRuleSpec) error {
+	b, err := jsoniter.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	incidentRule.Spec = b
+	incidentRule.spec = &spec
+	return nil
+}
+
 func (incidentRule *IncidentRule) BeforeCreate(tx *gorm.DB) (err error) {
 	if incidentRule.CreatedBy == nil {
 		incidentRule.CreatedBy = api.SystemUserID
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"github.com/flanksource/incident-commander/api"
+)
+
+const defaultNotificationSendsLimit = 100
+
+// ListNotificationSends returns the latest notification sends matching the query.
+func ListNotificationSends(ctx *api.Context, query api.NotificationSendQuery) ([]api.NotificationSend, error) {
+	q := ctx.DB().Order("created_at DESC")
+	if query.NotificationID != "" {
+		q = q.Where("notification_id = ?", query.NotificationID)
+	}
+	if query.IncidentID != "" {
+		q = q.Where("incident_id = ?", query.IncidentID)
+	}
+	if query.Recipient != "" {
+		q = q.Where("recipient = ?", query.Recipient)
+	}
+	if query.PersonID != "" {
+		q = q.Where("person_id = ?", query.PersonID)
+	}
+	if query.TeamID != "" {
+		q = q.Where("team_id = ?", query.TeamID)
+	}
+	if query.Status != "" {
+		q = q.Where("status = ?", query.Status)
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultNotificationSendsLimit
+	}
+
+	var sends []api.NotificationSend
+	err := q.Limit(limit).Find(&sends).Error
+	return sends, err
+}
+
+// GetNotificationSend returns the notification send of the id, or nil if there's none.
+func GetNotificationSend(ctx *api.Context, id string) (*api.NotificationSend, error) {
+	var send api.NotificationSend
+	tx := ctx.DB().Where("id = ?", id).Limit(1).Find(&send)
+	if tx.Error != nil {
+		return nil, tx.Error
+	} else if tx.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &send, nil
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// ReserveResponderExternalID records the intent to create an external issue for the responder.
+// It returns the reservation and whether it was created by this call.
+// It intentionally doesn't use the caller's transaction so that the reservation
+// survives a failure to commit that transaction.
+func ReserveResponderExternalID(responder api.Responder) (*api.ResponderReservation, bool, error) {
+	reservation := api.ResponderReservation{
+		ResponderID:    responder.ID,
+		IdempotencyKey: responder.IdempotencyKey(),
+	}
+	tx := Gorm.Clauses(clause.OnConflict{DoNothing: true}).Create(&reservation)
+	if tx.Error != nil {
+		return nil, false, tx.Error
+	}
+	created := tx.RowsAffected > 0
+
+	if err := Gorm.Where("responder_id = ?", responder.ID).First(&reservation).Error; err != nil {
+		return nil, false, err
+	}
+
+	return &reservation, created, nil
+}
+
+// CompleteResponderReservation records the id of the issue created on the external system.
+// Like the reservation, it's written outside of the caller's transaction.
+func CompleteResponderReservation(responderID uuid.UUID, externalID string) error {
+	return Gorm.Model(&api.ResponderReservation{}).Where("responder_id = ?", responderID).
+		Update("external_id", externalID).Error
+}
+
+// GetResponderSyncState returns the last known state of the responder's external issue.
+// Returns nil if the state hasn't been recorded yet.
+func GetResponderSyncState(ctx *api.Context, responderID uuid.UUID) (*api.ResponderSyncState, error) {
+	var state api.ResponderSyncState
+	tx := ctx.DB().Where("responder_id = ?", responderID).Limit(1).Find(&state)
+	if tx.Error != nil {
+		return nil, tx.Error
+	} else if tx.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &state, nil
+}
+
+// SaveResponderExternalStatus records the state of the responder's external issue.
+func SaveResponderExternalStatus(ctx *api.Context, responderID uuid.UUID, status string) error {
+	state := api.ResponderSyncState{
+		ResponderID:    responderID,
+		ExternalStatus: status,
+	}
+	return ctx.DB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "responder_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"external_status", "updated_at"}),
+	}).Create(&state).Error
+}
+
+// SetResponderClient records the name of the client the responder is filed with.
+// It's kept in the responder's properties as the responders table is owned by duty.
+func SetResponderClient(ctx *api.Context, responderID uuid.UUID, clientName string) error {
+	return ctx.DB().Exec(`UPDATE responders SET properties = COALESCE(properties, '{}'::jsonb) || jsonb_build_object(?::text, ?::text) WHERE id = ?`,
+		api.ResponderClientProperty, clientName, responderID).Error
+}
+
+// SaveResponderCommentCursor records the start of a comment sync of the responder.
+func SaveResponderCommentCursor(ctx *api.Context, responderID uuid.UUID, syncedAt time.Time, full bool) error {
+	state := api.ResponderSyncState{
+		ResponderID:      responderID,
+		CommentsSyncedAt: &syncedAt,
+	}
+	columns := []string{"comments_synced_at", "updated_at"}
+	if full {
+		state.CommentsFullSyncedAt = &syncedAt
+		columns = append(columns, "comments_full_synced_at")
+	}
+
+	return ctx.DB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "responder_id"}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).Create(&state).Error
+}
+
+// ResponderComment is a comment of an incident that's linked to an external comment on a responder.
+type ResponderComment struct {
+	CommentID  uuid.UUID
+	Comment    string
+	ExternalID string
+	// Source is true if the comment was pulled from the responder
+	// and false if it was pushed to the responder.
+	Source bool
+	// UpdatedAt is when the comment was last edited here, or created if it wasn't
+	UpdatedAt time.Time
+}
+
+// GetResponderComments returns the comments linked to external comments of the responder.
+func GetResponderComments(ctx *api.Context, responderID uuid.UUID) ([]ResponderComment, error) {
+	const query = `
+        SELECT id AS comment_id, comment, external_id, true AS source, COALESCE(updated_at, created_at) AS updated_at FROM comments
+        WHERE responder_id = @responder_id AND external_id IS NOT NULL
+        UNION ALL
+        SELECT comments.id AS comment_id, comments.comment, comment_responders.external_id, false AS source,
+            COALESCE(comments.updated_at, comments.created_at) AS updated_at
+        FROM comment_responders INNER JOIN comments ON comments.id = comment_responders.comment_id
+        WHERE comment_responders.responder_id = @responder_id AND comment_responders.external_id IS NOT NULL
+    `
+
+	var comments []ResponderComment
+	err := ctx.DB().Raw(query, sql.Named("responder_id", responderID)).Scan(&comments).Error
+	return comments, err
+}
@@ -0,0 +1,48 @@
+package events
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff decides how long a failed event must wait before it's attempted again.
+// The zero value retries immediately.
+type Backoff struct {
+	Base   time.Duration // delay before the first retry
+	Max    time.Duration // upper bound of the delay. Unbounded if zero.
+	Jitter float64       // fraction of the delay, between 0 and 1, that's randomized
+}
+
+// Delay returns the delay before the given attempt.
+// The delay doubles with every attempt.
+func (b Backoff) Delay(attempts int) time.Duration {
+	if b.Base <= 0 || attempts <= 0 {
+		return 0
+	}
+
+	delay := float64(b.Base) * math.Pow(2, float64(attempts-1))
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+
+	if b.Jitter > 0 {
+		jitter := math.Min(b.Jitter, 1)
+		// Spread the delay uniformly over [delay * (1 - jitter), delay * (1 + jitter)]
+		delay += delay * jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
+
+// NextAttempt returns when the given attempt should be made.
+// Returns nil when the attempt can be made right away.
+func (b Backoff) NextAttempt(attempts int) *time.Time {
+	delay := b.Delay(attempts)
+	if delay <= 0 {
+		return nil
+	}
+
+	next := time.Now().Add(delay)
+	return &next
+}
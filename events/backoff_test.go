@@ -0,0 +1,32 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	tests := []struct {
+		name     string
+		backoff  Backoff
+		attempts int
+		min      time.Duration
+		max      time.Duration
+	}{
+		{name: "zero value", backoff: Backoff{}, attempts: 3, min: 0, max: 0},
+		{name: "first attempt", backoff: Backoff{Base: time.Second}, attempts: 1, min: time.Second, max: time.Second},
+		{name: "exponential", backoff: Backoff{Base: time.Second}, attempts: 4, min: 8 * time.Second, max: 8 * time.Second},
+		{name: "capped", backoff: Backoff{Base: time.Second, Max: 5 * time.Second}, attempts: 10, min: 5 * time.Second, max: 5 * time.Second},
+		{name: "jitter", backoff: Backoff{Base: 10 * time.Second, Jitter: 0.5}, attempts: 1, min: 5 * time.Second, max: 15 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if got := tt.backoff.Delay(tt.attempts); got < tt.min || got > tt.max {
+					t.Fatalf("Delay() = %v, want between %v and %v", got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
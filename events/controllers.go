@@ -0,0 +1,143 @@
+package events
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// ListDeadLetterEvents lists the events in the dead letter queue.
+// The events can be filtered by the "name" & "error" query params.
+func ListDeadLetterEvents(c echo.Context) error {
+	ctx := c.(*api.Context)
+
+	query := api.DeadLetterEventQuery{
+		Name:  c.QueryParam("name"),
+		Error: c.QueryParam("error"),
+	}
+	if limitRaw := c.QueryParam("limit"); limitRaw != "" {
+		limit, err := strconv.Atoi(limitRaw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, api.HTTPError{Error: err.Error(), Message: "'limit' param needs to be a number"})
+		}
+		query.Limit = limit
+	}
+
+	events, err := db.ListDeadLetterEvents(ctx, query)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, api.HTTPError{Error: err.Error(), Message: "failed to list dead events"})
+	}
+
+	return c.JSON(http.StatusOK, events)
+}
+
+// GetDeadLetterEvent returns a single event from the dead letter queue.
+func GetDeadLetterEvent(c echo.Context) error {
+	ctx := c.(*api.Context)
+
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		return c.JSON(http.StatusBadRequest, api.HTTPError{Error: err.Error(), Message: "'id' needs to be a uuid"})
+	}
+
+	event, err := db.GetDeadLetterEvent(ctx, id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, api.HTTPError{Error: err.Error(), Message: "failed to get dead event"})
+	} else if event == nil {
+		return c.JSON(http.StatusNotFound, api.HTTPError{Message: fmt.Sprintf("dead event(id=%s) not found", id)})
+	}
+
+	return c.JSON(http.StatusOK, event)
+}
+
+// RequeueDeadLetterEvents moves the dead events matching the request body back to the event_queue.
+// When an "id" param is present, only that event is requeued.
+func RequeueDeadLetterEvents(c echo.Context) error {
+	ctx := c.(*api.Context)
+
+	query, err := bindDeadLetterEventQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, api.HTTPError{Error: err.Error(), Message: "invalid request"})
+	}
+
+	count, err := db.RequeueDeadLetterEvents(ctx, query)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, api.HTTPError{Error: err.Error(), Message: "failed to requeue dead events"})
+	}
+
+	return c.JSON(http.StatusOK, api.HTTPSuccess{Message: fmt.Sprintf("requeued %d events", count), Payload: count})
+}
+
+// PurgeDeadLetterEvents deletes the dead events matching the request body.
+// When an "id" param is present, only that event is deleted.
+func PurgeDeadLetterEvents(c echo.Context) error {
+	ctx := c.(*api.Context)
+
+	query, err := bindDeadLetterEventQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, api.HTTPError{Error: err.Error(), Message: "invalid request"})
+	}
+
+	count, err := db.PurgeDeadLetterEvents(ctx, query)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, api.HTTPError{Error: err.Error(), Message: "failed to purge dead events"})
+	}
+
+	return c.JSON(http.StatusOK, api.HTTPSuccess{Message: fmt.Sprintf("purged %d events", count), Payload: count})
+}
+
+func bindDeadLetterEventQuery(c echo.Context) (api.DeadLetterEventQuery, error) {
+	var query api.DeadLetterEventQuery
+	if idRaw := c.Param("id"); idRaw != "" {
+		id, err := uuid.Parse(idRaw)
+		if err != nil {
+			return query, fmt.Errorf("invalid id %q: %w", idRaw, err)
+		}
+		query.IDs = []uuid.UUID{id}
+		return query, nil
+	}
+
+	if err := c.Bind(&query); err != nil {
+		return query, err
+	}
+
+	// Guard against requeuing or purging the entire queue by accident
+	if query.IsEmpty() {
+		return query, fmt.Errorf("at least one of ids, name or error is required")
+	}
+
+	return query, nil
+}
+
+// PreviewNotificationHandler renders the notification of the request body
+// for a sample event and optionally sends it.
+func PreviewNotificationHandler(c echo.Context) error {
+	ctx := c.(*api.Context)
+
+	var request NotificationPreviewRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, api.HTTPError{Error: err.Error(), Message: "invalid request"})
+	}
+	if request.EventName == "" || request.ResourceID == "" {
+		return c.JSON(http.StatusBadRequest, api.HTTPError{Message: "'event_name' and 'resource_id' are required"})
+	}
+	if request.Notification == nil {
+		if _, err := uuid.Parse(request.NotificationID); err != nil {
+			return c.JSON(http.StatusBadRequest, api.HTTPError{Error: err.Error(), Message: "'notification_id' needs to be a uuid when no inline 'notification' is given"})
+		}
+	}
+
+	result, err := PreviewNotification(ctx, request)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, api.HTTPError{Error: err.Error(), Message: "failed to preview the notification"})
+	} else if result == nil {
+		return c.JSON(http.StatusNotFound, api.HTTPError{Message: fmt.Sprintf("notification(id=%s) not found", request.NotificationID)})
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
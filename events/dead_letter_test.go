@@ -0,0 +1,119 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	ginkgo "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+)
+
+var _ = ginkgo.Describe("Dead letter queue", ginkgo.Ordered, func() {
+	const eventName = "test.dead_letter"
+
+	var (
+		event    api.Event
+		consumer EventConsumer
+	)
+
+	ginkgo.BeforeAll(func() {
+		event = api.Event{ID: uuid.New(), Name: eventName, Properties: map[string]string{"id": uuid.NewString()}}
+		Expect(agentDB.Create(&event).Error).NotTo(HaveOccurred())
+
+		consumer = EventConsumer{
+			WatchEvents: []string{eventName},
+			ProcessBatchFunc: func(ctx *api.Context, events []api.Event) []*api.Event {
+				var failedEvents []*api.Event
+				for _, e := range events {
+					e.Error = errors.New("jira is down").Error()
+					failedEvents = append(failedEvents, &e)
+				}
+				return failedEvents
+			},
+			BatchSize: 1,
+			Consumers: 1,
+			DB:        agentDB,
+		}
+	})
+
+	ginkgo.It("should move the event to the dead letter queue once it exhausts all attempts", func() {
+		consumer.ConsumeEventsUntilEmpty(context.Background())
+
+		var pending int64
+		Expect(agentDB.Model(&api.Event{}).Where("name = ?", eventName).Count(&pending).Error).NotTo(HaveOccurred())
+		Expect(pending).To(BeZero())
+
+		dead, err := db.GetDeadLetterEvent(api.NewContext(agentDB, nil), event.ID.String())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dead).NotTo(BeNil())
+		Expect(dead.Attempts).To(Equal(eventMaxAttempts + 1))
+		Expect(dead.Error).To(Equal("jira is down"))
+	})
+
+	ginkgo.It("should requeue the dead event", func() {
+		count, err := db.RequeueDeadLetterEvents(api.NewContext(agentDB, nil), api.DeadLetterEventQuery{Name: eventName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(int64(1)))
+
+		var requeued api.Event
+		Expect(agentDB.Where("id = ?", event.ID).First(&requeued).Error).NotTo(HaveOccurred())
+		Expect(requeued.Attempts).To(BeZero())
+	})
+
+	ginkgo.It("should purge the dead event", func() {
+		consumer.ConsumeEventsUntilEmpty(context.Background())
+
+		count, err := db.PurgeDeadLetterEvents(api.NewContext(agentDB, nil), api.DeadLetterEventQuery{Error: "JIRA IS"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(int64(1)))
+
+		events, err := db.ListDeadLetterEvents(api.NewContext(agentDB, nil), api.DeadLetterEventQuery{Name: eventName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(BeEmpty())
+	})
+
+	ginkgo.It("should requeue an event that was dead lettered while backing off", func() {
+		backoffConsumer := consumer
+		backoffConsumer.Backoff = Backoff{Base: time.Hour}
+
+		retried := api.Event{ID: uuid.New(), Name: eventName, Properties: map[string]string{"id": uuid.NewString()}}
+		Expect(agentDB.Create(&retried).Error).NotTo(HaveOccurred())
+
+		// The failed event backs off for an hour
+		backoffConsumer.ConsumeEventsUntilEmpty(context.Background())
+		var retries int64
+		Expect(agentDB.Model(&api.EventRetry{}).Where("event_id = ?", retried.ID).Count(&retries).Error).NotTo(HaveOccurred())
+		Expect(retries).To(Equal(int64(1)))
+
+		Expect(agentDB.Model(&api.Event{}).Where("id = ?", retried.ID).Update("attempts", eventMaxAttempts+1).Error).NotTo(HaveOccurred())
+		count, err := db.DeadLetterExhaustedEvents(api.NewContext(agentDB, nil), eventMaxAttempts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(int64(1)))
+		Expect(agentDB.Model(&api.EventRetry{}).Where("event_id = ?", retried.ID).Count(&retries).Error).NotTo(HaveOccurred())
+		Expect(retries).To(BeZero())
+
+		// A stale backoff of the event doesn't delay its requeued attempts
+		stale := api.EventRetry{EventID: retried.ID, NextAttemptAt: time.Now().Add(time.Hour)}
+		Expect(agentDB.Create(&stale).Error).NotTo(HaveOccurred())
+		_, err = db.RequeueDeadLetterEvents(api.NewContext(agentDB, nil), api.DeadLetterEventQuery{IDs: []uuid.UUID{retried.ID}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(agentDB.Model(&api.EventRetry{}).Where("event_id = ?", retried.ID).Count(&retries).Error).NotTo(HaveOccurred())
+		Expect(retries).To(BeZero())
+
+		var processed []uuid.UUID
+		succeeding := consumer
+		succeeding.ProcessBatchFunc = func(ctx *api.Context, events []api.Event) []*api.Event {
+			for _, e := range events {
+				processed = append(processed, e.ID)
+			}
+			return nil
+		}
+		succeeding.ConsumeEventsUntilEmpty(context.Background())
+		Expect(processed).To(ContainElement(retried.ID))
+	})
+})
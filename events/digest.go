@@ -0,0 +1,152 @@
+package events
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/flanksource/incident-commander/api"
+	pkgNotification "github.com/flanksource/incident-commander/notification"
+)
+
+// digestEventName is the event name the digests are recorded with in the notification sends.
+const digestEventName = "notification.digest"
+
+// bufferNotificationDigest buffers the notification until the next digest to its recipient.
+func bufferNotificationDigest(ctx *api.Context, send api.NotificationSend, props NotificationEventProperties) error {
+	entry := api.NotificationDigestEntry{
+		NotificationID:   send.NotificationID,
+		Recipient:        send.Recipient,
+		PersonID:         send.PersonID,
+		TeamID:           send.TeamID,
+		NotificationName: props.NotificationName,
+		EventName:        send.EventName,
+		ResourceID:       send.ResourceID,
+		IncidentID:       send.IncidentID,
+		Message:          send.Message,
+	}
+	if err := ctx.DB().Create(&entry).Error; err != nil {
+		return fmt.Errorf("error buffering notification for the digest: %w", err)
+	}
+	return nil
+}
+
+type pendingDigest struct {
+	NotificationID uuid.UUID
+	Recipient      string
+	Since          time.Time
+}
+
+// SendNotificationDigests sends the digests of the buffered notifications whose schedule is due.
+// The notifications buffered before their digest was turned off are sent right away.
+func SendNotificationDigests(ctx *api.Context, now time.Time) error {
+	var pending []pendingDigest
+	if err := ctx.DB().Model(&api.NotificationDigestEntry{}).
+		Select("notification_id, recipient, MIN(created_at) AS since").
+		Group("notification_id, recipient").
+		Scan(&pending).Error; err != nil {
+		return err
+	}
+
+	for _, p := range pending {
+		notification, err := pkgNotification.GetNotification(ctx, p.NotificationID.String())
+		if err != nil {
+			return err
+		}
+
+		if notification.ID == uuid.Nil || notification.DeletedAt != nil {
+			if err := ctx.DB().Where("notification_id = ?", p.NotificationID).Delete(&api.NotificationDigestEntry{}).Error; err != nil {
+				logger.Errorf("error deleting the digest of deleted notification %s: %v", p.NotificationID, err)
+			}
+			continue
+		}
+
+		if notification.Settings.IsDigest() {
+			due, err := pkgNotification.DigestDue(notification.Settings.DigestSchedule, p.Since, now)
+			if err != nil {
+				logger.Errorf("error scheduling the digest of notification %s: %v", p.NotificationID, err)
+				continue
+			} else if !due {
+				continue
+			}
+		}
+
+		if err := sendNotificationDigest(ctx, notification, p.Recipient, now); err != nil {
+			logger.Errorf("error sending the digest of notification %s to %s: %v", p.NotificationID, p.Recipient, err)
+		}
+	}
+
+	return nil
+}
+
+// sendNotificationDigest sends the notifications buffered for the recipient as one message.
+// They stay buffered if the digest fails to be sent, and are retried on the next run.
+func sendNotificationDigest(ctx *api.Context, notification *pkgNotification.NotificationWithSpec, recipient string, now time.Time) error {
+	return ctx.DB().Transaction(func(tx *gorm.DB) error {
+		var entries []api.NotificationDigestEntry
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("notification_id = ? AND recipient = ? AND created_at <= ?", notification.ID, recipient, now).
+			Order("created_at").
+			Find(&entries).Error; err != nil {
+			return err
+		} else if len(entries) == 0 {
+			// Being sent by another instance
+			return nil
+		}
+
+		ids := make([]uuid.UUID, 0, len(entries))
+		for _, entry := range entries {
+			ids = append(ids, entry.ID)
+		}
+
+		props := NotificationEventProperties{
+			EventName:        digestEventName,
+			NotificationID:   notification.ID.String(),
+			NotificationName: entries[0].NotificationName,
+		}
+		if entries[0].PersonID != nil {
+			props.PersonID = entries[0].PersonID.String()
+		}
+		if entries[0].TeamID != nil {
+			props.TeamID = entries[0].TeamID.String()
+		}
+
+		data := NotificationTemplate{
+			Message:    pkgNotification.DefaultDigestTemplate,
+			Properties: notification.Properties,
+		}
+		if notification.Settings != nil && notification.Settings.DigestTemplate != "" {
+			data.Message = notification.Settings.DigestTemplate
+		}
+
+		templater := newNotificationTemplater(pkgNotification.DigestEnv(entries))
+		send := newNotificationSend(notification.ID, props, nil)
+		if err := templater.Walk(&data); err != nil {
+			err = fmt.Errorf("error templating notification digest: %w", err)
+			send.Status, send.Response = api.NotificationSendFailed, err.Error()
+			persistNotificationSend(ctx, send)
+			return err
+		}
+		send.Message = data.Message
+
+		service, err := deliverNotification(ctx, props, notification, templater, data)
+		send.Service = service
+		if err != nil {
+			send.Status, send.Response = api.NotificationSendFailed, err.Error()
+			persistNotificationSend(ctx, send)
+			return err
+		} else if service == "" {
+			// The recipient was removed since the notifications were buffered
+			send.Status, send.Response = api.NotificationSendFailed, "recipient not found"
+		} else {
+			send.Status = api.NotificationSendSent
+		}
+		persistNotificationSend(ctx, send)
+
+		return tx.Where("id IN ?", ids).Delete(&api.NotificationDigestEntry{}).Error
+	})
+}
@@ -12,16 +12,22 @@ import (
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/incident-commander/api"
 	"github.com/flanksource/incident-commander/db"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type EventConsumer struct {
+	// Name identifies the consumer in metrics
+	Name        string
 	WatchEvents []string
 	// We process mutliple events and return the failed events
 	ProcessBatchFunc func(*api.Context, []api.Event) []*api.Event
 	BatchSize        int
 	Consumers        int
 	DB               *gorm.DB
+	// Backoff schedules the next attempt of the failed events
+	Backoff Backoff
 }
 
 func (e EventConsumer) Validate() error {
@@ -51,7 +57,11 @@ func (t *EventConsumer) consumeEvents() error {
             SELECT id FROM event_queue
             WHERE 
                 attempts <= @maxAttempts AND
-                name IN @events
+                name IN @events AND
+                NOT EXISTS (
+                    SELECT 1 FROM event_queue_retries
+                    WHERE event_id = event_queue.id AND next_attempt_at > NOW()
+                )
             ORDER BY priority DESC, created_at ASC
             FOR UPDATE SKIP LOCKED
             LIMIT @batchSize
@@ -80,35 +90,82 @@ func (t *EventConsumer) consumeEvents() error {
 		return gorm.ErrRecordNotFound
 	}
 
+	ids := make([]uuid.UUID, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	if err := tx.Where("event_id IN ?", ids).Delete(&api.EventRetry{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error deleting the retries of the events: %w", err)
+	}
+
+	start := time.Now()
 	failedEvents := t.ProcessBatchFunc(ctx, events)
+	batchDuration.WithLabelValues(t.Name).Observe(time.Since(start).Seconds())
+	for _, e := range events {
+		eventsProcessed.WithLabelValues(e.Name).Inc()
+	}
+
+	var retryEvents []*api.Event
+	var retries []api.EventRetry
+	var deadEvents []api.DeadLetterEvent
 	for _, e := range failedEvents {
 		e.Attempts += 1
 		last_attempt := time.Now()
 		e.LastAttempt = &last_attempt
 		logger.Errorf("Failed to process event[%s]: %s", e.ID, e.Error)
+		eventsFailed.WithLabelValues(e.Name).Inc()
+
+		if e.Attempts > eventMaxAttempts {
+			logger.Warnf("Event[%s] exhausted all %d attempts. Moving it to the dead letter queue", e.ID, eventMaxAttempts)
+			deadEvents = append(deadEvents, api.NewDeadLetterEvent(*e))
+			eventsDeadLettered.WithLabelValues(e.Name).Inc()
+		} else {
+			retryEvents = append(retryEvents, e)
+			if next := t.Backoff.NextAttempt(e.Attempts); next != nil {
+				retries = append(retries, api.EventRetry{EventID: e.ID, NextAttemptAt: *next})
+			}
+		}
 	}
 
-	if len(failedEvents) > 0 {
-		if err := tx.Create(failedEvents).Error; err != nil {
+	if len(retryEvents) > 0 {
+		if err := tx.Create(retryEvents).Error; err != nil {
 			// TODO: More robust way to handle failed event insertion failures
 			logger.Errorf("Error inserting into table:event_queue with error:%v. %v", err)
 		}
 	}
+
+	if len(retries) > 0 {
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(retries).Error; err != nil {
+			logger.Errorf("Error inserting into table:event_queue_retries with error: %v", err)
+		}
+	}
+
+	if len(deadEvents) > 0 {
+		if err := tx.Create(deadEvents).Error; err != nil {
+			logger.Errorf("Error inserting into table:event_queue_dead_letter with error: %v", err)
+		}
+	}
 	return tx.Commit().Error
 }
 
-// ConsumeEventsUntilEmpty consumes events forever until the event queue is empty.
-func (t *EventConsumer) ConsumeEventsUntilEmpty() {
+// ConsumeEventsUntilEmpty consumes events until the event queue is empty
+// or the context is done.
+// A batch that's already being processed is always allowed to commit or roll back.
+func (t *EventConsumer) ConsumeEventsUntilEmpty(ctx context.Context) {
 	consumerFunc := func(wg *sync.WaitGroup) {
-		for {
+		defer wg.Done()
+		for ctx.Err() == nil {
 			err := t.consumeEvents()
 			if err != nil {
 				if errors.Is(err, gorm.ErrRecordNotFound) {
-					wg.Done()
 					return
 				} else {
 					logger.Errorf("error processing event, waiting 60s to try again %v", err)
-					time.Sleep(waitDurationOnFailure)
+					select {
+					case <-ctx.Done():
+					case <-time.After(waitDurationOnFailure):
+					}
 				}
 			}
 		}
@@ -123,8 +180,8 @@ func (t *EventConsumer) ConsumeEventsUntilEmpty() {
 }
 
 // listenToPostgresNotify listens to postgres notifications
-// and will retry on failure for dbReconnectMaxAttempt times
-func (e *EventConsumer) listenToPostgresNotify(pgNotify chan bool) {
+// and will retry on failure until the context is done.
+func (e *EventConsumer) listenToPostgresNotify(ctx context.Context, pgNotify chan bool) {
 	var listen = func(ctx context.Context, pgNotify chan bool) error {
 		conn, err := db.Pool.Acquire(ctx)
 		if err != nil {
@@ -144,26 +201,37 @@ func (e *EventConsumer) listenToPostgresNotify(pgNotify chan bool) {
 				return fmt.Errorf("error listening to database notifications: %v", err)
 			}
 
-			pgNotify <- true
+			select {
+			case pgNotify <- true:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 		}
 	}
 
 	// retry on failure.
-	for {
+	for ctx.Err() == nil {
 		backoff := retry.WithMaxDuration(dbReconnectMaxDuration, retry.NewExponential(dbReconnectBackoffBaseDuration))
-		err := retry.Do(context.TODO(), backoff, func(ctx context.Context) error {
+		err := retry.Do(ctx, backoff, func(ctx context.Context) error {
 			if err := listen(ctx, pgNotify); err != nil {
+				if ctx.Err() != nil {
+					return err
+				}
 				return retry.RetryableError(err)
 			}
 
 			return nil
 		})
 
-		logger.Errorf("failed to connect to database: %v", err)
+		if ctx.Err() == nil {
+			logger.Errorf("failed to connect to database: %v", err)
+		}
 	}
 }
 
-func (e *EventConsumer) Listen() {
+// Listen consumes the events as they are added to the event queue
+// until the context is done.
+func (e *EventConsumer) Listen(ctx context.Context) {
 	logger.Infof("Started listening for database notify events: %v", e.WatchEvents)
 
 	if err := e.Validate(); err != nil {
@@ -172,18 +240,22 @@ func (e *EventConsumer) Listen() {
 	}
 
 	// Consume pending events
-	e.ConsumeEventsUntilEmpty()
+	e.ConsumeEventsUntilEmpty(ctx)
 
 	pgNotify := make(chan bool)
-	go e.listenToPostgresNotify(pgNotify)
+	go e.listenToPostgresNotify(ctx, pgNotify)
 
 	for {
 		select {
+		case <-ctx.Done():
+			logger.Infof("Stopped listening for database notify events: %v", e.WatchEvents)
+			return
+
 		case <-pgNotify:
-			e.ConsumeEventsUntilEmpty()
+			e.ConsumeEventsUntilEmpty(ctx)
 
 		case <-time.After(pgNotifyTimeout):
-			e.ConsumeEventsUntilEmpty()
+			e.ConsumeEventsUntilEmpty(ctx)
 		}
 	}
 }
@@ -1,10 +1,15 @@
 package events
 
 import (
+	"context"
+	"sync"
 	"time"
 
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/duty/upstream"
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+	"github.com/flanksource/incident-commander/responder"
 	"gorm.io/gorm"
 
 	"github.com/flanksource/commons/collections/set"
@@ -21,10 +26,16 @@ const (
 	EventCheckPassed = "check.passed"
 	EventCheckFailed = "check.failed"
 
+	EventComponentStatusUpdated = "component.status.updated"
+	EventCheckStatusFailed      = "check.status.failed"
+	EventConfigAnalysisUpdated  = "config_analysis.updated"
+
 	EventIncidentCreated             = "incident.created"
 	EventIncidentResponderAdded      = "incident.responder.added"
 	EventIncidentResponderRemoved    = "incident.responder.removed"
 	EventIncidentCommentAdded        = "incident.comment.added"
+	EventIncidentCommentUpdated      = "incident.comment.updated"
+	EventIncidentCommentDeleted      = "incident.comment.deleted"
 	EventIncidentDODAdded            = "incident.dod.added"
 	EventIncidentDODPassed           = "incident.dod.passed"
 	EventIncidentDODRegressed        = "incident.dod.regressed"
@@ -35,6 +46,9 @@ const (
 	EventIncidentStatusInvestigating = "incident.status.investigating"
 	EventIncidentStatusCancelled     = "incident.status.cancelled"
 
+	EventResponderStatusUpdate = "responder.status.update"
+	EventResponderWebhookSync  = responder.WebhookSyncEvent
+
 	EventPushQueueCreate = "push_queue.create"
 )
 
@@ -47,21 +61,42 @@ const (
 	dbReconnectBackoffBaseDuration = time.Second
 )
 
+// Backoffs for the consumers that talk to external systems.
+// A failing external system would otherwise exhaust all the attempts in seconds.
+var (
+	ResponderBackoff        = Backoff{Base: time.Minute, Max: time.Hour, Jitter: 0.2}
+	NotificationSendBackoff = Backoff{Base: 30 * time.Second, Max: 30 * time.Minute, Jitter: 0.2}
+	UpstreamPushBackoff     = Backoff{Base: 10 * time.Second, Max: 10 * time.Minute, Jitter: 0.2}
+)
+
 type Config struct {
 	UpstreamPush upstream.UpstreamConfig
 }
 
-func StartConsumers(gormDB *gorm.DB, config Config) {
+// StartConsumers starts all the event consumers. The consumers stop picking
+// new events once the context is done. The returned WaitGroup is done once
+// all the consumers have finished their in-flight batches.
+func StartConsumers(ctx context.Context, gormDB *gorm.DB, config Config) *sync.WaitGroup {
+	// Events that exhausted their attempts before the dead letter queue existed
+	// would otherwise sit in the event_queue forever.
+	if count, err := db.DeadLetterExhaustedEvents(api.NewContext(gormDB, nil), eventMaxAttempts); err != nil {
+		logger.Errorf("Error moving exhausted events to the dead letter queue: %v", err)
+	} else if count > 0 {
+		logger.Infof("Moved %d exhausted events to the dead letter queue", count)
+	}
+
 	allConsumers := []EventConsumer{
 		NewTeamConsumer(gormDB),
 		NewNotificationConsumer(gormDB),
 		NewNotificationSendConsumer(gormDB),
 		NewResponderConsumer(gormDB),
+		NewIncidentRuleConsumer(gormDB),
 	}
 	if config.UpstreamPush.Valid() {
 		allConsumers = append(allConsumers, NewUpstreamPushConsumer(gormDB, config))
 	}
 
+	var wg sync.WaitGroup
 	uniqWatchEvents := set.New[string]()
 	for i, c := range allConsumers {
 		for _, we := range c.WatchEvents {
@@ -70,6 +105,13 @@ func StartConsumers(gormDB *gorm.DB, config Config) {
 			}
 		}
 		uniqWatchEvents.Add(c.WatchEvents...)
-		go allConsumers[i].Listen()
+		wg.Add(1)
+		go func(consumer EventConsumer) {
+			defer wg.Done()
+			consumer.Listen(ctx)
+		}(allConsumers[i])
 	}
+
+	go monitorQueue(ctx, gormDB)
+	return &wg
 }
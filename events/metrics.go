@@ -0,0 +1,105 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+const (
+	metricsNamespace = "mission_control"
+	metricsSubsystem = "event_queue"
+
+	queueMetricsInterval = 30 * time.Second
+)
+
+var (
+	batchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "batch_duration_seconds",
+		Help:      "Time taken by a consumer to process a batch of events",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"consumer"})
+
+	eventsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "events_processed_total",
+		Help:      "Number of events processed, including the ones that failed",
+	}, []string{"name"})
+
+	eventsFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "events_failed_total",
+		Help:      "Number of events that failed to be processed",
+	}, []string{"name"})
+
+	eventsDeadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "events_dead_lettered_total",
+		Help:      "Number of events moved to the dead letter queue after exhausting all attempts",
+	}, []string{"name"})
+
+	queueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "depth",
+		Help:      "Number of pending events in the event queue",
+	}, []string{"name"})
+
+	oldestPendingAge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "oldest_pending_age_seconds",
+		Help:      "Age of the oldest pending event in the event queue",
+	}, []string{"name"})
+)
+
+// updateQueueMetrics refreshes the queue depth & the age of the oldest pending event per event name.
+func updateQueueMetrics(gormDB *gorm.DB) error {
+	var rows []struct {
+		Name      string
+		Depth     int
+		OldestAge float64
+	}
+	err := gormDB.Raw(`
+        SELECT name, COUNT(*) AS depth, EXTRACT(EPOCH FROM NOW() - MIN(created_at)) AS oldest_age
+        FROM event_queue
+        WHERE attempts <= ?
+        GROUP BY name
+    `, eventMaxAttempts).Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	// Reset so that the event names that no longer have pending events drop to zero
+	queueDepth.Reset()
+	oldestPendingAge.Reset()
+	for _, row := range rows {
+		queueDepth.WithLabelValues(row.Name).Set(float64(row.Depth))
+		oldestPendingAge.WithLabelValues(row.Name).Set(row.OldestAge)
+	}
+
+	return nil
+}
+
+func monitorQueue(ctx context.Context, gormDB *gorm.DB) {
+	for {
+		if err := updateQueueMetrics(gormDB); err != nil {
+			logger.Errorf("error updating event queue metrics: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(queueMetricsInterval):
+		}
+	}
+}
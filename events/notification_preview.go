@@ -0,0 +1,212 @@
+package events
+
+import (
+	"fmt"
+
+	"github.com/flanksource/duty/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+	pkgNotification "github.com/flanksource/incident-commander/notification"
+	"github.com/flanksource/incident-commander/teams"
+)
+
+// NotificationPreviewRequest is the notification to preview against a sample event.
+type NotificationPreviewRequest struct {
+	// ID of an existing notification. Either this or the inline notification is required.
+	NotificationID string               `json:"notification_id,omitempty"`
+	Notification   *NotificationPreview `json:"notification,omitempty"`
+	// Name & resource id of the sample event, e.g. check.failed & the id of the check
+	EventName  string `json:"event_name"`
+	ResourceID string `json:"resource_id"`
+	// Send the notification to the recipients it would be sent to.
+	// Test sends bypass the silences & the throttling and aren't recorded.
+	Send bool `json:"send,omitempty"`
+}
+
+// NotificationPreview is an unsaved notification.
+type NotificationPreview struct {
+	Template       string                   `json:"template"`
+	Filter         string                   `json:"filter,omitempty"`
+	Properties     map[string]string        `json:"properties,omitempty"`
+	PersonID       *uuid.UUID               `json:"person_id,omitempty"`
+	TeamID         *uuid.UUID               `json:"team_id,omitempty"`
+	CustomServices []api.NotificationConfig `json:"custom_services,omitempty"`
+}
+
+// FilterResult is the outcome of a CEL filter.
+type FilterResult struct {
+	Expression string `json:"expression,omitempty"`
+	Passed     bool   `json:"passed"`
+	// CompileError is set when the expression isn't valid CEL
+	CompileError string `json:"compile_error,omitempty"`
+	// Error is set when the expression fails to evaluate against the event,
+	// e.g. it uses the check variable on an incident event.
+	Error string `json:"error,omitempty"`
+}
+
+// NotificationPreviewResult is what the notification would send for the sample event.
+type NotificationPreviewResult struct {
+	Filter        FilterResult                   `json:"filter"`
+	Message       string                         `json:"message,omitempty"`
+	Properties    map[string]string              `json:"properties,omitempty"`
+	TemplateError string                         `json:"template_error,omitempty"`
+	Recipients    []NotificationPreviewRecipient `json:"recipients"`
+}
+
+// NotificationPreviewRecipient is a recipient of the notification
+// and the shoutrrr service it's sent with.
+type NotificationPreviewRecipient struct {
+	Recipient string `json:"recipient"`
+	// Filter of the team or the custom service notification
+	Filter  *FilterResult `json:"filter,omitempty"`
+	Service string        `json:"service,omitempty"`
+	// Error resolving the service or sending the notification
+	Error string `json:"error,omitempty"`
+	Sent  bool   `json:"sent"`
+}
+
+// PreviewNotification renders the notification for the sample event
+// and resolves its recipients. The notification is only sent when requested
+// and when all its filters pass. It returns nil if the existing notification isn't found.
+func PreviewNotification(ctx *api.Context, request NotificationPreviewRequest) (*NotificationPreviewResult, error) {
+	notification, err := getPreviewNotification(ctx, request)
+	if err != nil || notification == nil {
+		return nil, err
+	}
+
+	celEnv, err := getEnvForEvent(ctx, request.EventName, map[string]string{"id": request.ResourceID})
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing's written with a dry run session, e.g. the job history of failing filters
+	dryRunCtx := api.NewContext(db.Gorm.Session(&gorm.Session{DryRun: true}), nil)
+	dryRunCtx.Context = ctx.Context
+
+	expressionRunner := pkgNotification.ExpressionRunner{
+		ResourceID:   request.NotificationID,
+		ResourceType: "notification",
+		CelEnv:       celEnv,
+	}
+
+	result := NotificationPreviewResult{
+		Filter:     evalFilter(dryRunCtx, expressionRunner, notification.Filter),
+		Recipients: []NotificationPreviewRecipient{},
+	}
+
+	templater := newNotificationTemplater(celEnv)
+	data := NotificationTemplate{
+		Message:    notification.Template,
+		Properties: notification.Properties,
+	}
+	if err := templater.Walk(&data); err != nil {
+		result.TemplateError = err.Error()
+	} else {
+		result.Message, result.Properties = data.Message, data.Properties
+	}
+
+	var recipients []NotificationEventProperties
+	var filters []*FilterResult
+	addRecipient := func(prop NotificationEventProperties, filter *FilterResult) {
+		prop.EventName, prop.ID = request.EventName, request.ResourceID
+		recipients = append(recipients, prop)
+		filters = append(filters, filter)
+	}
+
+	if notification.PersonID != nil {
+		addRecipient(NotificationEventProperties{PersonID: notification.PersonID.String()}, nil)
+	}
+
+	if notification.TeamID != nil {
+		teamSpec, err := teams.GetTeamSpec(ctx, notification.TeamID.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get team(id=%s); %v", notification.TeamID, err)
+		}
+
+		for _, cn := range teamSpec.Notifications {
+			filter := evalFilter(dryRunCtx, expressionRunner, cn.Filter)
+			addRecipient(NotificationEventProperties{TeamID: notification.TeamID.String(), NotificationName: cn.Name}, &filter)
+		}
+	}
+
+	for _, cn := range notification.CustomNotifications {
+		filter := evalFilter(dryRunCtx, expressionRunner, cn.Filter)
+		addRecipient(NotificationEventProperties{NotificationName: cn.Name}, &filter)
+	}
+
+	for i, prop := range recipients {
+		recipient := NotificationPreviewRecipient{Recipient: prop.Recipient(), Filter: filters[i]}
+
+		cn, err := getRecipientConfig(ctx, prop, notification, templater)
+		if err != nil {
+			recipient.Error = err.Error()
+		} else if cn == nil {
+			recipient.Error = "recipient not found"
+		} else if recipient.Service, err = pkgNotification.ResolveService(ctx, cn.Connection, cn.URL); err != nil {
+			recipient.Error = err.Error()
+		} else if request.Send && result.Filter.Passed && result.TemplateError == "" && (recipient.Filter == nil || recipient.Filter.Passed) {
+			if _, err := pkgNotification.Send(ctx, cn.Connection, cn.URL, data.Message, data.Properties, cn.Properties); err != nil {
+				recipient.Error = err.Error()
+			} else {
+				recipient.Sent = true
+			}
+		}
+
+		result.Recipients = append(result.Recipients, recipient)
+	}
+
+	return &result, nil
+}
+
+// getPreviewNotification returns the existing or the inline notification of the request.
+// It returns nil if the existing notification isn't found.
+func getPreviewNotification(ctx *api.Context, request NotificationPreviewRequest) (*pkgNotification.NotificationWithSpec, error) {
+	if request.Notification == nil {
+		notification, err := pkgNotification.GetNotification(ctx, request.NotificationID)
+		if err != nil {
+			return nil, err
+		} else if notification.ID == uuid.Nil {
+			return nil, nil
+		}
+		return notification, nil
+	}
+
+	spec := request.Notification
+	return &pkgNotification.NotificationWithSpec{
+		Notification: models.Notification{
+			Template:   spec.Template,
+			Filter:     spec.Filter,
+			Properties: spec.Properties,
+			PersonID:   spec.PersonID,
+			TeamID:     spec.TeamID,
+		},
+		CustomNotifications: spec.CustomServices,
+	}, nil
+}
+
+// evalFilter evaluates the filter against the event, telling apart
+// the expressions that don't compile from the ones that fail to evaluate.
+func evalFilter(ctx *api.Context, expressionRunner pkgNotification.ExpressionRunner, expression string) FilterResult {
+	result := FilterResult{Expression: expression}
+	if expression == "" {
+		result.Passed = true
+		return result
+	}
+
+	if _, err := expressionRunner.GetOrCompileCELProgram(ctx, expression); err != nil {
+		result.CompileError = err.Error()
+		return result
+	}
+
+	passed, err := expressionRunner.Eval(ctx, expression)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Passed = passed
+	return result
+}
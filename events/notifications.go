@@ -6,11 +6,14 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/commons/template"
 	"github.com/flanksource/duty/models"
 	"github.com/flanksource/incident-commander/api"
 	pkgNotification "github.com/flanksource/incident-commander/notification"
+	"github.com/flanksource/incident-commander/silence"
 	"github.com/flanksource/incident-commander/teams"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -18,6 +21,7 @@ import (
 
 func NewNotificationConsumer(db *gorm.DB) EventConsumer {
 	return EventConsumer{
+		Name: "notification",
 		WatchEvents: []string{
 			EventNotificationUpdate, EventNotificationDelete,
 			EventIncidentCreated,
@@ -36,11 +40,13 @@ func NewNotificationConsumer(db *gorm.DB) EventConsumer {
 
 func NewNotificationSendConsumer(db *gorm.DB) EventConsumer {
 	return EventConsumer{
+		Name:             "notification_send",
 		WatchEvents:      []string{EventNotificationSend},
 		ProcessBatchFunc: processNotificationEvents,
 		BatchSize:        1,
 		Consumers:        5,
 		DB:               db,
+		Backoff:          NotificationSendBackoff,
 	}
 }
 
@@ -101,6 +107,17 @@ func (t *NotificationEventProperties) FromMap(m map[string]string) {
 	_ = json.Unmarshal(b, &t)
 }
 
+// Recipient identifies the person, team notification or custom service notified.
+func (t *NotificationEventProperties) Recipient() string {
+	if t.PersonID != "" {
+		return "person:" + t.PersonID
+	}
+	if t.TeamID != "" {
+		return "team:" + t.TeamID + "/" + t.NotificationName
+	}
+	return t.NotificationName
+}
+
 func sendNotification(ctx *api.Context, event api.Event) error {
 	var props NotificationEventProperties
 	props.FromMap(event.Properties)
@@ -110,14 +127,7 @@ func sendNotification(ctx *api.Context, event api.Event) error {
 		return err
 	}
 
-	templater := template.StructTemplater{
-		Values:         celEnv,
-		ValueFunctions: true,
-		DelimSets: []template.Delims{
-			{Left: "{{", Right: "}}"},
-			{Left: "$(", Right: ")"},
-		},
-	}
+	templater := newNotificationTemplater(celEnv)
 
 	notification, err := pkgNotification.GetNotification(ctx, props.NotificationID)
 	if err != nil {
@@ -129,14 +139,139 @@ func sendNotification(ctx *api.Context, event api.Event) error {
 		Properties: notification.Properties,
 	}
 
+	send := newNotificationSend(notification.ID, props, celEnv)
 	if err := templater.Walk(&data); err != nil {
-		return fmt.Errorf("error templating notification: %w", err)
+		err = fmt.Errorf("error templating notification: %w", err)
+		send.Status, send.Response = api.NotificationSendFailed, err.Error()
+		persistNotificationSend(ctx, send)
+		return err
+	}
+	send.Message = data.Message
+
+	// Digests aren't throttled as they're sent on their schedule
+	if notification.Settings.IsDigest() {
+		if err := bufferNotificationDigest(ctx, send, props); err != nil {
+			return err
+		}
+		send.Status = api.NotificationSendDigested
+		persistNotificationSend(ctx, send)
+		return nil
+	}
+
+	var groupBy []string
+	if notification.Settings != nil {
+		groupBy = notification.Settings.GroupBy
+	}
+	key := pkgNotification.SendKey{
+		NotificationID: notification.ID,
+		Recipient:      props.Recipient(),
+		GroupKey:       pkgNotification.GroupKey(groupBy, celEnv, props.ID),
 	}
+	claim, err := pkgNotification.ClaimSend(ctx, notification.Settings, key, time.Now())
+	if err != nil {
+		return fmt.Errorf("error throttling notification: %w", err)
+	} else if claim == nil {
+		logger.Debugf("Suppressed notification %s of %s to %s", props.NotificationID, props.EventName, key.Recipient)
+		send.Status = api.NotificationSendSuppressed
+		persistNotificationSend(ctx, send)
+		return nil
+	}
+	data.Message = claim.Summary(data.Message)
+	send.Message = data.Message
+
+	service, err := deliverNotification(ctx, props, notification, templater, data)
+	send.Service = service
+	if err == nil && service == "" {
+		// The recipient was removed since the notification was queued
+		// The claim isn't kept for a recipient that wasn't sent anything
+		if err := claim.Release(ctx); err != nil {
+			logger.Errorf("error releasing the throttling claim of notification %s: %v", props.NotificationID, err)
+		}
+		send.Status, send.Response = api.NotificationSendFailed, "recipient not found"
+		persistNotificationSend(ctx, send)
+		return nil
+	} else if err != nil {
+		if err := claim.Release(ctx); err != nil {
+			logger.Errorf("error releasing the throttling claim of notification %s: %v", props.NotificationID, err)
+		}
+		send.Status, send.Response = api.NotificationSendFailed, err.Error()
+		persistNotificationSend(ctx, send)
+		return err
+	}
+
+	send.Status = api.NotificationSendSent
+	persistNotificationSend(ctx, send)
+	return nil
+}
+
+// newNotificationTemplater returns the templater of the notification messages & configs
+// of the event with the given environment.
+func newNotificationTemplater(celEnv map[string]any) template.StructTemplater {
+	return template.StructTemplater{
+		Values:         celEnv,
+		ValueFunctions: true,
+		DelimSets: []template.Delims{
+			{Left: "{{", Right: "}}"},
+			{Left: "$(", Right: ")"},
+		},
+	}
+}
+
+// newNotificationSend returns the record of the attempt to send the notification to its recipient.
+func newNotificationSend(notificationID uuid.UUID, props NotificationEventProperties, celEnv map[string]any) api.NotificationSend {
+	send := api.NotificationSend{
+		NotificationID: notificationID,
+		Recipient:      props.Recipient(),
+		EventName:      props.EventName,
+		ResourceID:     props.ID,
+	}
+	if id, err := uuid.Parse(props.PersonID); err == nil {
+		send.PersonID = &id
+	}
+	if id, err := uuid.Parse(props.TeamID); err == nil {
+		send.TeamID = &id
+	}
+	if incident, ok := celEnv["incident"].(map[string]any); ok {
+		if id, err := uuid.Parse(fmt.Sprint(incident["id"])); err == nil {
+			send.IncidentID = &id
+		}
+	}
+	return send
+}
+
+// persistNotificationSend records the send. The notification has already been
+// sent or not, so failing to record it only gets logged.
+func persistNotificationSend(ctx *api.Context, send api.NotificationSend) {
+	// Saved in a nested transaction so that a failure doesn't abort the consumer's batch
+	err := ctx.DB().Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&send).Error
+	})
+	if err != nil {
+		logger.Errorf("error recording the send of notification %s to %s: %v", send.NotificationID, send.Recipient, err)
+	}
+}
 
+// deliverNotification sends the templated notification to its recipient
+// and returns the name of the service it was sent with.
+func deliverNotification(ctx *api.Context, props NotificationEventProperties, notification *pkgNotification.NotificationWithSpec, templater template.StructTemplater, data NotificationTemplate) (string, error) {
+	cn, err := getRecipientConfig(ctx, props, notification, templater)
+	if err != nil {
+		return "", err
+	} else if cn == nil {
+		return "", nil
+	}
+
+	return pkgNotification.Send(ctx, cn.Connection, cn.URL, data.Message, data.Properties, cn.Properties)
+}
+
+// getRecipientConfig returns the templated config the notification is sent to its recipient with.
+// A person is sent an email with the SMTP settings of the environment.
+// It returns nil if the recipient doesn't exist anymore.
+func getRecipientConfig(ctx *api.Context, props NotificationEventProperties, notification *pkgNotification.NotificationWithSpec, templater template.StructTemplater) (*api.NotificationConfig, error) {
 	if props.PersonID != "" {
 		var emailAddress string
 		if err := ctx.DB().Model(&models.Person{}).Select("email").Where("id = ?", props.PersonID).Find(&emailAddress).Error; err != nil {
-			return fmt.Errorf("failed to get email of person(id=%s); %v", props.PersonID, err)
+			return nil, fmt.Errorf("failed to get email of person(id=%s); %v", props.PersonID, err)
 		}
 
 		smtpURL := fmt.Sprintf("smtp://%s:%s@%s:%s/?auth=Plain&FromAddress=%s&ToAddresses=%s",
@@ -147,13 +282,13 @@ func sendNotification(ctx *api.Context, event api.Event) error {
 			url.QueryEscape(os.Getenv("SMTP_USER")),
 			url.QueryEscape(emailAddress),
 		)
-		return pkgNotification.Send(ctx, "", smtpURL, data.Message, data.Properties)
+		return &api.NotificationConfig{URL: smtpURL}, nil
 	}
 
 	if props.TeamID != "" {
 		teamSpec, err := teams.GetTeamSpec(ctx, props.TeamID)
 		if err != nil {
-			return fmt.Errorf("failed to get team(id=%s); %v", props.TeamID, err)
+			return nil, fmt.Errorf("failed to get team(id=%s); %v", props.TeamID, err)
 		}
 
 		for _, cn := range teamSpec.Notifications {
@@ -162,10 +297,9 @@ func sendNotification(ctx *api.Context, event api.Event) error {
 			}
 
 			if err := templater.Walk(&cn); err != nil {
-				return fmt.Errorf("error templating notification: %w", err)
+				return nil, fmt.Errorf("error templating notification: %w", err)
 			}
-
-			return pkgNotification.Send(ctx, cn.Connection, cn.URL, data.Message, data.Properties, cn.Properties)
+			return &cn, nil
 		}
 	}
 
@@ -175,13 +309,12 @@ func sendNotification(ctx *api.Context, event api.Event) error {
 		}
 
 		if err := templater.Walk(&cn); err != nil {
-			return fmt.Errorf("error templating notification: %w", err)
+			return nil, fmt.Errorf("error templating notification: %w", err)
 		}
-
-		return pkgNotification.Send(ctx, cn.Connection, cn.URL, data.Message, data.Properties, cn.Properties)
+		return &cn, nil
 	}
 
-	return nil
+	return nil, nil
 }
 
 // addNotificationEvent responds to a event that can possible generate a notification.
@@ -202,6 +335,17 @@ func addNotificationEvent(ctx *api.Context, event api.Event) error {
 		return err
 	}
 
+	silences, err := silence.Active(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	var target silence.Target
+	if len(silences) > 0 {
+		if target, err = getSilenceTarget(ctx, event.Name, celEnv); err != nil {
+			return err
+		}
+	}
+
 	for _, id := range notificationIDs {
 		n, err := pkgNotification.GetNotification(ctx, id)
 		if err != nil {
@@ -234,13 +378,15 @@ func addNotificationEvent(ctx *api.Context, event api.Event) error {
 				PersonID:       n.PersonID.String(),
 			}
 
-			newEvent := api.Event{
-				ID:         uuid.New(),
-				Name:       EventNotificationSend,
-				Properties: prop.AsMap(),
-			}
-			if err := ctx.DB().Create(newEvent).Error; err != nil {
-				return fmt.Errorf("failed to create notification event for person(id=%s): %v", n.PersonID, err)
+			if !isSilenced(ctx, silences, target, prop) {
+				newEvent := api.Event{
+					ID:         uuid.New(),
+					Name:       EventNotificationSend,
+					Properties: prop.AsMap(),
+				}
+				if err := ctx.DB().Create(newEvent).Error; err != nil {
+					return fmt.Errorf("failed to create notification event for person(id=%s): %v", n.PersonID, err)
+				}
 			}
 		}
 
@@ -256,6 +402,13 @@ func addNotificationEvent(ctx *api.Context, event api.Event) error {
 				CelEnv:       celEnv,
 			}
 
+			var teamName string
+			if err := ctx.DB().Table("teams").Select("name").Where("id = ?", n.TeamID).Scan(&teamName).Error; err != nil {
+				return fmt.Errorf("failed to get team(id=%s); %v", n.TeamID, err)
+			}
+			teamTarget := target
+			teamTarget.Teams = append(teamTarget.Teams, silence.Ref{ID: n.TeamID.String(), Name: teamName})
+
 			for _, cn := range teamSpec.Notifications {
 				if valid, err := expressionRunner.Eval(ctx, cn.Filter); err != nil || !valid {
 					continue
@@ -268,6 +421,9 @@ func addNotificationEvent(ctx *api.Context, event api.Event) error {
 					TeamID:           n.TeamID.String(),
 					NotificationName: cn.Name,
 				}
+				if isSilenced(ctx, silences, teamTarget, prop) {
+					continue
+				}
 
 				newEvent := api.Event{
 					ID:         uuid.New(),
@@ -292,6 +448,9 @@ func addNotificationEvent(ctx *api.Context, event api.Event) error {
 				ID:               event.Properties["id"],
 				NotificationName: cn.Name,
 			}
+			if isSilenced(ctx, silences, target, prop) {
+				continue
+			}
 
 			newEvent := api.Event{
 				ID:         uuid.New(),
@@ -308,6 +467,50 @@ func addNotificationEvent(ctx *api.Context, event api.Event) error {
 	return nil
 }
 
+// getSilenceTarget returns the check or the resources of the incident the event is for.
+func getSilenceTarget(ctx *api.Context, eventName string, env map[string]any) (silence.Target, error) {
+	if check, ok := env["check"].(map[string]any); ok && strings.HasPrefix(eventName, "check.") {
+		target := silence.Target{
+			Checks: []silence.Ref{{ID: fmt.Sprint(check["id"]), Name: fmt.Sprint(check["name"])}},
+		}
+		if labels, ok := check["labels"].(map[string]any); ok {
+			target.Labels = make(map[string]string, len(labels))
+			for k, v := range labels {
+				target.Labels[k] = fmt.Sprint(v)
+			}
+		}
+		return target, nil
+	}
+
+	if incident, ok := env["incident"].(map[string]any); ok {
+		return silence.ForIncident(ctx, fmt.Sprint(incident["id"]))
+	}
+
+	return silence.Target{}, nil
+}
+
+// isSilenced returns whether the notification of the recipient is silenced,
+// and records its suppression if it is.
+func isSilenced(ctx *api.Context, silences []api.Silence, target silence.Target, prop NotificationEventProperties) bool {
+	silenced := silence.Find(silences, target)
+	if silenced == nil {
+		return false
+	}
+
+	suppression := api.SilenceSuppression{
+		SilenceID:   silenced.ID,
+		Action:      silence.ActionNotification,
+		SourceID:    prop.NotificationID,
+		ResourceID:  prop.ID,
+		Recipient:   prop.Recipient(),
+		Description: prop.EventName,
+	}
+	if err := silence.Record(ctx.DB(), suppression); err != nil {
+		logger.Errorf("error recording the suppression of notification %s by silence %s: %v", prop.NotificationID, silenced.Name, err)
+	}
+	return true
+}
+
 // getEnvForEvent gets the environment variables for the given event
 // that'll be passed to the cel expression or to the template renderer as a view.
 func getEnvForEvent(ctx *api.Context, eventName string, properties map[string]string) (map[string]any, error) {
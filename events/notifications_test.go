@@ -0,0 +1,112 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/flanksource/incident-commander/api"
+	pkgNotification "github.com/flanksource/incident-commander/notification"
+)
+
+func TestNewNotificationSend(t *testing.T) {
+	notificationID, personID, teamID, incidentID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	env := map[string]any{"incident": map[string]any{"id": incidentID.String()}}
+
+	tests := []struct {
+		name          string
+		props         NotificationEventProperties
+		env           map[string]any
+		wantRecipient string
+		wantPerson    bool
+		wantTeam      bool
+		wantIncident  bool
+	}{
+		{
+			name:          "person",
+			props:         NotificationEventProperties{ID: incidentID.String(), EventName: "incident.created", PersonID: personID.String()},
+			env:           env,
+			wantRecipient: "person:" + personID.String(),
+			wantPerson:    true,
+			wantIncident:  true,
+		},
+		{
+			name:          "team",
+			props:         NotificationEventProperties{ID: incidentID.String(), EventName: "incident.created", TeamID: teamID.String(), NotificationName: "slack"},
+			env:           env,
+			wantRecipient: "team:" + teamID.String() + "/slack",
+			wantTeam:      true,
+			wantIncident:  true,
+		},
+		{
+			name:          "custom service of a check",
+			props:         NotificationEventProperties{ID: uuid.NewString(), EventName: "check.failed", NotificationName: "ops-webhook"},
+			env:           map[string]any{"check": map[string]any{"id": "c1"}},
+			wantRecipient: "ops-webhook",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			send := newNotificationSend(notificationID, tt.props, tt.env)
+			if send.NotificationID != notificationID || send.EventName != tt.props.EventName || send.ResourceID != tt.props.ID {
+				t.Errorf("newNotificationSend() = %+v, want the notification and event of %+v", send, tt.props)
+			}
+			if send.Recipient != tt.wantRecipient {
+				t.Errorf("newNotificationSend() recipient = %q, want %q", send.Recipient, tt.wantRecipient)
+			}
+			if (send.PersonID != nil && *send.PersonID == personID) != tt.wantPerson {
+				t.Errorf("newNotificationSend() person = %v, want %v", send.PersonID, tt.wantPerson)
+			}
+			if (send.TeamID != nil && *send.TeamID == teamID) != tt.wantTeam {
+				t.Errorf("newNotificationSend() team = %v, want %v", send.TeamID, tt.wantTeam)
+			}
+			if (send.IncidentID != nil && *send.IncidentID == incidentID) != tt.wantIncident {
+				t.Errorf("newNotificationSend() incident = %v, want %v", send.IncidentID, tt.wantIncident)
+			}
+		})
+	}
+}
+
+func TestEvalFilter(t *testing.T) {
+	expressionRunner := pkgNotification.ExpressionRunner{
+		ResourceType: "notification",
+		CelEnv:       map[string]any{"check": map[string]any{"status": "unhealthy"}},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		want       bool
+	}{
+		{name: "no filter", expression: "", want: true},
+		{name: "passes", expression: "check.status == 'unhealthy'", want: true},
+		{name: "fails", expression: "check.status == 'healthy'", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := evalFilter(api.NewContext(nil, nil), expressionRunner, tt.expression)
+			if got.Passed != tt.want || got.CompileError != "" || got.Error != "" {
+				t.Errorf("evalFilter() = %+v, want passed=%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultDigestTemplate(t *testing.T) {
+	first := time.Date(2023, 6, 1, 10, 30, 0, 0, time.UTC)
+	entries := []api.NotificationDigestEntry{
+		{EventName: "incident.created", Message: "Incident DB latency created", CreatedAt: first},
+		{EventName: "check.failed", Message: "Check http failed", CreatedAt: first.Add(time.Minute)},
+	}
+
+	data := NotificationTemplate{Message: pkgNotification.DefaultDigestTemplate}
+	if err := newNotificationTemplater(pkgNotification.DigestEnv(entries)).Walk(&data); err != nil {
+		t.Fatalf("error templating the digest: %v", err)
+	}
+
+	want := "2 notifications since 2023-06-01 10:30:00 +0000 UTC\n\n- Incident DB latency created\n- Check http failed"
+	if data.Message != want {
+		t.Errorf("digest = %q, want %q", data.Message, want)
+	}
+}
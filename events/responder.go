@@ -1,27 +1,34 @@
 package events
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 
 	"gorm.io/gorm"
 
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
 	"github.com/flanksource/incident-commander/responder"
 	pkgResponder "github.com/flanksource/incident-commander/responder"
+	"github.com/google/uuid"
 )
 
 func NewResponderConsumer(db *gorm.DB) EventConsumer {
 	return EventConsumer{
+		Name: "responder",
 		WatchEvents: []string{
 			EventIncidentResponderAdded,
-			EventIncidentCommentAdded,
+			EventIncidentCommentAdded, EventIncidentCommentUpdated, EventIncidentCommentDeleted,
+			EventResponderStatusUpdate, EventResponderWebhookSync,
 		},
 		ProcessBatchFunc: processResponderEvents,
 		BatchSize:        1,
 		Consumers:        1,
 		DB:               db,
+		Backoff:          ResponderBackoff,
 	}
 }
 
@@ -42,6 +49,14 @@ func handleResponderEvent(ctx *api.Context, event api.Event) error {
 		return reconcileResponderEvent(ctx, event)
 	case EventIncidentCommentAdded:
 		return reconcileCommentEvent(ctx, event)
+	case EventIncidentCommentUpdated:
+		return reconcileCommentUpdatedEvent(ctx, event)
+	case EventIncidentCommentDeleted:
+		return reconcileCommentDeletedEvent(ctx, event)
+	case EventResponderStatusUpdate:
+		return reconcileResponderStatusEvent(ctx, event)
+	case EventResponderWebhookSync:
+		return pkgResponder.SyncWebhookResponders(ctx, event)
 	default:
 		return fmt.Errorf("Unrecognized event name: %s", event.Name)
 	}
@@ -51,17 +66,30 @@ func reconcileResponderEvent(ctx *api.Context, event api.Event) error {
 	responderID := event.Properties["id"]
 
 	var responder api.Responder
-	err := ctx.DB().Where("id = ? AND external_id is NULL", responderID).Preload("Incident").Preload("Team").Find(&responder).Error
-	if err != nil {
-		return err
+	tx := ctx.DB().Where("id = ? AND external_id is NULL", responderID).Preload("Incident").Preload("Team").Find(&responder)
+	if tx.Error != nil {
+		return tx.Error
+	} else if tx.RowsAffected == 0 {
+		logger.Debugf("Skipping responder %s since it already has an external id", responderID)
+		return nil
+	}
+
+	if !pkgResponder.HasClient(responder.Properties["responderType"]) {
+		return addNotificationEvent(ctx, event)
 	}
 
-	responderClient, err := pkgResponder.GetResponder(ctx, responder.Team)
+	responderClient, clientConfig, err := pkgResponder.GetResponderFor(ctx, responder)
 	if err != nil {
 		return err
 	}
 
-	externalID, err := responderClient.NotifyResponder(ctx, responder)
+	if responder.ClientName() != clientConfig.Name {
+		if err := db.SetResponderClient(ctx, responder.ID, clientConfig.Name); err != nil {
+			return fmt.Errorf("error recording the client of responder %s: %w", responder.ID, err)
+		}
+	}
+
+	externalID, err := notifyResponderOnce(ctx, responderClient, responder)
 	if err != nil {
 		return err
 	}
@@ -77,6 +105,47 @@ func reconcileResponderEvent(ctx *api.Context, event api.Event) error {
 	return nil
 }
 
+// notifyResponderOnce creates the ticket for the responder at most once.
+//
+// The reservation is committed outside of the event's transaction so that it
+// survives a rollback. A retry that finds an existing reservation looks up
+// the ticket that the previous attempt may have created instead of creating a duplicate.
+func notifyResponderOnce(ctx *api.Context, client pkgResponder.ResponderInterface, responder api.Responder) (string, error) {
+	reservation, created, err := db.ReserveResponderExternalID(responder)
+	if err != nil {
+		return "", fmt.Errorf("error reserving responder external id: %w", err)
+	}
+
+	if reservation.ExternalID != "" {
+		return reservation.ExternalID, nil
+	}
+
+	if !created {
+		externalID, err := client.FindExternalID(ctx, responder, *reservation)
+		if err != nil {
+			return "", fmt.Errorf("error looking up previously created ticket: %w", err)
+		}
+
+		if externalID != "" {
+			logger.Infof("Found ticket %s created by a previous attempt for responder %s", externalID, responder.ID)
+			return externalID, db.CompleteResponderReservation(responder.ID, externalID)
+		}
+	}
+
+	externalID, err := client.NotifyResponder(ctx, responder)
+	if err != nil {
+		return "", err
+	}
+
+	if externalID != "" {
+		if err := db.CompleteResponderReservation(responder.ID, externalID); err != nil {
+			return "", fmt.Errorf("error completing responder reservation: %w", err)
+		}
+	}
+
+	return externalID, nil
+}
+
 func reconcileCommentEvent(ctx *api.Context, event api.Event) error {
 	commentID := event.Properties["id"]
 
@@ -91,25 +160,33 @@ func reconcileCommentEvent(ctx *api.Context, event api.Event) error {
 		return err
 	}
 
-	// Get all responders related to a comment
+	// Get all responders related to a comment that don't have it yet
 	var responders []api.Responder
 	commentRespondersQuery := `
         SELECT * FROM responders WHERE incident_id IN (
-            SELECT incident_id FROM comments WHERE id = ?
+            SELECT incident_id FROM comments WHERE id = @id
+        ) AND id NOT IN (
+            SELECT responder_id FROM comment_responders WHERE comment_id = @id
         )
     `
-	if err = ctx.DB().Raw(commentRespondersQuery, commentID).Preload("Team").Find(&responders).Error; err != nil {
+	if err = ctx.DB().Raw(commentRespondersQuery, sql.Named("id", commentID)).Preload("Team").Find(&responders).Error; err != nil {
 		return err
 	}
 
 	// For each responder add the comment
 	for _, _responder := range responders {
+		// Only the responders with an issue can be commented on
+		if !pkgResponder.HasClient(_responder.Properties["responderType"]) || _responder.ExternalID == "" {
+			continue
+		}
+
 		// Reset externalID to avoid inserting previous iteration's ID
 		externalID := ""
 
-		responder, err := responder.GetResponder(ctx, _responder.Team)
+		responder, _, err := responder.GetResponderFor(ctx, _responder)
 		if err != nil {
-			return err
+			logger.Errorf("error getting client of responder:%s %v", _responder.ID, err)
+			continue
 		}
 
 		externalID, err = responder.NotifyResponderAddComment(ctx, _responder, comment.Comment)
@@ -135,3 +212,148 @@ func reconcileCommentEvent(ctx *api.Context, event api.Event) error {
 
 	return nil
 }
+
+// reconcileCommentUpdatedEvent updates the external comments of an edited comment.
+// An edit pulled from a responder isn't pushed back to it.
+// The event is retried if any of the responders fails.
+func reconcileCommentUpdatedEvent(ctx *api.Context, event api.Event) error {
+	commentID := event.Properties["id"]
+	origin := event.Properties["origin"]
+
+	var comment api.Comment
+	if err := ctx.DB().Where("id = ?", commentID).First(&comment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Debugf("Skipping update of comment %s since it was deleted", commentID)
+			return nil
+		}
+		return err
+	}
+
+	var targets []externalComment
+	externalCommentsQuery := `
+        SELECT responder_id, external_id FROM comment_responders WHERE comment_id = @id AND external_id IS NOT NULL
+        UNION ALL
+        SELECT responder_id, external_id FROM comments WHERE id = @id AND responder_id IS NOT NULL AND external_id IS NOT NULL
+    `
+	if err := ctx.DB().Raw(externalCommentsQuery, sql.Named("id", commentID)).Scan(&targets).Error; err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, target := range targets {
+		if target.ResponderID.String() == origin {
+			continue
+		}
+
+		if err := updateResponderComment(ctx, target, comment.Comment); err != nil {
+			errs = append(errs, fmt.Sprintf("responder:%s: %v", target.ResponderID, err))
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("error updating comment %s on responders: %s", commentID, strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// externalComment is a comment on a responder that's linked to a comment of an incident.
+type externalComment struct {
+	ResponderID uuid.UUID
+	ExternalID  string
+}
+
+func updateResponderComment(ctx *api.Context, target externalComment, comment string) error {
+	var _responder api.Responder
+	if err := ctx.DB().Where("id = ?", target.ResponderID).Preload("Team").First(&_responder).Error; err != nil {
+		return err
+	}
+
+	responder, _, err := pkgResponder.GetResponderFor(ctx, _responder)
+	if err != nil {
+		return err
+	}
+
+	return responder.UpdateComment(ctx, _responder, target.ExternalID, comment)
+}
+
+// reconcileCommentDeletedEvent deletes the external comments of a deleted comment.
+// Deleted external comments are removed from the queue so that only the failed ones are retried.
+func reconcileCommentDeletedEvent(ctx *api.Context, event api.Event) error {
+	commentID := event.Properties["id"]
+
+	var deletions []api.CommentResponderDeletion
+	if err := ctx.DB().Where("comment_id = ?", commentID).Find(&deletions).Error; err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, deletion := range deletions {
+		if err := deleteResponderComment(ctx, deletion); err != nil {
+			errs = append(errs, fmt.Sprintf("responder:%s: %v", deletion.ResponderID, err))
+			continue
+		}
+
+		if err := ctx.DB().Delete(&deletion).Error; err != nil {
+			logger.Errorf("error removing comment deletion %s: %v", deletion.ID, err)
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("error deleting comment %s on responders: %s", commentID, strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+func deleteResponderComment(ctx *api.Context, deletion api.CommentResponderDeletion) error {
+	var _responder api.Responder
+	if err := ctx.DB().Where("id = ?", deletion.ResponderID).Preload("Team").First(&_responder).Error; err != nil {
+		return err
+	}
+
+	responder, _, err := pkgResponder.GetResponderFor(ctx, _responder)
+	if err != nil {
+		return err
+	}
+
+	return responder.DeleteComment(ctx, _responder, deletion.ExternalID)
+}
+
+// reconcileResponderStatusEvent moves the issue of the responder
+// to the state mapped to the current status of its incident.
+func reconcileResponderStatusEvent(ctx *api.Context, event api.Event) error {
+	responderID := event.Properties["id"]
+
+	var _responder api.Responder
+	tx := ctx.DB().Where("id = ? AND external_id IS NOT NULL", responderID).Preload("Incident").Preload("Team").Find(&_responder)
+	if tx.Error != nil {
+		return tx.Error
+	} else if tx.RowsAffected == 0 {
+		logger.Debugf("Skipping responder %s since it doesn't have an issue", responderID)
+		return nil
+	}
+
+	if !pkgResponder.HasClient(_responder.Properties["responderType"]) {
+		return nil
+	}
+
+	responder, clientConfig, err := pkgResponder.GetResponderFor(ctx, _responder)
+	if err != nil {
+		return err
+	}
+
+	state, ok := clientConfig.StatusMapping().IssueState(_responder.Incident.Status)
+	if !ok {
+		return nil
+	}
+
+	if err := responder.UpdateStatus(ctx, _responder, state); err != nil {
+		return fmt.Errorf("error updating status of responder:%s to %s: %w", _responder.ID, state, err)
+	}
+
+	// Recorded so that the status sync doesn't treat our own update as an external change
+	if err := db.SaveResponderExternalStatus(ctx, _responder.ID, state); err != nil {
+		logger.Errorf("error saving status of responder:%s: %v", _responder.ID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package events
+
+import (
+	"github.com/flanksource/commons/logger"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+	"github.com/flanksource/incident-commander/rules"
+)
+
+func NewIncidentRuleConsumer(db *gorm.DB) EventConsumer {
+	return EventConsumer{
+		Name:             "incident_rule",
+		WatchEvents:      []string{EventComponentStatusUpdated, EventCheckStatusFailed, EventConfigAnalysisUpdated},
+		ProcessBatchFunc: processIncidentRuleEvents,
+		BatchSize:        100,
+		// The rules are evaluated one batch at a time anyway
+		Consumers: 1,
+		DB:        db,
+	}
+}
+
+// processIncidentRuleEvents evaluates the incident rules against the components whose status changed,
+// the checks that failed and the config analyses that were opened or updated.
+func processIncidentRuleEvents(ctx *api.Context, events []api.Event) []*api.Event {
+	idsByEvent := make(map[string][]uuid.UUID)
+	for _, e := range events {
+		id, err := uuid.Parse(e.Properties["id"])
+		if err != nil {
+			logger.Warnf("event has invalid id=%q. It's not a UUID", e.Properties["id"])
+			continue
+		}
+		idsByEvent[e.Name] = append(idsByEvent[e.Name], id)
+	}
+
+	// The incidents are committed right away, instead of with the batch, so that
+	// the periodic evaluation of the rules doesn't open them again in the meantime.
+	evaluateCtx := api.NewContext(db.Gorm, nil)
+	errs := make(map[string]error)
+	for name, ids := range idsByEvent {
+		var err error
+		switch name {
+		case EventComponentStatusUpdated:
+			err = rules.EvaluateComponents(evaluateCtx, ids)
+		case EventCheckStatusFailed:
+			err = rules.EvaluateChecks(evaluateCtx, ids)
+		case EventConfigAnalysisUpdated:
+			err = rules.EvaluateConfigAnalyses(evaluateCtx, ids)
+		}
+		if err != nil {
+			errs[name] = err
+		}
+	}
+
+	var failedEvents []*api.Event
+	for i := range events {
+		if err, ok := errs[events[i].Name]; ok {
+			events[i].Error = err.Error()
+			failedEvents = append(failedEvents, &events[i])
+		}
+	}
+	return failedEvents
+}
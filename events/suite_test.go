@@ -13,6 +13,7 @@ import (
 	"github.com/flanksource/duty/fixtures/dummy"
 	"github.com/flanksource/duty/testutils"
 	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
 
 	"github.com/flanksource/incident-commander/upstream"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -56,6 +57,9 @@ var _ = ginkgo.BeforeSuite(func() {
 	if agentDB, agentDBPGPool, err = duty.SetupDB(connection, nil); err != nil {
 		ginkgo.Fail(err.Error())
 	}
+	if err := db.RunMigrations(agentDB); err != nil {
+		ginkgo.Fail(err.Error())
+	}
 	if err := dummy.PopulateDBWithDummyModels(agentDB); err != nil {
 		ginkgo.Fail(err.Error())
 	}
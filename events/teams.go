@@ -13,6 +13,7 @@ import (
 
 func NewTeamConsumer(db *gorm.DB) EventConsumer {
 	return EventConsumer{
+		Name: "team",
 		WatchEvents: []string{
 			EventTeamUpdate,
 			EventTeamDelete,
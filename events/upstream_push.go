@@ -17,11 +17,13 @@ func NewUpstreamPushConsumer(db *gorm.DB, config Config) EventConsumer {
 	}
 
 	return EventConsumer{
+		Name:             "upstream_push",
 		WatchEvents:      []string{EventPushQueueCreate},
 		ProcessBatchFunc: handleUpstreamPushEvents,
 		BatchSize:        50,
 		Consumers:        5,
 		DB:               db,
+		Backoff:          UpstreamPushBackoff,
 	}
 }
 
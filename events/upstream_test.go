@@ -1,6 +1,7 @@
 package events
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -138,7 +139,7 @@ var _ = ginkgo.Describe("Push Mode", ginkgo.Ordered, func() {
 		}
 
 		c := NewUpstreamPushConsumer(agentDB, eventHandlerConfig)
-		c.ConsumeEventsUntilEmpty()
+		c.ConsumeEventsUntilEmpty(context.Background())
 	})
 
 	ginkgo.It("should have transferred all the components", func() {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -8,6 +9,8 @@ import (
 	"github.com/flanksource/duty/types"
 	"github.com/flanksource/incident-commander/api"
 	"github.com/flanksource/incident-commander/db"
+	"github.com/flanksource/incident-commander/events"
+	"github.com/flanksource/incident-commander/jobs"
 	"github.com/flanksource/incident-commander/rules"
 	"github.com/google/uuid"
 	"github.com/onsi/ginkgo/v2"
@@ -83,6 +86,7 @@ var _ = ginkgo.Describe("Test incident creation via incidence rule", ginkgo.Orde
 				Template: api.IncidentTemplate{
 					Description: incidentDescription,
 				},
+				AutoResolve: &api.AutoClose{Timeout: time.Minute},
 				IncidentResponders: api.IncidentResponders{
 					Email: []api.Email{
 						{
@@ -125,5 +129,75 @@ var _ = ginkgo.Describe("Test incident creation via incidence rule", ginkgo.Orde
 		var anotherIncident *models.Incident
 		err = db.Gorm.Where("title = ?", fmt.Sprintf("%s is %s", anotherComponent.Name, anotherComponent.Status)).First(&anotherIncident).Error
 		Expect(err).To(BeNil())
+
+		// The rule's email responder is added to each incident
+		var responders []models.Responder
+		err = db.Gorm.Where("incident_id IN ?", []uuid.UUID{incident.ID, anotherIncident.ID}).Find(&responders).Error
+		Expect(err).To(BeNil())
+		Expect(len(responders)).To(Equal(2))
+		for _, responder := range responders {
+			Expect(responder.Type).To(Equal("Email"))
+		}
+	})
+
+	ginkgo.It("should auto resolve the incidents once the components are healthy", func() {
+		for _, c := range []*models.Component{component, anotherComponent} {
+			c.Status = types.ComponentStatusHealthy
+			Expect(db.Gorm.Save(c).Error).To(BeNil())
+		}
+
+		// Healthy for longer than the rule's timeout
+		err := db.Gorm.Exec("UPDATE component_status_history SET created_at = NOW() - INTERVAL '1 hour' WHERE component_id IN ?",
+			[]uuid.UUID{component.ID, anotherComponent.ID}).Error
+		Expect(err).To(BeNil())
+
+		Expect(rules.Run()).To(BeNil())
+
+		var incidents []models.Incident
+		err = db.Gorm.Where(&models.Incident{Description: incidentDescription}).Find(&incidents).Error
+		Expect(err).To(BeNil())
+		Expect(len(incidents)).To(Equal(2))
+		for _, incident := range incidents {
+			Expect(incident.Status).To(Equal(models.IncidentStatus(api.IncidentStatusResolved)))
+		}
+
+		var count int64
+		err = db.Gorm.Table("incident_histories").Where("type = ?", "incident.auto_resolved").Count(&count).Error
+		Expect(err).To(BeNil())
+		Expect(count).To(Equal(int64(2)))
+	})
+
+	ginkgo.It("should create incidents as the components change status", func() {
+		component.Status = types.ComponentStatusUnhealthy
+		Expect(db.Gorm.Save(component).Error).To(BeNil())
+
+		consumer := events.NewIncidentRuleConsumer(db.Gorm)
+		consumer.ConsumeEventsUntilEmpty(context.Background())
+
+		var incidents []models.Incident
+		err := db.Gorm.Where(&models.Incident{Description: incidentDescription, Status: models.IncidentStatus(api.IncidentStatusOpen)}).Find(&incidents).Error
+		Expect(err).To(BeNil())
+		Expect(len(incidents)).To(Equal(1))
+		Expect(incidents[0].Title).To(Equal(fmt.Sprintf("%s is %s", component.Name, component.Status)))
+	})
+
+	ginkgo.It("should delete the status history older than the dry run window", func() {
+		err := db.Gorm.Exec(`INSERT INTO component_status_history(component_id, status, created_at) VALUES
+            (?, 'unhealthy', NOW() - INTERVAL '40 days'),
+            (?, 'healthy', NOW() - INTERVAL '35 days')`, anotherComponent.ID, anotherComponent.ID).Error
+		Expect(err).To(BeNil())
+
+		jobs.CleanupComponentStatusHistory()
+
+		var statuses []string
+		err = db.Gorm.Raw("SELECT status FROM component_status_history WHERE component_id = ? ORDER BY created_at", anotherComponent.ID).Scan(&statuses).Error
+		Expect(err).To(BeNil())
+		// The latest change before the window is the status the component was in when the window starts
+		Expect(statuses[0]).To(Equal("healthy"))
+		Expect(len(statuses)).To(BeNumerically(">", 1))
+
+		since, err := db.GetComponentStatusSince(context.Background(), []uuid.UUID{anotherComponent.ID})
+		Expect(err).To(BeNil())
+		Expect(since[anotherComponent.ID]).To(BeTemporally(">", time.Now().AddDate(0, 0, -rules.MaxDryRunDays)))
 	})
 })
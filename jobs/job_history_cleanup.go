@@ -1,9 +1,12 @@
 package jobs
 
 import (
+	"time"
+
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/incident-commander/api"
 	"github.com/flanksource/incident-commander/db"
+	"github.com/flanksource/incident-commander/rules"
 )
 
 func CleanupJobHistoryTable() {
@@ -13,3 +16,16 @@ func CleanupJobHistoryTable() {
 		logger.Errorf("Error deleting old job history rows: %v", err)
 	}
 }
+
+// CleanupComponentStatusHistory deletes the component status changes
+// older than the incident rules can be simulated over.
+func CleanupComponentStatusHistory() {
+	ctx := api.NewContext(db.Gorm, nil)
+
+	deleted, err := db.DeleteOldComponentStatusHistory(ctx, time.Now().AddDate(0, 0, -rules.MaxDryRunDays))
+	if err != nil {
+		logger.Errorf("Error deleting old component status history: %v", err)
+		return
+	}
+	logger.Debugf("Deleted %d old component status changes", deleted)
+}
@@ -1,7 +1,9 @@
 package jobs
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/flanksource/commons/logger"
@@ -17,25 +19,65 @@ const (
 	EvaluateEvidenceScriptsSchedule = "@every 5m"
 	ResponderCommentsSyncSchedule   = "@every 1h"
 	ResponderConfigSyncSchedule     = "@every 1h"
+	ResponderStatusSyncSchedule     = "@every 15m"
 	CleanupJobHistoryTableSchedule  = "@every 24h"
+	CleanupStatusHistorySchedule    = "@every 24h"
 	PushAgentReconcileSchedule      = "@every 30m"
+	NotificationDigestsSchedule     = "@every 1m"
 )
 
 var FuncScheduler = cron.New()
 
+var (
+	// initialRuns are the runs of the jobs at startup, which Stop waits for
+	initialRuns sync.WaitGroup
+	// schedulerLock guards the start of the scheduler against a concurrent Stop
+	schedulerLock sync.Mutex
+	stopped       bool
+)
+
 func ScheduleFunc(schedule string, fn func()) (any, error) {
 	return FuncScheduler.AddFunc(schedule, fn)
 }
 
-func Start() {
+// Start runs all the jobs once and then schedules them.
+// The remaining jobs aren't run, and nothing is scheduled,
+// once the context is done or Stop has been called.
+func Start(ctx context.Context) {
+	schedulerLock.Lock()
+	if stopped {
+		schedulerLock.Unlock()
+		return
+	}
+	initialRuns.Add(1)
+	schedulerLock.Unlock()
+	defer initialRuns.Done()
+
 	// Running first at startup and then with the schedule
-	TeamComponentOwnershipRun()
-	EvaluateEvidenceScripts()
-	responder.SyncComments()
-	responder.SyncConfig()
-	CleanupJobHistoryTable()
-	if err := rules.Run(); err != nil {
-		logger.Errorf("error running incident rules: %w", err)
+	initialJobs := []func(){
+		TeamComponentOwnershipRun,
+		EvaluateEvidenceScripts,
+		responder.SyncComments,
+		responder.SyncConfig,
+		responder.SyncStatuses,
+		CleanupJobHistoryTable,
+		CleanupComponentStatusHistory,
+		SendNotificationDigests,
+		func() {
+			if err := rules.Run(); err != nil {
+				logger.Errorf("error running incident rules: %w", err)
+			}
+		},
+	}
+	for _, job := range initialJobs {
+		if ctx.Err() != nil {
+			return
+		}
+		job()
+	}
+
+	if ctx.Err() != nil {
+		return
 	}
 
 	if _, err := ScheduleFunc(TeamComponentOwnershipSchedule, TeamComponentOwnershipRun); err != nil {
@@ -54,10 +96,22 @@ func Start() {
 		logger.Errorf("Failed to schedule job for syncing responder config: %v", err)
 	}
 
+	if _, err := ScheduleFunc(ResponderStatusSyncSchedule, responder.SyncStatuses); err != nil {
+		logger.Errorf("Failed to schedule job for syncing responder statuses: %v", err)
+	}
+
 	if _, err := ScheduleFunc(CleanupJobHistoryTableSchedule, CleanupJobHistoryTable); err != nil {
 		logger.Errorf("Failed to schedule job for cleaning up job history table: %v", err)
 	}
 
+	if _, err := ScheduleFunc(CleanupStatusHistorySchedule, CleanupComponentStatusHistory); err != nil {
+		logger.Errorf("Failed to schedule job for cleaning up component status history: %v", err)
+	}
+
+	if _, err := ScheduleFunc(NotificationDigestsSchedule, SendNotificationDigests); err != nil {
+		logger.Errorf("Failed to schedule job for sending notification digests: %v", err)
+	}
+
 	if api.UpstreamConf.Valid() {
 		job := newFuncJob(upstream.SyncWithUpstream, withName("upstream reconcile job"), withRunNow(true), withTimeout(time.Minute*10))
 		if err := job.schedule(FuncScheduler, PushAgentReconcileSchedule); err != nil {
@@ -75,5 +129,44 @@ func Start() {
 		logger.Errorf("Failed to schedule job for incident rules: %v", err)
 	}
 
-	FuncScheduler.Start()
+	if _, err := ScheduleFunc(fmt.Sprintf("@every %s", rules.AutoClosePeriod), func() {
+		if err := rules.AutoClose(); err != nil {
+			logger.Errorf("error auto closing incidents: %v", err)
+		}
+	}); err != nil {
+		logger.Errorf("Failed to schedule job for auto closing incidents: %v", err)
+	}
+
+	schedulerLock.Lock()
+	defer schedulerLock.Unlock()
+	if !stopped {
+		FuncScheduler.Start()
+	}
+}
+
+// Stop stops the scheduler and waits for the initial and the running jobs to complete
+// or for the context to be done, whichever happens first.
+func Stop(ctx context.Context) {
+	schedulerLock.Lock()
+	stopped = true
+	schedulerLock.Unlock()
+
+	initialRunsDone := make(chan struct{})
+	go func() {
+		initialRuns.Wait()
+		close(initialRunsDone)
+	}()
+
+	select {
+	case <-initialRunsDone:
+	case <-ctx.Done():
+		logger.Warnf("Timed out waiting for the initial run of the jobs to complete")
+		return
+	}
+
+	select {
+	case <-FuncScheduler.Stop().Done():
+	case <-ctx.Done():
+		logger.Warnf("Timed out waiting for the running jobs to complete")
+	}
 }
@@ -0,0 +1,17 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStartAfterStop(t *testing.T) {
+	Stop(context.Background())
+
+	// None of the jobs run, as they'd fail without a database, and the scheduler isn't started
+	Start(context.Background())
+
+	if entries := FuncScheduler.Entries(); len(entries) != 0 {
+		t.Errorf("Start() after Stop() scheduled %d jobs", len(entries))
+	}
+}
@@ -0,0 +1,18 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+	"github.com/flanksource/incident-commander/events"
+)
+
+func SendNotificationDigests() {
+	ctx := api.NewContext(db.Gorm, nil)
+
+	if err := events.SendNotificationDigests(ctx, time.Now()); err != nil {
+		logger.Errorf("Error sending notification digests: %v", err)
+	}
+}
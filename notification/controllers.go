@@ -0,0 +1,71 @@
+package notification
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+)
+
+// ListSends lists the latest notification sends. They can be filtered by the
+// "notification_id", "incident_id", "recipient", "person_id", "team_id" & "status" query params.
+func ListSends(c echo.Context) error {
+	ctx := c.(*api.Context)
+
+	query := api.NotificationSendQuery{
+		NotificationID: c.QueryParam("notification_id"),
+		IncidentID:     c.QueryParam("incident_id"),
+		Recipient:      c.QueryParam("recipient"),
+		PersonID:       c.QueryParam("person_id"),
+		TeamID:         c.QueryParam("team_id"),
+		Status:         c.QueryParam("status"),
+	}
+	for param, value := range map[string]string{
+		"notification_id": query.NotificationID,
+		"incident_id":     query.IncidentID,
+		"person_id":       query.PersonID,
+		"team_id":         query.TeamID,
+	} {
+		if _, err := uuid.Parse(value); value != "" && err != nil {
+			return c.JSON(http.StatusBadRequest, api.HTTPError{Error: err.Error(), Message: fmt.Sprintf("'%s' param needs to be a uuid", param)})
+		}
+	}
+	if limitRaw := c.QueryParam("limit"); limitRaw != "" {
+		limit, err := strconv.Atoi(limitRaw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, api.HTTPError{Error: err.Error(), Message: "'limit' param needs to be a number"})
+		}
+		query.Limit = limit
+	}
+
+	sends, err := db.ListNotificationSends(ctx, query)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, api.HTTPError{Error: err.Error(), Message: "failed to list notification sends"})
+	}
+
+	return c.JSON(http.StatusOK, sends)
+}
+
+// GetSend returns a single notification send.
+func GetSend(c echo.Context) error {
+	ctx := c.(*api.Context)
+
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		return c.JSON(http.StatusBadRequest, api.HTTPError{Error: err.Error(), Message: "'id' needs to be a uuid"})
+	}
+
+	send, err := db.GetNotificationSend(ctx, id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, api.HTTPError{Error: err.Error(), Message: "failed to get notification send"})
+	} else if send == nil {
+		return c.JSON(http.StatusNotFound, api.HTTPError{Message: fmt.Sprintf("notification send(id=%s) not found", id)})
+	}
+
+	return c.JSON(http.StatusOK, send)
+}
@@ -0,0 +1,49 @@
+package notification
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/flanksource/incident-commander/api"
+)
+
+// DefaultDigestTemplate lists the messages of the buffered notifications.
+const DefaultDigestTemplate = `{{.count}} notifications since {{.since}}
+{{range .entries}}
+- {{.message}}{{end}}`
+
+// DigestDue returns whether the digest of the notifications buffered since the given time is due,
+// i.e. the schedule has fired since.
+func DigestDue(schedule string, since, now time.Time) (bool, error) {
+	s, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return false, fmt.Errorf("invalid digest schedule %q: %w", schedule, err)
+	}
+
+	return !s.Next(since).After(now), nil
+}
+
+// DigestEnv returns the values the digest template is rendered with:
+// the buffered notifications as "entries", their "count" and when the oldest was buffered as "since".
+func DigestEnv(entries []api.NotificationDigestEntry) map[string]any {
+	env := map[string]any{"count": len(entries)}
+
+	list := make([]map[string]any, 0, len(entries))
+	for i, entry := range entries {
+		if i == 0 || entry.CreatedAt.Before(env["since"].(time.Time)) {
+			env["since"] = entry.CreatedAt
+		}
+
+		list = append(list, map[string]any{
+			"event_name":  entry.EventName,
+			"resource_id": entry.ResourceID,
+			"message":     entry.Message,
+			"created_at":  entry.CreatedAt,
+		})
+	}
+	env["entries"] = list
+
+	return env
+}
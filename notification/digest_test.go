@@ -0,0 +1,57 @@
+package notification
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flanksource/incident-commander/api"
+)
+
+func TestDigestDue(t *testing.T) {
+	since := time.Date(2023, 6, 1, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		schedule string
+		now      time.Time
+		want     bool
+		wantErr  bool
+	}{
+		{name: "hourly before the hour", schedule: "@hourly", now: since.Add(20 * time.Minute), want: false},
+		{name: "hourly after the hour", schedule: "@hourly", now: since.Add(30 * time.Minute), want: true},
+		{name: "daily the same day", schedule: "0 9 * * *", now: since.Add(12 * time.Hour), want: false},
+		{name: "daily the next morning", schedule: "0 9 * * *", now: since.Add(23 * time.Hour), want: true},
+		{name: "timezone", schedule: "CRON_TZ=Asia/Kathmandu 0 18 * * *", now: since.Add(2 * time.Hour), want: true},
+		{name: "invalid", schedule: "every morning", now: since, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DigestDue(tt.schedule, since, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DigestDue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DigestDue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDigestEnv(t *testing.T) {
+	first := time.Date(2023, 6, 1, 10, 30, 0, 0, time.UTC)
+	entries := []api.NotificationDigestEntry{
+		{EventName: "check.failed", Message: "check http failed", CreatedAt: first.Add(time.Minute)},
+		{EventName: "incident.created", Message: "incident created", CreatedAt: first},
+	}
+
+	env := DigestEnv(entries)
+	if env["count"] != 2 {
+		t.Errorf("count = %v, want 2", env["count"])
+	}
+	if since, _ := env["since"].(time.Time); !since.Equal(first) {
+		t.Errorf("since = %v, want %v", env["since"], first)
+	}
+	if list, _ := env["entries"].([]map[string]any); len(list) != 2 || list[0]["message"] != "check http failed" {
+		t.Errorf("entries = %v, want the messages in order", env["entries"])
+	}
+}
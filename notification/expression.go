@@ -16,7 +16,7 @@ import (
 var (
 	prgCache = cache.New(1*time.Hour, 1*time.Hour)
 
-	allEnvVars = []string{"check", "canary", "incident", "team", "responder", "comment", "evidence", "hypothesis"}
+	allEnvVars = []string{"check", "canary", "component", "incident", "team", "responder", "comment", "evidence", "hypothesis", "config_analysis", "config"}
 )
 
 type programCache struct {
@@ -60,6 +60,22 @@ func (t ExpressionRunner) Eval(ctx *api.Context, expression string) (bool, error
 	return strconv.ParseBool(fmt.Sprint(out))
 }
 
+// EvalString evaluates the given expression into a string.
+func (t ExpressionRunner) EvalString(ctx *api.Context, expression string) (string, error) {
+	prg, err := t.GetOrCompileCELProgram(ctx, expression)
+	if err != nil {
+		return "", err
+	}
+
+	out, _, err := (*prg).Eval(t.CelEnv)
+	if err != nil {
+		t.logToJobHistory(ctx, "ExpressionEval", fmt.Sprintf("%s: %s", expression, err.Error()))
+		return "", err
+	}
+
+	return fmt.Sprint(out.Value()), nil
+}
+
 // GetOrCompileCELProgram returns a cached or compiled cel.Program for the given cel expression.
 func (t ExpressionRunner) GetOrCompileCELProgram(ctx *api.Context, expression string) (*cel.Program, error) {
 	if prg, exists := prgCache.Get(expression); exists {
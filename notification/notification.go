@@ -35,10 +35,13 @@ func GetNotificationIDs(ctx *api.Context, eventName string) ([]string, error) {
 	return ids, nil
 }
 
-// A wrapper around notification that also contains the custom notifications.
+// A wrapper around notification that also contains the custom notifications
+// and the throttling settings.
 type NotificationWithSpec struct {
 	models.Notification
 	CustomNotifications []api.NotificationConfig
+	// Settings is nil if the notification isn't throttled
+	Settings *api.NotificationSettings
 }
 
 func GetNotification(ctx *api.Context, id string) (*NotificationWithSpec, error) {
@@ -61,10 +64,18 @@ func GetNotification(ctx *api.Context, id string) (*NotificationWithSpec, error)
 		return nil, err
 	}
 
+	var settings []api.NotificationSettings
+	if err := ctx.DB().Where("notification_id = ?", id).Find(&settings).Error; err != nil {
+		return nil, err
+	}
+
 	data := NotificationWithSpec{
 		Notification:        n,
 		CustomNotifications: customNotifications,
 	}
+	if len(settings) > 0 {
+		data.Settings = &settings[0]
+	}
 
 	notificationByIDCache.Set(id, &data, cache.DefaultExpiration)
 
@@ -1,6 +1,7 @@
 package notification_test
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -109,8 +110,8 @@ var _ = ginkgo.Describe("Test Notification on incident creation", ginkgo.Ordered
 
 		// Order of consumption is important as incident.create event
 		// produces a notification.send event
-		notifHandler.ConsumeEventsUntilEmpty()
-		sendHandler.ConsumeEventsUntilEmpty()
+		notifHandler.ConsumeEventsUntilEmpty(context.Background())
+		sendHandler.ConsumeEventsUntilEmpty(context.Background())
 
 		Expect(webhookPostdata).To(Not(BeNil()))
 		Expect(webhookPostdata["message"]).To(Equal(fmt.Sprintf("Severity: %s", incident.Severity)))
@@ -5,30 +5,28 @@ import (
 	"strings"
 
 	"github.com/containrrr/shoutrrr"
+	"github.com/containrrr/shoutrrr/pkg/router"
 	"github.com/containrrr/shoutrrr/pkg/types"
 	"github.com/flanksource/commons/collections"
 	"github.com/flanksource/incident-commander/api"
 )
 
-func Send(ctx *api.Context, connectionName, shoutrrrURL, message string, properties ...map[string]string) error {
+// Send sends the message with the shoutrrr service of the url or the connection
+// and returns the name of the service, e.g. slack.
+func Send(ctx *api.Context, connectionName, shoutrrrURL, message string, properties ...map[string]string) (string, error) {
 	if connectionName != "" {
 		connection, err := ctx.HydrateConnection(connectionName)
 		if err != nil {
-			return err
+			return "", err
 		}
 
 		shoutrrrURL = connection.URL
 		properties = append([]map[string]string{connection.Properties}, properties...)
 	}
 
-	sender, err := shoutrrr.CreateSender(shoutrrrURL)
+	sender, service, err := createSender(shoutrrrURL)
 	if err != nil {
-		return fmt.Errorf("failed to create a shoutrrr sender client: %w", err)
-	}
-
-	service, _, err := sender.ExtractServiceName(shoutrrrURL)
-	if err != nil {
-		return fmt.Errorf("failed to extract service name: %w", err)
+		return "", err
 	}
 
 	var allProps map[string]string
@@ -45,11 +43,43 @@ func Send(ctx *api.Context, connectionName, shoutrrrURL, message string, propert
 	sendErrors := sender.Send(message, params)
 	for _, err := range sendErrors {
 		if err != nil {
-			return fmt.Errorf("error publishing notification: %w", err)
+			return service, fmt.Errorf("error publishing notification: %w", err)
+		}
+	}
+
+	return service, nil
+}
+
+// ResolveService returns the name of the shoutrrr service the url or the connection
+// would send with, without sending anything.
+func ResolveService(ctx *api.Context, connectionName, shoutrrrURL string) (string, error) {
+	if connectionName != "" {
+		connection, err := ctx.HydrateConnection(connectionName)
+		if err != nil {
+			return "", err
+		} else if connection == nil {
+			return "", fmt.Errorf("connection %q is not a connection url", connectionName)
 		}
+
+		shoutrrrURL = connection.URL
+	}
+
+	_, service, err := createSender(shoutrrrURL)
+	return service, err
+}
+
+func createSender(shoutrrrURL string) (*router.ServiceRouter, string, error) {
+	sender, err := shoutrrr.CreateSender(shoutrrrURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create a shoutrrr sender client: %w", err)
+	}
+
+	service, _, err := sender.ExtractServiceName(shoutrrrURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to extract service name: %w", err)
 	}
 
-	return nil
+	return sender, service, nil
 }
 
 func getPropsForService(service string, property map[string]string) map[string]string {
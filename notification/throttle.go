@@ -0,0 +1,215 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/flanksource/incident-commander/api"
+)
+
+const defaultRateLimitPeriod = time.Hour
+
+// SendKey identifies the sends that are throttled together.
+type SendKey struct {
+	NotificationID uuid.UUID
+	// Recipient is the person, team or custom service notified
+	Recipient string
+	GroupKey  string
+}
+
+// GroupKey returns the group of a notification of an event: the values of the group by paths
+// in the event's environment, e.g. check.id, or the id of the event's resource without a group by.
+func GroupKey(groupBy []string, env map[string]any, resourceID string) string {
+	if len(groupBy) == 0 {
+		return resourceID
+	}
+
+	values := make([]string, 0, len(groupBy))
+	for _, path := range groupBy {
+		values = append(values, path+"="+fmt.Sprint(lookup(env, path)))
+	}
+	return strings.Join(values, ",")
+}
+
+func lookup(env map[string]any, path string) any {
+	var value any = env
+	for _, key := range strings.Split(path, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+type limits struct {
+	repeatInterval  time.Duration
+	rateLimit       int
+	rateLimitPeriod time.Duration
+}
+
+func parseLimits(settings api.NotificationSettings) (limits, error) {
+	l := limits{rateLimit: settings.RateLimit, rateLimitPeriod: defaultRateLimitPeriod}
+
+	var err error
+	if settings.RepeatInterval != "" {
+		if l.repeatInterval, err = time.ParseDuration(settings.RepeatInterval); err != nil {
+			return l, fmt.Errorf("invalid repeat interval %q: %w", settings.RepeatInterval, err)
+		}
+	}
+	if settings.RateLimitPeriod != "" {
+		if l.rateLimitPeriod, err = time.ParseDuration(settings.RateLimitPeriod); err != nil {
+			return l, fmt.Errorf("invalid rate limit period %q: %w", settings.RateLimitPeriod, err)
+		}
+	}
+	return l, nil
+}
+
+// allow updates the state with the send, or its suppression if the group was sent within the
+// repeat interval or the recipient has reached the rate limit, and returns whether it's allowed.
+func (l limits) allow(state *api.NotificationSendState, rate *api.NotificationRateLimit, now time.Time) bool {
+	suppress := l.repeatInterval > 0 && state.LastSentAt != nil && now.Sub(*state.LastSentAt) < l.repeatInterval
+
+	if !suppress && l.rateLimit > 0 {
+		if !now.Before(rate.WindowStart.Add(l.rateLimitPeriod)) {
+			rate.WindowStart, rate.Count = now, 0
+		}
+		if rate.Count >= l.rateLimit {
+			suppress = true
+		} else {
+			rate.Count++
+		}
+	}
+
+	if suppress {
+		state.Suppressed++
+		if state.SuppressedSince == nil {
+			state.SuppressedSince = &now
+		}
+		return false
+	}
+
+	state.LastSentAt = &now
+	state.Suppressed, state.SuppressedSince = 0, nil
+	return true
+}
+
+// Claim is a send allowed by the throttling of its notification.
+type Claim struct {
+	key SendKey
+	// Suppressed is the number of notifications of the group suppressed since SuppressedSince
+	Suppressed      int
+	SuppressedSince *time.Time
+	previousSentAt  *time.Time
+	rateLimited     bool
+}
+
+// Summary appends the number of notifications of the group that were suppressed since the last send to the message.
+func (c *Claim) Summary(message string) string {
+	if c == nil || c.Suppressed == 0 {
+		return message
+	}
+	return fmt.Sprintf("%s\n\n%d similar notification(s) were suppressed since %s", message, c.Suppressed, c.SuppressedSince.Format(time.RFC3339))
+}
+
+// ClaimSend decides with the persisted send state whether the notification can be sent now.
+// It returns nil if the send is suppressed, which is counted in the state of its group.
+// Without settings, every send is allowed.
+func ClaimSend(ctx *api.Context, settings *api.NotificationSettings, key SendKey, now time.Time) (*Claim, error) {
+	if settings == nil {
+		return &Claim{key: key}, nil
+	}
+
+	l, err := parseLimits(*settings)
+	if err != nil {
+		return nil, err
+	}
+
+	var claim *Claim
+	err = ctx.DB().Transaction(func(tx *gorm.DB) error {
+		// The queries are sessions to be safely reused for the lock and the update
+		stateQuery := tx.Where("notification_id = ? AND recipient = ? AND group_key = ?", key.NotificationID, key.Recipient, key.GroupKey).Session(&gorm.Session{})
+		state := api.NotificationSendState{NotificationID: key.NotificationID, Recipient: key.Recipient, GroupKey: key.GroupKey}
+		if err := lockOrCreate(tx, stateQuery, &state); err != nil {
+			return err
+		}
+
+		rateQuery := tx.Where("notification_id = ? AND recipient = ?", key.NotificationID, key.Recipient).Session(&gorm.Session{})
+		rate := api.NotificationRateLimit{NotificationID: key.NotificationID, Recipient: key.Recipient, WindowStart: now}
+		if l.rateLimit > 0 {
+			if err := lockOrCreate(tx, rateQuery, &rate); err != nil {
+				return err
+			}
+		}
+
+		previous := state
+		allowed := l.allow(&state, &rate, now)
+		err := stateQuery.Model(&api.NotificationSendState{}).Updates(map[string]any{
+			"last_sent_at":     state.LastSentAt,
+			"suppressed":       state.Suppressed,
+			"suppressed_since": state.SuppressedSince,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if l.rateLimit > 0 {
+			err := rateQuery.Model(&api.NotificationRateLimit{}).Updates(map[string]any{
+				"window_start": rate.WindowStart,
+				"count":        rate.Count,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if allowed {
+			claim = &Claim{
+				key:             key,
+				Suppressed:      previous.Suppressed,
+				SuppressedSince: previous.SuppressedSince,
+				previousSentAt:  previous.LastSentAt,
+				rateLimited:     l.rateLimit > 0,
+			}
+		}
+		return nil
+	})
+	return claim, err
+}
+
+// Release rolls back the claim of a send that failed, so that its retry isn't suppressed.
+func (c *Claim) Release(ctx *api.Context) error {
+	if c == nil {
+		return nil
+	}
+
+	return ctx.DB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&api.NotificationSendState{}).
+			Where("notification_id = ? AND recipient = ? AND group_key = ?", c.key.NotificationID, c.key.Recipient, c.key.GroupKey).
+			Updates(map[string]any{
+				"last_sent_at":     c.previousSentAt,
+				"suppressed":       gorm.Expr("suppressed + ?", c.Suppressed),
+				"suppressed_since": gorm.Expr("LEAST(suppressed_since, ?)", c.SuppressedSince),
+			}).Error
+		if err != nil || !c.rateLimited {
+			return err
+		}
+
+		return tx.Model(&api.NotificationRateLimit{}).
+			Where("notification_id = ? AND recipient = ?", c.key.NotificationID, c.key.Recipient).
+			Update("count", gorm.Expr("GREATEST(count - 1, 0)")).Error
+	})
+}
+
+// lockOrCreate locks the row of the query, creating it from the model first if it doesn't exist.
+func lockOrCreate(tx, query *gorm.DB, model any) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(model).Error; err != nil {
+		return err
+	}
+	return query.Clauses(clause.Locking{Strength: "UPDATE"}).First(model).Error
+}
@@ -0,0 +1,97 @@
+package notification
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flanksource/incident-commander/api"
+)
+
+func TestGroupKey(t *testing.T) {
+	env := map[string]any{
+		"check":  map[string]any{"id": "c1", "name": "http"},
+		"canary": map[string]any{"namespace": "prod"},
+	}
+
+	tests := []struct {
+		name    string
+		groupBy []string
+		want    string
+	}{
+		{name: "resource of the event", want: "c1"},
+		{name: "one path", groupBy: []string{"canary.namespace"}, want: "canary.namespace=prod"},
+		{name: "many paths", groupBy: []string{"canary.namespace", "check.name"}, want: "canary.namespace=prod,check.name=http"},
+		{name: "missing path", groupBy: []string{"incident.id"}, want: "incident.id=<nil>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GroupKey(tt.groupBy, env, "c1"); got != tt.want {
+				t.Errorf("GroupKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitsAllow(t *testing.T) {
+	start := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	tests := []struct {
+		name     string
+		settings api.NotificationSettings
+		sends    []int
+		want     []bool
+		// wantSuppressed is the number of suppressed sends after the last one
+		wantSuppressed int
+	}{
+		{
+			name:  "no limits",
+			sends: []int{0, 1, 2},
+			want:  []bool{true, true, true},
+		},
+		{
+			name:           "repeat interval",
+			settings:       api.NotificationSettings{RepeatInterval: "10m"},
+			sends:          []int{0, 1, 5, 10, 11},
+			want:           []bool{true, false, false, true, false},
+			wantSuppressed: 1,
+		},
+		{
+			name:           "rate limit",
+			settings:       api.NotificationSettings{RateLimit: 2, RateLimitPeriod: "1h"},
+			sends:          []int{0, 1, 2, 59, 60, 61},
+			want:           []bool{true, true, false, false, true, true},
+			wantSuppressed: 0,
+		},
+		{
+			name:           "repeat interval and rate limit",
+			settings:       api.NotificationSettings{RepeatInterval: "10m", RateLimit: 1},
+			sends:          []int{0, 5, 15, 61},
+			want:           []bool{true, false, false, true},
+			wantSuppressed: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := parseLimits(tt.settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var state api.NotificationSendState
+			rate := api.NotificationRateLimit{WindowStart: start}
+			for i, minutes := range tt.sends {
+				if got := l.allow(&state, &rate, at(minutes)); got != tt.want[i] {
+					t.Errorf("allow() at %dm = %v, want %v", minutes, got, tt.want[i])
+				}
+			}
+			if state.Suppressed != tt.wantSuppressed {
+				t.Errorf("suppressed = %d, want %d", state.Suppressed, tt.wantSuppressed)
+			}
+		})
+	}
+
+	if _, err := parseLimits(api.NotificationSettings{RepeatInterval: "often"}); err == nil {
+		t.Errorf("parseLimits() of an invalid repeat interval didn't fail")
+	}
+}
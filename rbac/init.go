@@ -42,9 +42,12 @@ const (
 	ActionCreate = "create"
 
 	// Objects
-	ObjectRBAC     = "rbac"
-	ObjectAuth     = "auth"
-	ObjectDatabase = "database"
+	ObjectRBAC         = "rbac"
+	ObjectAuth         = "auth"
+	ObjectDatabase     = "database"
+	ObjectEventQueue   = "event_queue"
+	ObjectRule         = "incident_rule"
+	ObjectNotification = "notification"
 
 	ObjectDatabaseResponder      = "database.responder"
 	ObjectDatabaseIncident       = "database.incident"
@@ -56,6 +59,8 @@ const (
 	ObjectDatabaseIdentity       = "database.identities"
 	ObjectDatabaseConnection     = "database.connections"
 	ObjectDatabaseKratosTable    = "database.kratos"
+	ObjectDatabaseSilence        = "database.silences"
+	ObjectDatabaseSuppression    = "database.silence_suppressions"
 )
 
 var Enforcer *casbin.Enforcer
@@ -103,6 +108,11 @@ func Init(adminUserID string) error {
 		{RoleAdmin, ObjectDatabaseConnection, ActionRead},
 		{RoleAdmin, ObjectDatabaseConnection, ActionCreate},
 		{RoleAdmin, ObjectDatabaseConnection, ActionUpdate},
+		{RoleAdmin, ObjectEventQueue, ActionRead},
+		{RoleAdmin, ObjectEventQueue, ActionWrite},
+		{RoleAdmin, ObjectRule, ActionRead},
+		{RoleAdmin, ObjectNotification, ActionRead},
+		{RoleAdmin, ObjectNotification, ActionWrite},
 
 		{RoleEditor, ObjectDatabaseCanary, ActionCreate},
 		{RoleEditor, ObjectDatabaseCanary, ActionUpdate},
@@ -113,6 +123,10 @@ func Init(adminUserID string) error {
 		{RoleEditor, ObjectDatabaseConfigScraper, ActionCreate},
 		{RoleEditor, ObjectDatabaseConfigScraper, ActionUpdate},
 		{RoleEditor, ObjectDatabaseConfigScraper, ActionRead},
+		{RoleEditor, ObjectDatabaseSilence, ActionCreate},
+		{RoleEditor, ObjectDatabaseSilence, ActionUpdate},
+		{RoleEditor, ObjectEventQueue, ActionRead},
+		{RoleEditor, ObjectRule, ActionRead},
 
 		{RoleCommander, ObjectDatabaseResponder, ActionCreate},
 		{RoleCommander, ObjectDatabaseIncident, ActionCreate},
@@ -121,6 +135,7 @@ func Init(adminUserID string) error {
 		{RoleCommander, ObjectDatabaseEvidence, ActionUpdate},
 
 		{RoleResponder, ObjectDatabaseComment, ActionCreate},
+		{RoleResponder, ObjectNotification, ActionRead},
 		{RoleResponder, ObjectDatabaseIncident, ActionUpdate},
 
 		{RoleViewer, ObjectDatabase, ActionRead},
@@ -30,6 +30,8 @@ var dbResourceObjMap = map[string]string{
 	"selfservice_settings_flows":      ObjectDatabaseKratosTable,
 	"selfservice_verification_flows":  ObjectDatabaseKratosTable,
 	"courier_messages":                ObjectDatabaseKratosTable,
+	"silences":                        ObjectDatabaseSilence,
+	"silence_suppressions":            ObjectDatabaseSuppression,
 }
 
 var dbReadDenied = []string{
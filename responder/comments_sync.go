@@ -1,17 +1,29 @@
 package responder
 
 import (
-	"database/sql"
+	"fmt"
+	"strings"
+	"time"
 
-	"github.com/flanksource/commons/collections"
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/duty/models"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 
 	"github.com/flanksource/incident-commander/api"
 	"github.com/flanksource/incident-commander/db"
 )
 
+var (
+	// FullCommentSyncInterval is how often all the comments of a responder are fetched.
+	// Deletions on the responder are only detected by a full sync.
+	FullCommentSyncInterval = 24 * time.Hour
+
+	// commentSyncOverlap is subtracted from the cursor to allow for
+	// a clock skew between us and the responder.
+	commentSyncOverlap = 5 * time.Minute
+)
+
 func getRootHypothesisOfIncident(incidentID uuid.UUID) (api.Hypothesis, error) {
 	var hypothesis api.Hypothesis
 	if err := db.Gorm.Where("incident_id = ? AND type = ?", incidentID, "root").First(&hypothesis).Error; err != nil {
@@ -31,12 +43,6 @@ func SyncComments() {
 		return
 	}
 
-	dbSelectExternalIDQuery := `
-        SELECT external_id FROM comments WHERE responder_id = @responder_id
-        UNION
-        SELECT external_id FROM comment_responders WHERE responder_id = @responder_id
-    `
-
 	jobHistory := models.NewJobHistory("ResponderCommentSync", "", "")
 	_ = db.PersistJobHistory(ctx, jobHistory.Start())
 	for _, responder := range responders {
@@ -45,50 +51,140 @@ func SyncComments() {
 			continue
 		}
 
-		responderClient, err := GetResponder(ctx, responder.Team)
-		if err != nil {
-			logger.Errorf("Error getting responder: %v", err)
+		if err := syncResponderComments(ctx, responder); err != nil {
+			logger.Errorf("Error syncing comments of responder %s: %v", responder.ID, err)
 			jobHistory.AddError(err.Error())
 			continue
 		}
+		jobHistory.IncrSuccess()
+	}
+	_ = db.PersistJobHistory(ctx, jobHistory.End())
+}
 
-		comments, err := responderClient.GetComments(responder.ExternalID)
-		if err != nil {
-			logger.Errorf("Error fetching comments from responder: %v", err)
-			jobHistory.AddError(err.Error())
-			continue
-		}
+// syncResponderComments pulls the comments created, updated or deleted on the responder
+// since the last sync. Changes are propagated to the other responders by the comment triggers.
+func syncResponderComments(ctx *api.Context, responder api.Responder) error {
+	responderClient, _, err := GetResponderFor(ctx, responder)
+	if err != nil {
+		return err
+	}
 
-		// Query all external_ids from comments and comment_responders table
-		var dbExternalIDs []string
-		err = db.Gorm.Raw(dbSelectExternalIDQuery, sql.Named("responder_id", responder.ID)).Find(&dbExternalIDs).Error
-		if err != nil {
-			logger.Errorf("Error querying external_ids from database: %v", err)
-			jobHistory.AddError(err.Error())
-			continue
+	state, err := db.GetResponderSyncState(ctx, responder.ID)
+	if err != nil {
+		return err
+	}
+
+	startedAt := time.Now()
+	full := state == nil || state.CommentsSyncedAt == nil || state.CommentsFullSyncedAt == nil ||
+		time.Since(*state.CommentsFullSyncedAt) > FullCommentSyncInterval
+
+	var since *time.Time
+	if !full {
+		s := state.CommentsSyncedAt.Add(-commentSyncOverlap)
+		since = &s
+	}
+
+	comments, err := responderClient.GetComments(responder.ExternalID, since)
+	if err != nil {
+		return fmt.Errorf("error fetching comments from responder: %w", err)
+	}
+
+	linked, err := db.GetResponderComments(ctx, responder.ID)
+	if err != nil {
+		return fmt.Errorf("error querying linked comments from database: %w", err)
+	}
+	linkedByExternalID := make(map[string]db.ResponderComment, len(linked))
+	for _, c := range linked {
+		linkedByExternalID[c.ExternalID] = c
+	}
+
+	seen := make(map[string]struct{}, len(comments))
+	for _, responderComment := range comments {
+		seen[responderComment.ExternalID] = struct{}{}
+
+		if err := upsertResponderComment(responder, linkedByExternalID, responderComment); err != nil {
+			logger.Errorf("Error saving comment %s of responder %s: %v", responderComment.ExternalID, responder.ID, err)
 		}
+	}
+
+	if full {
+		for externalID, existing := range linkedByExternalID {
+			if _, ok := seen[externalID]; ok {
+				continue
+			}
 
-		// IDs which are in Jira but not added to database must be added in the comments table
-		for _, responderComment := range comments {
-			if !collections.Contains(dbExternalIDs, responderComment.ExternalID) {
-				rootHypothesis, err := getRootHypothesisOfIncident(responder.IncidentID)
-				if err != nil {
-					logger.Errorf("Error fetching hypothesis from database: %v", err)
-					continue
-				}
-				responderComment.IncidentID = responder.IncidentID
-				responderComment.CreatedBy = *api.SystemUserID
-				responderComment.ResponderID = &responder.ID
-				responderComment.HypothesisID = &rootHypothesis.ID
-
-				err = db.Gorm.Create(&responderComment).Error
-				if err != nil {
-					logger.Errorf("Error inserting comment in database: %v", err)
-					continue
-				}
+			if err := removeDeletedResponderComment(responder, existing); err != nil {
+				logger.Errorf("Error removing comment %s deleted on the responder: %v", existing.CommentID, err)
 			}
 		}
 	}
-	jobHistory.IncrSuccess()
-	_ = db.PersistJobHistory(ctx, jobHistory.End())
+
+	return db.SaveResponderCommentCursor(ctx, responder.ID, startedAt, full)
+}
+
+// upsertResponderComment inserts a comment of the responder
+// or updates the comment it's linked to if its text was edited on the responder.
+func upsertResponderComment(responder api.Responder, linkedByExternalID map[string]db.ResponderComment, responderComment api.Comment) error {
+	existing, ok := linkedByExternalID[responderComment.ExternalID]
+	if !ok {
+		return insertResponderComment(responder, responderComment)
+	}
+
+	if strings.TrimSpace(existing.Comment) == strings.TrimSpace(responderComment.Comment) {
+		return nil
+	}
+
+	// The latest edit wins. An edit made here that hasn't been pushed to the responder yet
+	// is newer than the responder's copy, so it isn't overwritten by the old text.
+	responderUpdatedAt := responderComment.CreatedAt
+	if responderComment.UpdatedAt != nil {
+		responderUpdatedAt = *responderComment.UpdatedAt
+	}
+	if !responderUpdatedAt.After(existing.UpdatedAt) {
+		return nil
+	}
+
+	return db.Gorm.Transaction(func(tx *gorm.DB) error {
+		// Recorded on the comment.updated event so that the edit isn't pushed back to the responder
+		if err := tx.Exec("SELECT set_config('incident_commander.comment_origin', ?, true)", responder.ID.String()).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&api.Comment{}).Where("id = ?", existing.CommentID).
+			Updates(map[string]any{"comment": responderComment.Comment, "updated_at": gorm.Expr("NOW()")}).Error
+	})
+}
+
+func insertResponderComment(responder api.Responder, responderComment api.Comment) error {
+	rootHypothesis, err := getRootHypothesisOfIncident(responder.IncidentID)
+	if err != nil {
+		return fmt.Errorf("error fetching hypothesis from database: %w", err)
+	}
+
+	responderComment.IncidentID = responder.IncidentID
+	responderComment.CreatedBy = *api.SystemUserID
+	responderComment.ResponderID = &responder.ID
+	responderComment.HypothesisID = &rootHypothesis.ID
+	return db.Gorm.Create(&responderComment).Error
+}
+
+// removeDeletedResponderComment handles a comment that was deleted on the responder.
+//
+// A comment that was pulled from the responder is deleted, which in turn deletes it
+// from the other responders. A comment that was pushed to the responder is only unlinked
+// from it so that a deletion on one responder doesn't remove comments written here.
+func removeDeletedResponderComment(responder api.Responder, comment db.ResponderComment) error {
+	return db.Gorm.Transaction(func(tx *gorm.DB) error {
+		if !comment.Source {
+			return tx.Exec("DELETE FROM comment_responders WHERE comment_id = ? AND responder_id = ?", comment.CommentID, responder.ID).Error
+		}
+
+		if err := tx.Delete(&api.Comment{}, "id = ?", comment.CommentID).Error; err != nil {
+			return err
+		}
+
+		// It's already gone from this responder
+		return tx.Where("comment_id = ? AND responder_id = ?", comment.CommentID, responder.ID).
+			Delete(&api.CommentResponderDeletion{}).Error
+	})
 }
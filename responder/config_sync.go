@@ -30,6 +30,16 @@ func upsertConfig(configType, externalID, name, config string) error {
 	return nil
 }
 
+// configExternalID identifies the config of the team's responder client.
+// The clients set directly on the team's responder clients keep using the team's id
+// since the configs are also keyed by their type.
+func configExternalID(team api.Team, client api.ResponderClient) string {
+	if client.Name == client.Type() {
+		return team.ID.String()
+	}
+	return team.ID.String() + "/" + client.Name
+}
+
 func SyncConfig() {
 	logger.Debugf("Syncing responder config")
 	ctx := api.NewContext(db.Gorm, nil)
@@ -48,29 +58,36 @@ func SyncConfig() {
 		jobHistory := models.NewJobHistory("TeamResponderConfigSync", "team", team.ID.String())
 		_ = db.PersistJobHistory(ctx, jobHistory.Start())
 
-		defer func() {
-			_ = db.PersistJobHistory(ctx, jobHistory.End())
-		}()
-
-		responder, err := GetResponder(ctx, team)
+		clients, err := getResponderClients(team)
 		if err != nil {
-			logger.Errorf("Error getting responder: %v", err)
-			jobHistory.AddError(err.Error()).End()
+			logger.Errorf("Error getting responder clients: %v", err)
+			_ = db.PersistJobHistory(ctx, jobHistory.AddError(err.Error()).End())
 			continue
 		}
 
-		if configType, configName, config, err := responder.SyncConfig(ctx, team); err != nil {
-			logger.Errorf("Error syncing config: %v", err)
-			jobHistory.AddError(err.Error()).End()
-			continue
-		} else {
-			if err := upsertConfig(configType, team.ID.String(), configName, config); err != nil {
+		for _, client := range clients.All() {
+			responder, err := GetResponder(ctx, team, client.Name)
+			if err != nil {
+				logger.Errorf("Error getting responder: %v", err)
+				jobHistory.AddError(err.Error())
+				continue
+			}
+
+			configType, configName, config, err := responder.SyncConfig(ctx)
+			if err != nil {
+				logger.Errorf("Error syncing config: %v", err)
+				jobHistory.AddError(err.Error())
+				continue
+			}
+
+			if err := upsertConfig(configType, configExternalID(team, client), configName, config); err != nil {
 				logger.Errorf("Error upserting config: %v", err)
-				jobHistory.AddError(err.Error()).End()
+				jobHistory.AddError(err.Error())
 				continue
 			}
 			jobHistory.IncrSuccess()
-
 		}
+
+		_ = db.PersistJobHistory(ctx, jobHistory.End())
 	}
 }
@@ -0,0 +1,17 @@
+package github
+
+import (
+	"github.com/flanksource/incident-commander/api"
+	"github.com/pkg/errors"
+)
+
+func (gc *GithubClient) SyncConfig(ctx *api.Context) (configType string, configName string, config string, err error) {
+	config, err = gc.GetConfigJSON()
+	if err != nil {
+		return "", "", "", errors.Wrap(err, "error generating config from Github")
+	}
+
+	configName = gc.config.Values["repository"]
+	configType = ResponderType
+	return
+}
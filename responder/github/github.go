@@ -0,0 +1,433 @@
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/incident-commander/api"
+	"github.com/patrickmn/go-cache"
+)
+
+const ResponderType = "github"
+
+const defaultURL = "https://api.github.com"
+
+// pageSize is the maximum number of items GitHub returns per page
+const pageSize = 100
+
+// labelsCache holds the label names of the repositories
+var labelsCache = cache.New(6*time.Hour, time.Hour)
+
+type GithubIssue struct {
+	// Repository in the form owner/name
+	Repository string
+	Title      string
+	Body       string
+	// Labels is a comma separated list of labels
+	Labels string
+}
+
+func (i GithubIssue) labels() []string {
+	var labels []string
+	for _, label := range strings.Split(i.Labels, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+type GithubRepository struct {
+	Name   string   `json:"name"`
+	Labels []string `json:"labels"`
+}
+
+type GithubConfig struct {
+	Repositories map[string]GithubRepository `json:"repositories"`
+}
+
+type GithubClient struct {
+	client *http.Client
+	url    string
+	token  string
+	owner  string
+	config api.GithubClient
+}
+
+type issue struct {
+	Number    int       `json:"number"`
+	State     string    `json:"state"`
+	Body      string    `json:"body"`
+	HTMLURL   string    `json:"html_url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type comment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type repository struct {
+	FullName string `json:"full_name"`
+	Owner    struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+type label struct {
+	Name string `json:"name"`
+}
+
+func NewClient(ctx *api.Context, config api.GithubClient) (*GithubClient, error) {
+	token, err := ctx.GetEnvVarValue(config.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	client := newClient(token, config.Url, config.Owner)
+	client.config = config
+	return client, nil
+}
+
+func newClient(token, url, owner string) *GithubClient {
+	if url == "" {
+		url = defaultURL
+	}
+
+	return &GithubClient{
+		client: &http.Client{Timeout: time.Minute},
+		url:    strings.TrimSuffix(url, "/"),
+		token:  token,
+		owner:  owner,
+	}
+}
+
+// IssueRef returns the external id of an issue.
+func IssueRef(repository string, number int) string {
+	return fmt.Sprintf("%s#%d", repository, number)
+}
+
+// ParseIssueRef splits the external id of an issue into the repository and the issue number.
+func ParseIssueRef(ref string) (string, int, error) {
+	repository, numberRaw, ok := strings.Cut(ref, "#")
+	if !ok || repository == "" {
+		return "", 0, fmt.Errorf("invalid github issue reference: %q", ref)
+	}
+
+	number, err := strconv.Atoi(numberRaw)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid github issue reference: %q", ref)
+	}
+	return repository, number, nil
+}
+
+// errNotFound is returned by do when the resource doesn't exist
+var errNotFound = fmt.Errorf("not found")
+
+// do sends a request to the GitHub REST API and decodes the response into out.
+func (gc *GithubClient) do(method, path string, query url.Values, body any, out any) error {
+	endpoint := gc.url + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, endpoint, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if gc.token != "" {
+		req.Header.Set("Authorization", "Bearer "+gc.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := gc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var response struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&response)
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("github returned %d: %s: %w", resp.StatusCode, response.Message, errNotFound)
+		}
+		return fmt.Errorf("github returned %d: %s", resp.StatusCode, response.Message)
+	}
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// CreateIssue opens an issue and returns its reference.
+func (gc *GithubClient) CreateIssue(opts GithubIssue) (string, error) {
+	body := map[string]any{
+		"title": opts.Title,
+		"body":  opts.Body,
+	}
+	if labels := opts.labels(); len(labels) > 0 {
+		body["labels"] = labels
+	}
+
+	var created issue
+	if err := gc.do(http.MethodPost, fmt.Sprintf("/repos/%s/issues", opts.Repository), nil, body, &created); err != nil {
+		return "", err
+	}
+
+	logger.Debugf("[Github] Issue created in repository: [%s] with number: [%d] - [%s]", opts.Repository, created.Number, opts.Title)
+	return IssueRef(opts.Repository, created.Number), nil
+}
+
+// FindIssueByMarker returns the reference of an issue in the repository, updated since the given time,
+// whose body contains the marker.
+// Returns an empty string if there's no such issue.
+func (gc *GithubClient) FindIssueByMarker(repository, marker string, since time.Time) (string, error) {
+	for page := 1; ; page++ {
+		query := url.Values{
+			"state":    []string{"all"},
+			"since":    []string{since.UTC().Format(time.RFC3339)},
+			"per_page": []string{strconv.Itoa(pageSize)},
+			"page":     []string{strconv.Itoa(page)},
+		}
+
+		var issues []issue
+		if err := gc.do(http.MethodGet, fmt.Sprintf("/repos/%s/issues", repository), query, nil, &issues); err != nil {
+			return "", err
+		}
+
+		for _, i := range issues {
+			if strings.Contains(i.Body, marker) {
+				return IssueRef(repository, i.Number), nil
+			}
+		}
+
+		if len(issues) < pageSize {
+			return "", nil
+		}
+	}
+}
+
+// GetIssueState returns the state of the issue: open or closed
+func (gc *GithubClient) GetIssueState(issueRef string) (string, error) {
+	repository, number, err := ParseIssueRef(issueRef)
+	if err != nil {
+		return "", err
+	}
+
+	var i issue
+	if err := gc.do(http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d", repository, number), nil, nil, &i); err != nil {
+		return "", err
+	}
+	return i.State, nil
+}
+
+func (gc *GithubClient) SetIssueState(issueRef, state string) error {
+	repository, number, err := ParseIssueRef(issueRef)
+	if err != nil {
+		return err
+	}
+
+	if err := gc.do(http.MethodPatch, fmt.Sprintf("/repos/%s/issues/%d", repository, number), nil, map[string]string{"state": state}, nil); err != nil {
+		return err
+	}
+
+	logger.Debugf("[Github] Issue: [%s] moved to state: [%s]", issueRef, state)
+	return nil
+}
+
+func (gc *GithubClient) AddComment(issueRef, body string) (string, error) {
+	repository, number, err := ParseIssueRef(issueRef)
+	if err != nil {
+		return "", err
+	}
+
+	var created comment
+	if err := gc.do(http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", repository, number), nil, map[string]string{"body": body}, &created); err != nil {
+		return "", err
+	}
+
+	logger.Debugf("[Github] Comment: [%s] added for issue: [%s]", body, issueRef)
+	return strconv.FormatInt(created.ID, 10), nil
+}
+
+func (gc *GithubClient) EditComment(issueRef, commentID, body string) error {
+	repository, _, err := ParseIssueRef(issueRef)
+	if err != nil {
+		return err
+	}
+
+	return gc.do(http.MethodPatch, fmt.Sprintf("/repos/%s/issues/comments/%s", repository, commentID), nil, map[string]string{"body": body}, nil)
+}
+
+func (gc *GithubClient) RemoveComment(issueRef, commentID string) error {
+	repository, _, err := ParseIssueRef(issueRef)
+	if err != nil {
+		return err
+	}
+
+	err = gc.do(http.MethodDelete, fmt.Sprintf("/repos/%s/issues/comments/%s", repository, commentID), nil, nil, nil)
+	if errors.Is(err, errNotFound) {
+		// Already deleted
+		return nil
+	}
+	return err
+}
+
+// GetComments returns the comments of the issue.
+// When since is set, only the comments updated since are returned.
+func (gc *GithubClient) GetComments(issueRef string, since *time.Time) ([]api.Comment, error) {
+	repository, number, err := ParseIssueRef(issueRef)
+	if err != nil {
+		return nil, err
+	}
+
+	var comments []api.Comment
+	for page := 1; ; page++ {
+		query := url.Values{
+			"per_page": []string{strconv.Itoa(pageSize)},
+			"page":     []string{strconv.Itoa(page)},
+		}
+		if since != nil {
+			query.Set("since", since.UTC().Format(time.RFC3339))
+		}
+
+		var issueComments []comment
+		if err := gc.do(http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d/comments", repository, number), query, nil, &issueComments); err != nil {
+			return nil, err
+		}
+
+		for _, c := range issueComments {
+			updatedAt := c.UpdatedAt
+			comments = append(comments, api.Comment{
+				ExternalID:        strconv.FormatInt(c.ID, 10),
+				Comment:           c.Body,
+				ExternalCreatedBy: c.User.Login,
+				CreatedAt:         c.CreatedAt,
+				UpdatedAt:         &updatedAt,
+			})
+		}
+
+		if len(issueComments) < pageSize {
+			return comments, nil
+		}
+	}
+}
+
+func (gc *GithubClient) GetConfig() (GithubConfig, error) {
+	var repositories []repository
+	for page := 1; ; page++ {
+		query := url.Values{
+			"per_page": []string{strconv.Itoa(pageSize)},
+			"page":     []string{strconv.Itoa(page)},
+		}
+
+		var result []repository
+		if err := gc.do(http.MethodGet, "/user/repos", query, nil, &result); err != nil {
+			return GithubConfig{}, err
+		}
+		repositories = append(repositories, result...)
+
+		if len(result) < pageSize {
+			break
+		}
+	}
+
+	config := GithubConfig{Repositories: make(map[string]GithubRepository)}
+	for _, repo := range repositories {
+		if gc.owner != "" && !strings.EqualFold(repo.Owner.Login, gc.owner) {
+			continue
+		}
+
+		labelNames, err := gc.getLabels(repo.FullName)
+		if err != nil {
+			return GithubConfig{}, err
+		}
+
+		config.Repositories[repo.FullName] = GithubRepository{
+			Name:   repo.FullName,
+			Labels: labelNames,
+		}
+	}
+
+	return config, nil
+}
+
+// getLabels returns the names of the labels of the repository.
+// The labels rarely change, so they're cached instead of being fetched for every repository on each config sync.
+func (gc *GithubClient) getLabels(repository string) ([]string, error) {
+	cacheKey := gc.url + "/" + repository
+	if val, found := labelsCache.Get(cacheKey); found {
+		return val.([]string), nil
+	}
+
+	var labelNames []string
+	for page := 1; ; page++ {
+		query := url.Values{
+			"per_page": []string{strconv.Itoa(pageSize)},
+			"page":     []string{strconv.Itoa(page)},
+		}
+
+		var labels []label
+		if err := gc.do(http.MethodGet, fmt.Sprintf("/repos/%s/labels", repository), query, nil, &labels); err != nil {
+			return nil, err
+		}
+		for _, l := range labels {
+			labelNames = append(labelNames, l.Name)
+		}
+
+		if len(labels) < pageSize {
+			break
+		}
+	}
+
+	labelsCache.SetDefault(cacheKey, labelNames)
+	return labelNames, nil
+}
+
+func (gc *GithubClient) GetConfigJSON() (string, error) {
+	config, err := gc.GetConfig()
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(&config)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
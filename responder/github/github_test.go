@@ -0,0 +1,230 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/google/uuid"
+)
+
+func TestParseIssueRef(t *testing.T) {
+	tests := []struct {
+		ref        string
+		repository string
+		number     int
+		wantErr    bool
+	}{
+		{ref: "flanksource/incident-commander#42", repository: "flanksource/incident-commander", number: 42},
+		{ref: "flanksource/incident-commander", wantErr: true},
+		{ref: "#42", wantErr: true},
+		{ref: "flanksource/incident-commander#abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			repository, number, err := ParseIssueRef(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseIssueRef() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if repository != tt.repository || number != tt.number {
+				t.Errorf("ParseIssueRef() = %s, %d, want %s, %d", repository, number, tt.repository, tt.number)
+			}
+		})
+	}
+}
+
+func TestGithubClient(t *testing.T) {
+	var issues []map[string]any
+	var comments []map[string]any
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/flanksource/demo/issues", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]string{"message": "Bad credentials"})
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			body["number"] = len(issues) + 1
+			issues = append(issues, body)
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(body)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(issues)
+	})
+	mux.HandleFunc("/repos/flanksource/demo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			body["id"] = 1000 + len(comments)
+			body["user"] = map[string]string{"login": "octocat"}
+			body["created_at"] = fmt.Sprintf("2023-07-01T10:00:%02dZ", len(comments))
+			body["updated_at"] = body["created_at"]
+			comments = append(comments, body)
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(body)
+			return
+		}
+		since := r.URL.Query().Get("since")
+		var out []map[string]any
+		for _, c := range comments {
+			if c["updated_at"].(string) >= since {
+				out = append(out, c)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(out)
+	})
+	mux.HandleFunc("/repos/flanksource/demo/issues/comments/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/repos/flanksource/demo/issues/comments/")
+		for i, c := range comments {
+			if fmt.Sprint(c["id"]) != id {
+				continue
+			}
+
+			if r.Method == http.MethodDelete {
+				comments = append(comments[:i], comments[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			c["body"] = body["body"]
+			c["updated_at"] = "2023-07-02T10:00:00Z"
+			_ = json.NewEncoder(w).Encode(c)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": "Not Found"})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := newClient("token", server.URL, "")
+	responder := api.Responder{
+		ID: uuid.New(),
+		Properties: map[string]string{
+			"responderType": ResponderType,
+			"repository":    "flanksource/demo",
+			"title":         "Database is down",
+			"body":          "Connections are being refused",
+			"labels":        "incident, p1",
+		},
+	}
+
+	externalID, err := client.NotifyResponder(nil, responder)
+	if err != nil {
+		t.Fatalf("NotifyResponder() error = %v", err)
+	}
+	if externalID != "flanksource/demo#1" {
+		t.Errorf("NotifyResponder() = %q, want flanksource/demo#1", externalID)
+	}
+	if labels := issues[0]["labels"].([]any); len(labels) != 2 || labels[1] != "p1" {
+		t.Errorf("NotifyResponder() created issue with labels %v", labels)
+	}
+
+	foundID, err := client.FindExternalID(nil, responder, api.ResponderReservation{IdempotencyKey: responder.IdempotencyKey()})
+	if err != nil {
+		t.Fatalf("FindExternalID() error = %v", err)
+	}
+	if foundID != externalID {
+		t.Errorf("FindExternalID() = %q, want %q", foundID, externalID)
+	}
+
+	responder.ExternalID = externalID
+	commentID, err := client.NotifyResponderAddComment(nil, responder, "looking into it")
+	if err != nil {
+		t.Fatalf("NotifyResponderAddComment() error = %v", err)
+	}
+
+	synced, err := client.GetComments(externalID, nil)
+	if err != nil {
+		t.Fatalf("GetComments() error = %v", err)
+	}
+	if len(synced) != 1 || synced[0].ExternalID != commentID || synced[0].ExternalCreatedBy != "octocat" || synced[0].CreatedAt.IsZero() {
+		t.Errorf("GetComments() = %+v", synced)
+	}
+
+	if err := client.UpdateComment(nil, responder, commentID, "found the cause"); err != nil {
+		t.Fatalf("UpdateComment() error = %v", err)
+	}
+	since := time.Date(2023, 7, 2, 0, 0, 0, 0, time.UTC)
+	synced, err = client.GetComments(externalID, &since)
+	if err != nil {
+		t.Fatalf("GetComments() since error = %v", err)
+	}
+	if len(synced) != 1 || synced[0].Comment != "found the cause" || synced[0].UpdatedAt == nil {
+		t.Errorf("GetComments() since = %+v, want the updated comment", synced)
+	}
+
+	if err := client.DeleteComment(nil, responder, commentID); err != nil {
+		t.Fatalf("DeleteComment() error = %v", err)
+	}
+	if err := client.DeleteComment(nil, responder, commentID); err != nil {
+		t.Errorf("DeleteComment() of a deleted comment error = %v", err)
+	}
+	if synced, _ := client.GetComments(externalID, nil); len(synced) != 0 {
+		t.Errorf("GetComments() after delete = %+v", synced)
+	}
+
+	if _, err := newClient("wrong", server.URL, "").NotifyResponder(nil, responder); err == nil || !strings.Contains(err.Error(), "Bad credentials") {
+		t.Errorf("NotifyResponder() with bad credentials error = %v", err)
+	}
+}
+
+func TestGetConfig(t *testing.T) {
+	labelRequests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/user/repos", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]map[string]any{
+			{"full_name": "flanksource/labels", "owner": map[string]string{"login": "flanksource"}},
+			{"full_name": "octocat/other", "owner": map[string]string{"login": "octocat"}},
+		})
+	})
+	mux.HandleFunc("/repos/flanksource/labels/labels", func(w http.ResponseWriter, r *http.Request) {
+		labelRequests++
+
+		// 150 labels over two pages
+		count := pageSize
+		if r.URL.Query().Get("page") == "2" {
+			count = 50
+		}
+		labels := make([]map[string]string, count)
+		for i := range labels {
+			labels[i] = map[string]string{"name": fmt.Sprintf("page%s-%d", r.URL.Query().Get("page"), i)}
+		}
+		_ = json.NewEncoder(w).Encode(labels)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := newClient("token", server.URL, "flanksource")
+	for i := 0; i < 2; i++ {
+		config, err := client.GetConfig()
+		if err != nil {
+			t.Fatalf("GetConfig() error = %v", err)
+		}
+		if len(config.Repositories) != 1 {
+			t.Fatalf("GetConfig() = %v, want only the repositories of the owner", config.Repositories)
+		}
+		if labels := config.Repositories["flanksource/labels"].Labels; len(labels) != 150 || labels[149] != "page2-49" {
+			t.Errorf("GetConfig() labels = %d, want the labels of all the pages", len(labels))
+		}
+	}
+
+	if labelRequests != 2 {
+		t.Errorf("labels were requested %d times, want them fetched once per page and then cached", labelRequests)
+	}
+}
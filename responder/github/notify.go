@@ -0,0 +1,75 @@
+package github
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/mitchellh/mapstructure"
+)
+
+func (gc *GithubClient) NotifyResponder(ctx *api.Context, responder api.Responder) (string, error) {
+	issueOptions, err := decodeIssue(responder)
+	if err != nil {
+		return "", err
+	}
+
+	// The idempotency key is hidden in the body so that the issue can be found on retries
+	issueOptions.Body += "\n\n" + marker(responder.IdempotencyKey())
+	return gc.CreateIssue(issueOptions)
+}
+
+func (gc *GithubClient) NotifyResponderAddComment(ctx *api.Context, responder api.Responder, comment string) (string, error) {
+	if responder.Properties["responderType"] != ResponderType {
+		return "", fmt.Errorf("invalid responderType: %s", responder.Properties["responderType"])
+	}
+
+	return gc.AddComment(responder.ExternalID, comment)
+}
+
+func (gc *GithubClient) UpdateComment(ctx *api.Context, responder api.Responder, commentID string, comment string) error {
+	return gc.EditComment(responder.ExternalID, commentID, comment)
+}
+
+func (gc *GithubClient) DeleteComment(ctx *api.Context, responder api.Responder, commentID string) error {
+	return gc.RemoveComment(responder.ExternalID, commentID)
+}
+
+func (gc *GithubClient) UpdateStatus(ctx *api.Context, responder api.Responder, state string) error {
+	return gc.SetIssueState(responder.ExternalID, state)
+}
+
+func (gc *GithubClient) GetStatus(ctx *api.Context, responder api.Responder) (string, error) {
+	return gc.GetIssueState(responder.ExternalID)
+}
+
+func (gc *GithubClient) FindExternalID(ctx *api.Context, responder api.Responder, reservation api.ResponderReservation) (string, error) {
+	issueOptions, err := decodeIssue(responder)
+	if err != nil {
+		return "", err
+	}
+
+	// Allow for a clock skew between us and GitHub
+	return gc.FindIssueByMarker(issueOptions.Repository, marker(reservation.IdempotencyKey), reservation.CreatedAt.Add(-time.Minute))
+}
+
+// marker is rendered as an invisible html comment in the issue body
+func marker(idempotencyKey string) string {
+	return fmt.Sprintf("<!-- %s -->", idempotencyKey)
+}
+
+func decodeIssue(responder api.Responder) (GithubIssue, error) {
+	if responder.Properties["responderType"] != ResponderType {
+		return GithubIssue{}, fmt.Errorf("invalid responderType: %s", responder.Properties["responderType"])
+	}
+
+	var issueOptions GithubIssue
+	if err := mapstructure.Decode(responder.Properties, &issueOptions); err != nil {
+		return GithubIssue{}, err
+	}
+
+	if issueOptions.Repository == "" {
+		return GithubIssue{}, fmt.Errorf("repository is required")
+	}
+	return issueOptions, nil
+}
@@ -2,6 +2,8 @@ package jira
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/flanksource/commons/collections"
@@ -19,6 +21,7 @@ type JiraIssue struct {
 	Description string
 	IssueType   string
 	Priority    string
+	Labels      []string `mapstructure:"-"`
 }
 
 type JiraProject struct {
@@ -41,27 +44,29 @@ type JiraConfig struct {
 
 type JiraClient struct {
 	client *jira.Client
+	config api.JiraClient
 }
 
 // Jira does not support creating an issue of
 // type Sub-task so we do not set it in config
 var IssueTypeExcludeList = []string{"Sub-task"}
 
-func NewClient(ctx *api.Context, team api.Team) (*JiraClient, error) {
-	teamSpec, err := team.GetSpec()
+func NewClient(ctx *api.Context, config api.JiraClient) (*JiraClient, error) {
+	username, err := ctx.GetEnvVarValue(config.Username)
 	if err != nil {
 		return nil, err
 	}
-	username, err := ctx.GetEnvVarValue(teamSpec.ResponderClients.Jira.Username)
+	password, err := ctx.GetEnvVarValue(config.Password)
 	if err != nil {
 		return nil, err
 	}
-	password, err := ctx.GetEnvVarValue(teamSpec.ResponderClients.Jira.Password)
+
+	client, err := newClient(username, password, config.Url)
 	if err != nil {
 		return nil, err
 	}
-
-	return newClient(username, password, teamSpec.ResponderClients.Jira.Url)
+	client.config = config
+	return client, nil
 }
 
 func newClient(email, apiToken, url string) (*JiraClient, error) {
@@ -91,6 +96,7 @@ func (jc *JiraClient) CreateIssue(opts JiraIssue) (*jira.Issue, error) {
 				Key: opts.Project,
 			},
 			Summary: opts.Summary,
+			Labels:  opts.Labels,
 		},
 	}
 
@@ -106,6 +112,20 @@ func (jc *JiraClient) CreateIssue(opts JiraIssue) (*jira.Issue, error) {
 	return issue, nil
 }
 
+// FindIssueByLabel returns the key of the first issue with the given label.
+// Returns an empty string if there's no such issue.
+func (jc *JiraClient) FindIssueByLabel(label string) (string, error) {
+	issues, _, err := jc.client.Issue.Search(fmt.Sprintf("labels = %q", label), &jira.SearchOptions{MaxResults: 1, Fields: []string{"key"}})
+	if err != nil {
+		return "", err
+	}
+
+	if len(issues) == 0 {
+		return "", nil
+	}
+	return issues[0].Key, nil
+}
+
 func (jc *JiraClient) AddComment(issueID, comment string) (string, error) {
 	c, _, err := jc.client.Issue.AddComment(issueID, &jira.Comment{Body: comment})
 	if err != nil {
@@ -116,20 +136,53 @@ func (jc *JiraClient) AddComment(issueID, comment string) (string, error) {
 	return c.ID, nil
 }
 
-func (jc *JiraClient) GetComments(issueID string) ([]api.Comment, error) {
+// jiraTimeFormat is the format of the timestamps returned by Jira
+const jiraTimeFormat = "2006-01-02T15:04:05.999-0700"
+
+func (jc *JiraClient) EditComment(issueID, commentID, comment string) error {
+	_, _, err := jc.client.Issue.UpdateComment(issueID, &jira.Comment{ID: commentID, Body: comment})
+	return err
+}
+
+func (jc *JiraClient) RemoveComment(issueID, commentID string) error {
+	err := jc.client.Issue.DeleteComment(issueID, commentID)
+	if err != nil && strings.Contains(err.Error(), "404 Not Found") {
+		// Already deleted
+		return nil
+	}
+	return err
+}
+
+// GetComments returns the comments of the issue.
+// Jira can't filter the comments by time so the filtering is done here.
+func (jc *JiraClient) GetComments(issueID string, since *time.Time) ([]api.Comment, error) {
 	issue, _, err := jc.client.Issue.Get(issueID, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	var comments []api.Comment
+	if issue.Fields == nil || issue.Fields.Comments == nil {
+		return comments, nil
+	}
+
 	for _, comment := range issue.Fields.Comments.Comments {
-		createdAt, _ := time.Parse("2006-01-02T15:04:05.999-0700", comment.Created)
+		createdAt, _ := time.Parse(jiraTimeFormat, comment.Created)
+		updatedAt, err := time.Parse(jiraTimeFormat, comment.Updated)
+		if err != nil {
+			updatedAt = createdAt
+		}
+
+		if since != nil && updatedAt.Before(*since) {
+			continue
+		}
+
 		comments = append(comments, api.Comment{
 			ExternalID:        comment.ID,
 			Comment:           comment.Body,
 			ExternalCreatedBy: comment.Author.DisplayName,
 			CreatedAt:         createdAt,
+			UpdatedAt:         &updatedAt,
 		})
 	}
 
@@ -222,3 +275,41 @@ func (jc JiraClient) TransitionIssue(issueID, transitionID string) error {
 	_, err := jc.client.Issue.DoTransition(issueID, transitionID)
 	return err
 }
+
+func (jc JiraClient) GetIssueStatus(issueID string) (string, error) {
+	issue, _, err := jc.client.Issue.Get(issueID, &jira.GetQueryOptions{Fields: "status"})
+	if err != nil {
+		return "", err
+	}
+
+	if issue.Fields == nil || issue.Fields.Status == nil {
+		return "", nil
+	}
+	return issue.Fields.Status.Name, nil
+}
+
+// TransitionIssueTo moves the issue to the given status
+// using the first available transition that leads to it.
+func (jc JiraClient) TransitionIssueTo(issueID, status string) error {
+	current, err := jc.GetIssueStatus(issueID)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(current, status) {
+		return nil
+	}
+
+	transitions, err := jc.GetIssueTransitions(issueID)
+	if err != nil {
+		return err
+	}
+
+	for _, transition := range transitions {
+		if strings.EqualFold(transition.ToState, status) {
+			logger.Debugf("[Jira] Transitioning issue: [%s] from [%s] to [%s]", issueID, current, transition.ToState)
+			return jc.TransitionIssue(issueID, transition.ID)
+		}
+	}
+
+	return fmt.Errorf("no transition from %s to %s available for issue %s", current, status, issueID)
+}
@@ -19,6 +19,9 @@ func (jc *JiraClient) NotifyResponder(ctx *api.Context, responder api.Responder)
 		return "", err
 	}
 
+	// The idempotency key is attached as a label so that the issue can be found on retries
+	issueOptions.Labels = []string{responder.IdempotencyKey()}
+
 	var issue *goJira.Issue
 	if issue, err = jc.CreateIssue(issueOptions); err != nil {
 		return "", err
@@ -39,3 +42,23 @@ func (jc *JiraClient) NotifyResponderAddComment(ctx *api.Context, responder api.
 
 	return commentId, nil
 }
+
+func (jc *JiraClient) UpdateComment(ctx *api.Context, responder api.Responder, commentID string, comment string) error {
+	return jc.EditComment(responder.ExternalID, commentID, comment)
+}
+
+func (jc *JiraClient) DeleteComment(ctx *api.Context, responder api.Responder, commentID string) error {
+	return jc.RemoveComment(responder.ExternalID, commentID)
+}
+
+func (jc *JiraClient) UpdateStatus(ctx *api.Context, responder api.Responder, state string) error {
+	return jc.TransitionIssueTo(responder.ExternalID, state)
+}
+
+func (jc *JiraClient) GetStatus(ctx *api.Context, responder api.Responder) (string, error) {
+	return jc.GetIssueStatus(responder.ExternalID)
+}
+
+func (jc *JiraClient) FindExternalID(ctx *api.Context, responder api.Responder, reservation api.ResponderReservation) (string, error) {
+	return jc.FindIssueByLabel(reservation.IdempotencyKey)
+}
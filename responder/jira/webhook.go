@@ -0,0 +1,99 @@
+package jira
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/flanksource/incident-commander/api"
+)
+
+// Webhook events handled by the responder webhook
+const (
+	WebhookCommentCreated = "comment_created"
+	WebhookCommentUpdated = "comment_updated"
+	WebhookIssueUpdated   = "issue_updated"
+)
+
+// WebhookSignatureHeader is the header of the HMAC signature of a webhook with a secret
+const WebhookSignatureHeader = "X-Hub-Signature"
+
+// WebhookEvent is the payload of a Jira webhook.
+type WebhookEvent struct {
+	WebhookEvent string `json:"webhookEvent"`
+	Issue        struct {
+		Key string `json:"key"`
+	} `json:"issue"`
+	Comment *struct {
+		ID     string `json:"id"`
+		Body   string `json:"body"`
+		Author struct {
+			DisplayName string `json:"displayName"`
+		} `json:"author"`
+		Created string `json:"created"`
+		Updated string `json:"updated"`
+	} `json:"comment,omitempty"`
+	Changelog *struct {
+		Items []struct {
+			Field    string `json:"field"`
+			ToString string `json:"toString"`
+		} `json:"items"`
+	} `json:"changelog,omitempty"`
+}
+
+// Event returns the name of the event without the "jira:" prefix of the issue events.
+func (e WebhookEvent) Event() string {
+	return strings.TrimPrefix(e.WebhookEvent, "jira:")
+}
+
+// GetComment returns the comment the event is about.
+func (e WebhookEvent) GetComment() (api.Comment, bool) {
+	if e.Comment == nil || e.Comment.ID == "" {
+		return api.Comment{}, false
+	}
+
+	createdAt, _ := time.Parse(jiraTimeFormat, e.Comment.Created)
+	comment := api.Comment{
+		ExternalID:        e.Comment.ID,
+		Comment:           e.Comment.Body,
+		ExternalCreatedBy: e.Comment.Author.DisplayName,
+		CreatedAt:         createdAt,
+	}
+	if updatedAt, err := time.Parse(jiraTimeFormat, e.Comment.Updated); err == nil {
+		comment.UpdatedAt = &updatedAt
+	}
+	return comment, true
+}
+
+// StatusChange returns the status the issue was moved to, if it was.
+func (e WebhookEvent) StatusChange() (string, bool) {
+	if e.Changelog == nil {
+		return "", false
+	}
+
+	for _, item := range e.Changelog.Items {
+		if item.Field == "status" {
+			return item.ToString, true
+		}
+	}
+	return "", false
+}
+
+// VerifyWebhookSignature checks the "sha256=<hex>" HMAC signature of the webhook's body.
+func VerifyWebhookSignature(secret string, body []byte, signature string) bool {
+	signature, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,72 @@
+package jira
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"webhookEvent":"comment_created"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		want      bool
+	}{
+		{name: "valid", secret: "secret", signature: signature, want: true},
+		{name: "wrong secret", secret: "other", signature: signature},
+		{name: "missing prefix", secret: "secret", signature: signature[len("sha256="):]},
+		{name: "not hex", secret: "secret", signature: "sha256=xyz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyWebhookSignature(tt.secret, body, tt.signature); got != tt.want {
+				t.Errorf("VerifyWebhookSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookEvent(t *testing.T) {
+	payload := `{
+		"webhookEvent": "jira:issue_updated",
+		"issue": {"key": "OPS-7"},
+		"comment": {
+			"id": "10042",
+			"body": "rolled back",
+			"author": {"displayName": "Jane"},
+			"created": "2023-07-01T10:00:00.000+0000",
+			"updated": "2023-07-01T10:05:00.000+0000"
+		},
+		"changelog": {"items": [{"field": "assignee", "toString": "Jane"}, {"field": "status", "toString": "Done"}]}
+	}`
+
+	var event WebhookEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		t.Fatalf("failed to unmarshal webhook: %v", err)
+	}
+
+	if event.Event() != WebhookIssueUpdated {
+		t.Errorf("Event() = %q, want %q", event.Event(), WebhookIssueUpdated)
+	}
+
+	if state, ok := event.StatusChange(); !ok || state != "Done" {
+		t.Errorf("StatusChange() = %q, %v, want Done", state, ok)
+	}
+
+	comment, ok := event.GetComment()
+	if !ok {
+		t.Fatalf("GetComment() returned no comment")
+	}
+	if comment.ExternalID != "10042" || comment.ExternalCreatedBy != "Jane" || comment.CreatedAt.IsZero() || comment.UpdatedAt == nil {
+		t.Errorf("GetComment() = %+v", comment)
+	}
+}
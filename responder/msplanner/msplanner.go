@@ -4,11 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	kiotaAbstractions "github.com/microsoft/kiota-abstractions-go"
 	kiotaAuth "github.com/microsoft/kiota-authentication-azure-go"
 	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	msgraphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
 	"github.com/microsoftgraph/msgraph-sdk-go/groups"
 	"github.com/microsoftgraph/msgraph-sdk-go/models"
 	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
@@ -19,6 +24,9 @@ import (
 
 const ResponderType = "ms_planner"
 
+// postClockSkew is the clock skew allowed between us and Graph when looking up a post
+const postClockSkew = 5 * time.Minute
+
 type MSPlannerTask struct {
 	Title       string
 	PlanID      string `mapstructure:"plan_id"`
@@ -46,6 +54,7 @@ type MSPlannerConfig struct {
 type MSPlannerClient struct {
 	client  *msgraphsdk.GraphServiceClient
 	groupID string
+	config  api.MSPlannerClient
 }
 
 // Planner interprets values 0 and 1 as "urgent", 2, 3 and 4 as "important", 5, 6, and 7 as "medium", and 8, 9, and 10 as "low"
@@ -57,30 +66,48 @@ var taskPriorities = map[string]int32{
 	"low":       9,
 }
 
-func NewClient(ctx *api.Context, team api.Team) (*MSPlannerClient, error) {
+// Planner tracks the progress of a task by its percentComplete.
+// These are the states shown by the UI for the values it sets.
+var taskStates = map[string]int32{
+	"notStarted": 0,
+	"inProgress": 50,
+	"completed":  100,
+}
 
-	teamSpec, err := team.GetSpec()
-	if err != nil {
-		return nil, err
+// taskState returns the state shown by the UI for the percentComplete of the task.
+func taskState(percentComplete int32) string {
+	switch {
+	case percentComplete <= 0:
+		return "notStarted"
+	case percentComplete >= 100:
+		return "completed"
+	default:
+		return "inProgress"
 	}
-	client := teamSpec.ResponderClients.MSPlanner
+}
 
-	username, err := ctx.GetEnvVarValue(client.Username)
+func NewClient(ctx *api.Context, config api.MSPlannerClient) (*MSPlannerClient, error) {
+	username, err := ctx.GetEnvVarValue(config.Username)
 	if err != nil {
 		return nil, err
 	}
-	password, err := ctx.GetEnvVarValue(client.Password)
+	password, err := ctx.GetEnvVarValue(config.Password)
 	if err != nil {
 		return nil, err
 	}
 
-	return newClient(
-		client.TenantID,
-		client.ClientID,
-		client.GroupID,
+	client, err := newClient(
+		config.TenantID,
+		config.ClientID,
+		config.GroupID,
 		username,
 		password,
 	)
+	if err != nil {
+		return nil, err
+	}
+	client.config = config
+	return client, nil
 }
 
 func newClient(tenantID, clientID, groupID, username, password string) (*MSPlannerClient, error) {
@@ -125,14 +152,93 @@ func (c MSPlannerClient) CreateTask(opts MSPlannerTask) (models.PlannerTaskable,
 	return result, openDataError(err)
 }
 
-func (c MSPlannerClient) AddComment(taskID, comment string) (string, error) {
-	// MS Graph API does not return the ID of the comment created
-	// so we just return a constant string for now
-	commentID := "posted"
+// FindTaskByMarker returns the ID of the task in the plan, created at or after the given time,
+// whose description contains the marker.
+// Returns an empty string if there's no such task.
+func (c MSPlannerClient) FindTaskByMarker(planID, marker string, createdAfter time.Time) (string, error) {
+	// The description is only returned with the details of the task
+	config := &planner.PlansItemTasksRequestBuilderGetRequestConfiguration{
+		QueryParameters: &planner.PlansItemTasksRequestBuilderGetQueryParameters{Expand: []string{"details"}},
+	}
+	result, err := c.client.Planner().Plans().ByPlannerPlanId(planID).Tasks().Get(context.Background(), config)
+	if err != nil {
+		return "", openDataError(err)
+	}
+
+	// The tasks of large plans span several pages
+	iterator, err := msgraphcore.NewPageIterator[models.PlannerTaskable](result, c.client.GetAdapter(), models.CreatePlannerTaskCollectionResponseFromDiscriminatorValue)
+	if err != nil {
+		return "", err
+	}
+
+	var taskID string
+	err = iterator.Iterate(context.Background(), func(task models.PlannerTaskable) bool {
+		if task.GetCreatedDateTime() != nil && task.GetCreatedDateTime().Before(createdAfter) {
+			return true
+		}
+
+		details := task.GetDetails()
+		if details == nil || details.GetDescription() == nil || !strings.Contains(*details.GetDescription(), marker) {
+			return true
+		}
+
+		taskID = *task.GetId()
+		return false
+	})
+	if err != nil {
+		return "", openDataError(err)
+	}
 
+	return taskID, nil
+}
+
+func (c MSPlannerClient) GetTaskState(taskID string) (string, error) {
 	task, err := c.client.Planner().Tasks().ByPlannerTaskId(taskID).Get(context.Background(), nil)
 	if err != nil {
-		return commentID, openDataError(err)
+		return "", openDataError(err)
+	}
+
+	var percentComplete int32
+	if task.GetPercentComplete() != nil {
+		percentComplete = *task.GetPercentComplete()
+	}
+	return taskState(percentComplete), nil
+}
+
+func (c MSPlannerClient) SetTaskState(taskID, state string) error {
+	var percentComplete int32
+	var found bool
+	for name, value := range taskStates {
+		if strings.EqualFold(name, state) {
+			percentComplete, found = value, true
+		}
+	}
+	if !found {
+		return fmt.Errorf("unknown task state %s", state)
+	}
+
+	task, err := c.client.Planner().Tasks().ByPlannerTaskId(taskID).Get(context.Background(), nil)
+	if err != nil {
+		return openDataError(err)
+	}
+
+	// Updates require the etag of the task
+	etag := *task.GetAdditionalData()["@odata.etag"].(*string)
+	headers := kiotaAbstractions.NewRequestHeaders()
+	headers.Add("If-Match", etag)
+	patchConfig := planner.TasksPlannerTaskItemRequestBuilderPatchRequestConfiguration{Headers: headers}
+
+	requestBody := models.NewPlannerTask()
+	requestBody.SetPercentComplete(&percentComplete)
+	_, err = c.client.Planner().Tasks().ByPlannerTaskId(taskID).Patch(context.Background(), requestBody, &patchConfig)
+	return openDataError(err)
+}
+
+// AddComment posts the comment to the task's conversation thread and returns the id of the post.
+func (c MSPlannerClient) AddComment(taskID, comment string) (string, error) {
+	task, err := c.client.Planner().Tasks().ByPlannerTaskId(taskID).Get(context.Background(), nil)
+	if err != nil {
+		return "", openDataError(err)
 	}
 
 	post := models.NewPost()
@@ -142,13 +248,22 @@ func (c MSPlannerClient) AddComment(taskID, comment string) (string, error) {
 	body.SetContent(&comment)
 	post.SetBody(body)
 
+	// The posts of a thread are timestamped by Graph, so allow for a clock skew
+	postedAfter := time.Now().Add(-postClockSkew)
+
 	// If conversation thread exists, add a new reply
 	if task.GetConversationThreadId() != nil {
+		threadID := *task.GetConversationThreadId()
 		replyBody := groups.NewItemConversationsItemThreadsItemPostsItemReplyPostRequestBody()
 		replyBody.SetPost(post)
 
-		err = c.client.Groups().ByGroupId(c.groupID).Threads().ByConversationThreadId(*task.GetConversationThreadId()).Reply().Post(context.Background(), replyBody, nil)
-		return commentID, openDataError(err)
+		err = c.client.Groups().ByGroupId(c.groupID).Threads().ByConversationThreadId(threadID).Reply().Post(context.Background(), replyBody, nil)
+		if err != nil {
+			return "", openDataError(err)
+		}
+
+		// MS Graph API does not return the created post, so it's looked up in the thread
+		return c.findPost(threadID, comment, postedAfter)
 	}
 
 	// Create a new conversation thread for the task
@@ -159,7 +274,7 @@ func (c MSPlannerClient) AddComment(taskID, comment string) (string, error) {
 
 	result, err := c.client.Groups().ByGroupId(c.groupID).Threads().Post(context.Background(), convBody, nil)
 	if err != nil {
-		return commentID, openDataError(err)
+		return "", openDataError(err)
 	}
 
 	// Link the created conversation thread to the task
@@ -171,10 +286,81 @@ func (c MSPlannerClient) AddComment(taskID, comment string) (string, error) {
 	requestBody := models.NewPlannerTask()
 	requestBody.SetConversationThreadId(result.GetId())
 	_, err = c.client.Planner().Tasks().ByPlannerTaskId(taskID).Patch(context.Background(), requestBody, &patchConfig)
-	return commentID, openDataError(err)
+	if err != nil {
+		return "", openDataError(err)
+	}
+
+	return c.findPost(*result.GetId(), comment, postedAfter)
+}
+
+// findPost returns the id of the latest post of the thread with the comment's text
+// that was created after the given time.
+func (c MSPlannerClient) findPost(threadID, comment string, postedAfter time.Time) (string, error) {
+	result, err := c.client.Groups().ByGroupId(c.groupID).Threads().ByConversationThreadId(threadID).Posts().Get(context.Background(), nil)
+	if err != nil {
+		return "", openDataError(err)
+	}
+
+	iterator, err := msgraphcore.NewPageIterator[models.Postable](result, c.client.GetAdapter(), models.CreatePostCollectionResponseFromDiscriminatorValue)
+	if err != nil {
+		return "", err
+	}
+
+	var postID string
+	var postCreatedAt time.Time
+	err = iterator.Iterate(context.Background(), func(post models.Postable) bool {
+		if post.GetId() == nil || post.GetCreatedDateTime() == nil || post.GetCreatedDateTime().Before(postedAfter) {
+			return true
+		}
+		if post.GetBody() == nil || post.GetBody().GetContent() == nil || !samePostText(*post.GetBody().GetContent(), comment) {
+			return true
+		}
+
+		if postID == "" || post.GetCreatedDateTime().After(postCreatedAt) {
+			postID, postCreatedAt = *post.GetId(), *post.GetCreatedDateTime()
+		}
+		return true
+	})
+	if err != nil {
+		return "", openDataError(err)
+	}
+
+	if postID == "" {
+		return "", fmt.Errorf("posted comment was not found in conversation thread %s", threadID)
+	}
+	return postID, nil
+}
+
+var (
+	htmlTags       = regexp.MustCompile(`<[^>]*>`)
+	htmlLineBreaks = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div)>`)
+	htmlNewlines   = regexp.MustCompile(`\r?\n`)
+)
+
+// postText returns the text of the content of a post.
+// Graph stores the posts as HTML, even the ones posted as text,
+// so the text of a posted comment is read back as it was posted.
+func postText(content string) string {
+	content = htmlNewlines.ReplaceAllString(content, " ")
+	content = htmlLineBreaks.ReplaceAllString(content, "\n")
+	content = html.UnescapeString(htmlTags.ReplaceAllString(content, ""))
+	content = strings.ReplaceAll(content, "\u00a0", " ")
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// samePostText returns whether the content of a post is the text that was posted.
+func samePostText(content, text string) bool {
+	return strings.Join(strings.Fields(postText(content)), " ") == strings.Join(strings.Fields(text), " ")
 }
 
-func (c MSPlannerClient) GetComments(taskID string) ([]api.Comment, error) {
+// GetComments returns the posts of the task's conversation thread.
+// When since is set, only the posts modified since are returned.
+func (c MSPlannerClient) GetComments(taskID string, since *time.Time) ([]api.Comment, error) {
 	task, err := c.client.Planner().Tasks().ByPlannerTaskId(taskID).Get(context.Background(), nil)
 	if err != nil {
 		return nil, openDataError(err)
@@ -185,17 +371,39 @@ func (c MSPlannerClient) GetComments(taskID string) ([]api.Comment, error) {
 		return comments, nil
 	}
 
-	conversations, err := c.client.Groups().ByGroupId(c.groupID).Threads().ByConversationThreadId(*task.GetConversationThreadId()).Posts().Get(context.Background(), nil)
+	result, err := c.client.Groups().ByGroupId(c.groupID).Threads().ByConversationThreadId(*task.GetConversationThreadId()).Posts().Get(context.Background(), nil)
 	if err != nil {
 		return nil, openDataError(err)
 	}
 
-	for _, conv := range conversations.GetValue() {
-		comments = append(comments, api.Comment{
-			Comment:           *conv.GetBody().GetContent(),
+	iterator, err := msgraphcore.NewPageIterator[models.Postable](result, c.client.GetAdapter(), models.CreatePostCollectionResponseFromDiscriminatorValue)
+	if err != nil {
+		return nil, err
+	}
+
+	err = iterator.Iterate(context.Background(), func(conv models.Postable) bool {
+		updatedAt := conv.GetLastModifiedDateTime()
+		if updatedAt == nil {
+			updatedAt = conv.GetCreatedDateTime()
+		}
+		if since != nil && updatedAt != nil && updatedAt.Before(*since) {
+			return true
+		}
+
+		comment := api.Comment{
+			Comment:           postText(*conv.GetBody().GetContent()),
 			ExternalCreatedBy: *conv.GetFrom().GetEmailAddress().GetName(),
 			CreatedAt:         *conv.GetCreatedDateTime(),
-		})
+			UpdatedAt:         updatedAt,
+		}
+		if conv.GetId() != nil {
+			comment.ExternalID = *conv.GetId()
+		}
+		comments = append(comments, comment)
+		return true
+	})
+	if err != nil {
+		return nil, openDataError(err)
 	}
 
 	return comments, nil
@@ -0,0 +1,228 @@
+package msplanner
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flanksource/duty/types"
+	"github.com/google/uuid"
+	"github.com/microsoft/kiota-abstractions-go/authentication"
+	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"github.com/flanksource/incident-commander/api"
+)
+
+// fakeGraph serves a Planner task whose conversation thread stores the posts
+// as HTML, as Graph does, and pages them.
+type fakeGraph struct {
+	mu       sync.Mutex
+	url      string
+	posts    []map[string]any
+	tasks    []map[string]any
+	pageSize int
+}
+
+func (f *fakeGraph) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/planner/tasks/task-1":
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": "task-1", "conversationThreadId": "thread-1", "@odata.etag": `W/"1"`})
+
+	case r.Method == http.MethodPost && r.URL.Path == "/planner/tasks":
+		var task map[string]any
+		if err := decodeBody(r, &task); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		task["id"] = fmt.Sprintf("planned-%d", len(f.tasks)+1)
+		task["createdDateTime"] = time.Now().UTC().Format(time.RFC3339)
+		f.tasks = append(f.tasks, task)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(task)
+
+	case r.Method == http.MethodGet && r.URL.Path == "/planner/plans/plan-1/tasks":
+		// The details are only returned when they're expanded
+		expand := r.URL.Query().Get("$expand") == "details"
+		var tasks []map[string]any
+		for _, task := range f.tasks {
+			listed := map[string]any{}
+			for k, v := range task {
+				if k != "details" || expand {
+					listed[k] = v
+				}
+			}
+			tasks = append(tasks, listed)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"value": tasks})
+
+	case r.Method == http.MethodPost && r.URL.Path == "/groups/group-1/threads/thread-1/reply":
+		var body struct {
+			Post struct {
+				Body struct{ Content string } `json:"body"`
+			} `json:"post"`
+		}
+		if err := decodeBody(r, &body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now().UTC().Format(time.RFC3339)
+		content := strings.ReplaceAll(html.EscapeString(body.Post.Body.Content), "\n", "<br>\r\n")
+		f.posts = append(f.posts, map[string]any{
+			"id":                   fmt.Sprintf("post-%d", len(f.posts)+1),
+			"createdDateTime":      now,
+			"lastModifiedDateTime": now,
+			"body":                 map[string]any{"contentType": "html", "content": "<html><body><div>\r\n<div>" + content + "</div>\r\n</div></body></html>"},
+			"from":                 map[string]any{"emailAddress": map[string]any{"name": "Incident Commander"}},
+		})
+		w.WriteHeader(http.StatusAccepted)
+
+	case r.Method == http.MethodGet && r.URL.Path == "/groups/group-1/threads/thread-1/posts":
+		var skip int
+		fmt.Sscan(r.URL.Query().Get("$skip"), &skip)
+		end := skip + f.pageSize
+		if end > len(f.posts) {
+			end = len(f.posts)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		page := map[string]any{"value": f.posts[skip:end]}
+		if end < len(f.posts) {
+			page["@odata.nextLink"] = fmt.Sprintf("%s/groups/group-1/threads/thread-1/posts?$skip=%d", f.url, end)
+		}
+		_ = json.NewEncoder(w).Encode(page)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// decodeBody decodes the request body, which the Graph client compresses.
+func decodeBody(r *http.Request, v any) error {
+	reader := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return err
+		}
+		reader = gz
+	}
+	return json.NewDecoder(reader).Decode(v)
+}
+
+func newTestClient(t *testing.T, graph *fakeGraph) MSPlannerClient {
+	server := httptest.NewServer(graph)
+	t.Cleanup(server.Close)
+	graph.url = server.URL
+
+	adapter, err := msgraphsdk.NewGraphRequestAdapter(&authentication.AnonymousAuthenticationProvider{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter.SetBaseUrl(server.URL)
+	return MSPlannerClient{client: msgraphsdk.NewGraphServiceClient(adapter), groupID: "group-1"}
+}
+
+func TestCommentRoundTrip(t *testing.T) {
+	graph := &fakeGraph{pageSize: 2}
+	client := newTestClient(t, graph)
+
+	comments := []string{
+		"first",
+		"second & <third>",
+		"a comment\nover two lines",
+	}
+	for _, comment := range comments {
+		if _, err := client.AddComment("task-1", comment); err != nil {
+			t.Fatalf("AddComment(%q) error = %v", comment, err)
+		}
+	}
+
+	synced, err := client.GetComments("task-1", nil)
+	if err != nil {
+		t.Fatalf("GetComments() error = %v", err)
+	}
+	if len(synced) != len(comments) {
+		t.Fatalf("GetComments() returned %d comments, want %d", len(synced), len(comments))
+	}
+
+	// The pushed comments are read back unchanged, so the sync doesn't overwrite them
+	for i, comment := range synced {
+		if comment.Comment != comments[i] {
+			t.Errorf("GetComments()[%d] = %q, want %q", i, comment.Comment, comments[i])
+		}
+		if comment.ExternalID != fmt.Sprintf("post-%d", i+1) {
+			t.Errorf("GetComments()[%d] id = %q", i, comment.ExternalID)
+		}
+	}
+}
+
+func TestFindExternalID(t *testing.T) {
+	graph := &fakeGraph{}
+	client := newTestClient(t, graph)
+
+	reservedAt := time.Now()
+	var responders []api.Responder
+	for i := 0; i < 2; i++ {
+		responder := api.Responder{
+			ID:         uuid.New(),
+			Properties: types.JSONStringMap{"title": "Database is down", "plan_id": "plan-1", "description": "Templated description"},
+		}
+		if _, err := client.NotifyResponder(nil, responder); err != nil {
+			t.Fatalf("NotifyResponder() error = %v", err)
+		}
+		responders = append(responders, responder)
+	}
+
+	// The tasks have the same title, so each responder is matched to its task by its marker
+	for i, responder := range responders {
+		reservation := api.ResponderReservation{ResponderID: responder.ID, IdempotencyKey: responder.IdempotencyKey(), CreatedAt: reservedAt}
+		externalID, err := client.FindExternalID(nil, responder, reservation)
+		if err != nil {
+			t.Fatalf("FindExternalID() error = %v", err)
+		}
+		if want := fmt.Sprintf("planned-%d", i+1); externalID != want {
+			t.Errorf("FindExternalID() of responder %d = %q, want %q", i, externalID, want)
+		}
+	}
+
+	missing := api.Responder{ID: uuid.New(), Properties: types.JSONStringMap{"title": "Database is down", "plan_id": "plan-1"}}
+	reservation := api.ResponderReservation{ResponderID: missing.ID, IdempotencyKey: missing.IdempotencyKey(), CreatedAt: reservedAt}
+	if externalID, err := client.FindExternalID(nil, missing, reservation); err != nil || externalID != "" {
+		t.Errorf("FindExternalID() of a responder without a task = %q, %v", externalID, err)
+	}
+}
+
+func TestPostText(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{content: "plain", want: "plain"},
+		{content: "<html><body><div>a &amp; b</div></body></html>", want: "a & b"},
+		{content: "<div>one<br>two<BR/>three</div>", want: "one\ntwo\nthree"},
+		{content: "<p>one</p>\r\n<p>two</p>", want: "one\ntwo"},
+		{content: "<div>non&nbsp;breaking</div>", want: "non breaking"},
+	}
+	for _, tt := range tests {
+		if got := postText(tt.content); got != tt.want {
+			t.Errorf("postText(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
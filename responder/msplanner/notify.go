@@ -1,6 +1,11 @@
 package msplanner
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/incident-commander/api"
 	msgraphModels "github.com/microsoftgraph/msgraph-sdk-go/models"
 	"github.com/mitchellh/mapstructure"
@@ -17,6 +22,10 @@ func (client *MSPlannerClient) NotifyResponder(ctx *api.Context, responder api.R
 		return "", err
 	}
 
+	// Planner tasks have no hidden fields, so the idempotency key is added to the description
+	// for the task to be found on retries
+	taskOptions.Description = strings.TrimSpace(taskOptions.Description + "\n\n" + marker(responder.IdempotencyKey()))
+
 	var task msgraphModels.PlannerTaskable
 	if task, err = client.CreateTask(taskOptions); err != nil {
 		return "", err
@@ -24,3 +33,41 @@ func (client *MSPlannerClient) NotifyResponder(ctx *api.Context, responder api.R
 
 	return *task.GetId(), nil
 }
+
+// UpdateComment isn't supported for the conversation posts of Planner tasks.
+func (client *MSPlannerClient) UpdateComment(ctx *api.Context, responder api.Responder, commentID string, comment string) error {
+	logger.Debugf("[MSPlanner] Skipping update of comment %s since Planner posts can't be updated", commentID)
+	return nil
+}
+
+// DeleteComment isn't supported for the conversation posts of Planner tasks.
+func (client *MSPlannerClient) DeleteComment(ctx *api.Context, responder api.Responder, commentID string) error {
+	logger.Debugf("[MSPlanner] Skipping deletion of comment %s since Planner posts can't be deleted", commentID)
+	return nil
+}
+
+func (client *MSPlannerClient) UpdateStatus(ctx *api.Context, responder api.Responder, state string) error {
+	return client.SetTaskState(responder.ExternalID, state)
+}
+
+func (client *MSPlannerClient) GetStatus(ctx *api.Context, responder api.Responder) (string, error) {
+	return client.GetTaskState(responder.ExternalID)
+}
+
+// FindExternalID looks up the task by the marker in its description.
+// Only the tasks created after the reservation are considered.
+func (client *MSPlannerClient) FindExternalID(ctx *api.Context, responder api.Responder, reservation api.ResponderReservation) (string, error) {
+	var taskOptions MSPlannerTask
+	if err := mapstructure.Decode(responder.Properties, &taskOptions); err != nil {
+		return "", err
+	}
+
+	// Allow for a clock skew between us and the Graph API
+	return client.FindTaskByMarker(taskOptions.PlanID, marker(reservation.IdempotencyKey), reservation.CreatedAt.Add(-time.Minute))
+}
+
+// marker identifies the responder of a task.
+// The description of a task is plain text, so it can't be hidden as it is in GitHub issues.
+func marker(idempotencyKey string) string {
+	return fmt.Sprintf("Ref: %s", idempotencyKey)
+}
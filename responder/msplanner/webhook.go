@@ -0,0 +1,33 @@
+package msplanner
+
+import (
+	"crypto/subtle"
+)
+
+// ChangeNotifications is the payload of a Microsoft Graph change notification.
+// Comments on Planner tasks are posts in the group's conversations,
+// so the subscription is on the group's conversations.
+type ChangeNotifications struct {
+	Value []ChangeNotification `json:"value"`
+}
+
+type ChangeNotification struct {
+	SubscriptionID string `json:"subscriptionId"`
+	ClientState    string `json:"clientState"`
+	ChangeType     string `json:"changeType"`
+	Resource       string `json:"resource"`
+}
+
+// Verify checks that all the notifications carry the clientState of the subscription.
+func (n ChangeNotifications) Verify(clientState string) bool {
+	if len(n.Value) == 0 {
+		return false
+	}
+
+	for _, notification := range n.Value {
+		if subtle.ConstantTimeCompare([]byte(notification.ClientState), []byte(clientState)) != 1 {
+			return false
+		}
+	}
+	return true
+}
@@ -5,8 +5,10 @@ import (
 	"time"
 
 	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/responder/github"
 	"github.com/flanksource/incident-commander/responder/jira"
 	"github.com/flanksource/incident-commander/responder/msplanner"
+	"github.com/flanksource/incident-commander/responder/servicenow"
 	"github.com/patrickmn/go-cache"
 )
 
@@ -15,42 +17,123 @@ var respondersCache = cache.New(time.Hour*1, time.Hour*1)
 type ResponderInterface interface {
 	// NotifyResponder creates a new issue and returns the issue ID
 	NotifyResponder(ctx *api.Context, responder api.Responder) (string, error)
+	// FindExternalID looks up the issue that a previous attempt may have created for the reservation.
+	// Returns an empty string if there's none.
+	FindExternalID(ctx *api.Context, responder api.Responder, reservation api.ResponderReservation) (string, error)
 	// NotifyResponderAddComment adds a comment to an existing issue
 	NotifyResponderAddComment(ctx *api.Context, responder api.Responder, comment string) (string, error)
-	// GetComments returns the comments for an issue
-	GetComments(issueID string) ([]api.Comment, error)
+	// UpdateStatus moves the issue to the given state
+	UpdateStatus(ctx *api.Context, responder api.Responder, state string) error
+	// GetStatus returns the current state of the issue
+	GetStatus(ctx *api.Context, responder api.Responder) (string, error)
+	// UpdateComment updates the body of an existing comment
+	UpdateComment(ctx *api.Context, responder api.Responder, commentID string, comment string) error
+	// DeleteComment deletes an existing comment. Deleting a comment that no longer exists isn't an error.
+	DeleteComment(ctx *api.Context, responder api.Responder, commentID string) error
+	// GetComments returns the comments for an issue.
+	// When since is set, only the comments created or updated since are returned.
+	GetComments(issueID string, since *time.Time) ([]api.Comment, error)
 	// SyncConfig gets the config for the responder for use in the UI
-	SyncConfig(ctx *api.Context, team api.Team) (configType string, configName string, config string, err error)
+	SyncConfig(ctx *api.Context) (configType string, configName string, config string, err error)
 }
 
-func GetResponder(ctx *api.Context, team api.Team) (ResponderInterface, error) {
+// GetResponder returns the team's responder client with the given name.
+func GetResponder(ctx *api.Context, team api.Team, name string) (ResponderInterface, error) {
+	clients := map[string]ResponderInterface{}
 	if r, found := respondersCache.Get(team.ID.String()); found {
-		return r.(ResponderInterface), nil
+		clients = r.(map[string]ResponderInterface)
+		if responder, ok := clients[name]; ok {
+			return responder, nil
+		}
 	}
 
-	teamSpec, err := team.GetSpec()
+	teamClients, err := getResponderClients(team)
 	if err != nil {
 		return nil, err
 	}
-	var responder ResponderInterface
-	if teamSpec.ResponderClients.Jira != nil {
-		responder, err = jira.NewClient(ctx, team)
-		if err != nil {
-			return nil, err
-		}
-	} else if teamSpec.ResponderClients.MSPlanner != nil {
-		responder, err = msplanner.NewClient(ctx, team)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		return nil, fmt.Errorf("no responder client found for team %s", team.ID)
+
+	client, ok := teamClients.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("no responder client named %q found for team %s", name, team.ID)
 	}
 
-	respondersCache.Set(team.ID.String(), responder, cache.DefaultExpiration)
+	responder, err := newResponder(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	// The cached map is shared, so a copy is updated instead
+	updated := make(map[string]ResponderInterface, len(clients)+1)
+	for k, v := range clients {
+		updated[k] = v
+	}
+	updated[name] = responder
+	respondersCache.Set(team.ID.String(), updated, cache.DefaultExpiration)
 	return responder, nil
 }
 
+// GetResponderFor returns the client the responder is filed with.
+// A responder that hasn't been filed yet uses the client named in its properties,
+// or else the team's first client of its responderType.
+func GetResponderFor(ctx *api.Context, responder api.Responder) (ResponderInterface, api.ResponderClient, error) {
+	clients, err := getResponderClients(responder.Team)
+	if err != nil {
+		return nil, api.ResponderClient{}, err
+	}
+
+	var client api.ResponderClient
+	var ok bool
+	if name := responder.ClientName(); name != "" {
+		client, ok = clients.Get(name)
+	} else {
+		client, ok = clients.ForType(responder.Properties["responderType"])
+	}
+	if !ok {
+		return nil, client, fmt.Errorf("no responder client found for responder %s of team %s", responder.ID, responder.Team.ID)
+	}
+
+	r, err := GetResponder(ctx, responder.Team, client.Name)
+	return r, client, err
+}
+
+// getResponderClients returns the responder clients of the team,
+// or an error if they can't be told apart by their names.
+func getResponderClients(team api.Team) (api.ResponderClients, error) {
+	teamSpec, err := team.GetSpec()
+	if err != nil {
+		return api.ResponderClients{}, err
+	}
+
+	if err := teamSpec.ResponderClients.Validate(); err != nil {
+		return api.ResponderClients{}, fmt.Errorf("invalid responder clients of team %s: %w", team.ID, err)
+	}
+	return teamSpec.ResponderClients, nil
+}
+
+// HasClient returns true if the responders of the responderType are filed with a responder client.
+// The other responders, e.g. email and slack, are only notified.
+func HasClient(responderType string) bool {
+	switch responderType {
+	case jira.ResponderType, msplanner.ResponderType, servicenow.ResponderType, github.ResponderType:
+		return true
+	}
+	return false
+}
+
+func newResponder(ctx *api.Context, client api.ResponderClient) (ResponderInterface, error) {
+	switch {
+	case client.Jira != nil:
+		return jira.NewClient(ctx, *client.Jira)
+	case client.MSPlanner != nil:
+		return msplanner.NewClient(ctx, *client.MSPlanner)
+	case client.ServiceNow != nil:
+		return servicenow.NewClient(ctx, *client.ServiceNow)
+	case client.Github != nil:
+		return github.NewClient(ctx, *client.Github)
+	}
+	return nil, fmt.Errorf("responder client %q has no client configured", client.Name)
+}
+
 func PurgeCache(teamID string) {
 	respondersCache.Delete(teamID)
 }
@@ -0,0 +1,17 @@
+package servicenow
+
+import (
+	"github.com/flanksource/incident-commander/api"
+	"github.com/pkg/errors"
+)
+
+func (sc *ServiceNowClient) SyncConfig(ctx *api.Context) (configType string, configName string, config string, err error) {
+	config, err = sc.GetConfigJSON()
+	if err != nil {
+		return "", "", "", errors.Wrap(err, "error generating config from ServiceNow")
+	}
+
+	configName = sc.config.Values["project"]
+	configType = ResponderType
+	return
+}
@@ -0,0 +1,73 @@
+package servicenow
+
+import (
+	"fmt"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/mitchellh/mapstructure"
+)
+
+func (sc *ServiceNowClient) NotifyResponder(ctx *api.Context, responder api.Responder) (string, error) {
+	issueOptions, err := decodeIssue(responder)
+	if err != nil {
+		return "", err
+	}
+
+	// The idempotency key is set as the correlation id so that the record can be found on retries
+	issueOptions.CorrelationID = responder.IdempotencyKey()
+	return sc.CreateIssue(issueOptions)
+}
+
+func (sc *ServiceNowClient) NotifyResponderAddComment(ctx *api.Context, responder api.Responder, comment string) (string, error) {
+	issueOptions, err := decodeIssue(responder)
+	if err != nil {
+		return "", err
+	}
+
+	return sc.AddComment(issueOptions.table(), responder.ExternalID, comment)
+}
+
+func (sc *ServiceNowClient) UpdateComment(ctx *api.Context, responder api.Responder, commentID string, comment string) error {
+	return sc.EditComment(commentID, comment)
+}
+
+func (sc *ServiceNowClient) DeleteComment(ctx *api.Context, responder api.Responder, commentID string) error {
+	return sc.RemoveComment(commentID)
+}
+
+func (sc *ServiceNowClient) UpdateStatus(ctx *api.Context, responder api.Responder, state string) error {
+	issueOptions, err := decodeIssue(responder)
+	if err != nil {
+		return err
+	}
+
+	return sc.SetIssueState(issueOptions.table(), responder.ExternalID, state)
+}
+
+func (sc *ServiceNowClient) GetStatus(ctx *api.Context, responder api.Responder) (string, error) {
+	issueOptions, err := decodeIssue(responder)
+	if err != nil {
+		return "", err
+	}
+
+	return sc.GetIssueState(issueOptions.table(), responder.ExternalID)
+}
+
+func (sc *ServiceNowClient) FindExternalID(ctx *api.Context, responder api.Responder, reservation api.ResponderReservation) (string, error) {
+	issueOptions, err := decodeIssue(responder)
+	if err != nil {
+		return "", err
+	}
+
+	return sc.FindIssueByCorrelationID(issueOptions.table(), reservation.IdempotencyKey)
+}
+
+func decodeIssue(responder api.Responder) (ServiceNowIssue, error) {
+	if responder.Properties["responderType"] != ResponderType {
+		return ServiceNowIssue{}, fmt.Errorf("invalid responderType: %s", responder.Properties["responderType"])
+	}
+
+	var issueOptions ServiceNowIssue
+	err := mapstructure.Decode(responder.Properties, &issueOptions)
+	return issueOptions, err
+}
@@ -0,0 +1,368 @@
+package servicenow
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/incident-commander/api"
+)
+
+const ResponderType = "servicenow"
+
+// defaultTable is the table the records are created in
+// when the responder doesn't specify an issue type.
+const defaultTable = "incident"
+
+// timeFormat is the format of the date time fields returned by the Table API.
+// The values are in UTC.
+const timeFormat = "2006-01-02 15:04:05"
+
+type ServiceNowIssue struct {
+	Summary     string
+	Description string
+	// IssueType is the table the record is created in. Eg: incident, problem
+	IssueType string
+	Priority  string
+	// Project is the assignment group of the record
+	Project  string
+	Assignee string
+	// CorrelationID is set on the record so that it can be looked up on retries
+	CorrelationID string `mapstructure:"-"`
+}
+
+func (i ServiceNowIssue) table() string {
+	if i.IssueType == "" {
+		return defaultTable
+	}
+	return i.IssueType
+}
+
+type ServiceNowConfig struct {
+	Tables           []string          `json:"tables"`
+	Priorities       map[string]string `json:"priorities"`
+	AssignmentGroups map[string]string `json:"assignment_groups"`
+}
+
+type ServiceNowClient struct {
+	client   *http.Client
+	url      string
+	username string
+	password string
+	config   api.ServiceNowClient
+}
+
+// tableRecord is the subset of the fields of a Table API record
+// that is read by the client.
+type tableRecord struct {
+	SysID         string `json:"sys_id"`
+	Number        string `json:"number"`
+	Value         string `json:"value"`
+	Label         string `json:"label"`
+	Name          string `json:"name"`
+	SysCreatedBy  string `json:"sys_created_by"`
+	SysCreatedOn  string `json:"sys_created_on"`
+	CorrelationID string `json:"correlation_id"`
+	State         string `json:"state"`
+}
+
+type tableResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Message string `json:"message"`
+		Detail  string `json:"detail"`
+	} `json:"error,omitempty"`
+}
+
+func NewClient(ctx *api.Context, config api.ServiceNowClient) (*ServiceNowClient, error) {
+	username, err := ctx.GetEnvVarValue(config.Username)
+	if err != nil {
+		return nil, err
+	}
+	password, err := ctx.GetEnvVarValue(config.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	client := newClient(username, password, config.Url)
+	client.config = config
+	return client, nil
+}
+
+func newClient(username, password, url string) *ServiceNowClient {
+	return &ServiceNowClient{
+		client:   &http.Client{Timeout: time.Minute},
+		url:      strings.TrimSuffix(url, "/"),
+		username: username,
+		password: password,
+	}
+}
+
+// errNotFound is returned by do when the record doesn't exist
+var errNotFound = fmt.Errorf("not found")
+
+// do sends a request to the Table API and decodes the result into out.
+func (sc *ServiceNowClient) do(method, path string, query url.Values, body any, out any) error {
+	endpoint := fmt.Sprintf("%s/api/now/table/%s", sc.url, path)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, endpoint, reqBody)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(sc.username, sc.password)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := sc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	var response tableResponse
+	if resp.StatusCode >= 300 {
+		// The body of an error isn't always json, e.g. the html page of a proxy,
+		// so it's only used for its message if it decodes
+		_ = json.NewDecoder(resp.Body).Decode(&response)
+
+		if resp.StatusCode == http.StatusNotFound {
+			message := ""
+			if response.Error != nil {
+				message = response.Error.Message
+			}
+			return fmt.Errorf("servicenow returned %d: %s: %w", resp.StatusCode, message, errNotFound)
+		}
+		if response.Error != nil {
+			return fmt.Errorf("servicenow returned %d: %s %s", resp.StatusCode, response.Error.Message, response.Error.Detail)
+		}
+		return fmt.Errorf("servicenow returned %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("error decoding response (status=%d): %w", resp.StatusCode, err)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(response.Result, out)
+}
+
+func (sc *ServiceNowClient) CreateIssue(opts ServiceNowIssue) (string, error) {
+	fields := map[string]string{
+		"short_description": opts.Summary,
+		"description":       opts.Description,
+	}
+	if opts.Priority != "" {
+		fields["priority"] = opts.Priority
+	}
+	if opts.Project != "" {
+		fields["assignment_group"] = opts.Project
+	}
+	if opts.Assignee != "" {
+		fields["assigned_to"] = opts.Assignee
+	}
+	if opts.CorrelationID != "" {
+		fields["correlation_id"] = opts.CorrelationID
+	}
+
+	var record tableRecord
+	if err := sc.do(http.MethodPost, opts.table(), nil, fields, &record); err != nil {
+		return "", err
+	}
+
+	logger.Debugf("[ServiceNow] Record created in table: [%s] with ID: [%s] - [%s]", opts.table(), record.Number, opts.Summary)
+	return record.SysID, nil
+}
+
+// FindIssueByCorrelationID returns the sys_id of the first record in the table with the given correlation id.
+// Returns an empty string if there's no such record.
+func (sc *ServiceNowClient) FindIssueByCorrelationID(table, correlationID string) (string, error) {
+	query := url.Values{
+		"sysparm_query":  []string{"correlation_id=" + correlationID},
+		"sysparm_fields": []string{"sys_id"},
+		"sysparm_limit":  []string{"1"},
+	}
+
+	var records []tableRecord
+	if err := sc.do(http.MethodGet, table, query, nil, &records); err != nil {
+		return "", err
+	}
+
+	if len(records) == 0 {
+		return "", nil
+	}
+	return records[0].SysID, nil
+}
+
+// GetIssueState returns the display value of the record's state. Eg: In Progress
+func (sc *ServiceNowClient) GetIssueState(table, sysID string) (string, error) {
+	query := url.Values{
+		"sysparm_fields":        []string{"state"},
+		"sysparm_display_value": []string{"true"},
+	}
+
+	var record tableRecord
+	if err := sc.do(http.MethodGet, fmt.Sprintf("%s/%s", table, sysID), query, nil, &record); err != nil {
+		return "", err
+	}
+	return record.State, nil
+}
+
+// SetIssueState updates the record's state by its display value.
+func (sc *ServiceNowClient) SetIssueState(table, sysID, state string) error {
+	query := url.Values{"sysparm_input_display_value": []string{"true"}}
+	if err := sc.do(http.MethodPatch, fmt.Sprintf("%s/%s", table, sysID), query, map[string]string{"state": state}, nil); err != nil {
+		return err
+	}
+
+	logger.Debugf("[ServiceNow] Record: [%s] moved to state: [%s]", sysID, state)
+	return nil
+}
+
+// AddComment adds a comment to the record's journal and returns the id of the journal entry.
+func (sc *ServiceNowClient) AddComment(table, sysID, comment string) (string, error) {
+	if err := sc.do(http.MethodPatch, fmt.Sprintf("%s/%s", table, sysID), nil, map[string]string{"comments": comment}, nil); err != nil {
+		return "", err
+	}
+
+	// The Table API doesn't return the journal entry created by the update
+	// so the latest comment of the record is looked up instead.
+	query := url.Values{
+		"sysparm_query":  []string{fmt.Sprintf("element_id=%s^element=comments^ORDERBYDESCsys_created_on", sysID)},
+		"sysparm_fields": []string{"sys_id"},
+		"sysparm_limit":  []string{"1"},
+	}
+	var entries []tableRecord
+	if err := sc.do(http.MethodGet, "sys_journal_field", query, nil, &entries); err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", fmt.Errorf("comment added to %s but it wasn't found in the journal", sysID)
+	}
+
+	logger.Debugf("[ServiceNow] Comment: [%s] added for record: [%s]", comment, sysID)
+	return entries[0].SysID, nil
+}
+
+// EditComment updates the journal entry of a comment.
+// Journal entries can only be updated by users with the admin role.
+func (sc *ServiceNowClient) EditComment(commentID, comment string) error {
+	return sc.do(http.MethodPatch, "sys_journal_field/"+commentID, nil, map[string]string{"value": comment}, nil)
+}
+
+// RemoveComment deletes the journal entry of a comment.
+// Journal entries can only be deleted by users with the admin role.
+func (sc *ServiceNowClient) RemoveComment(commentID string) error {
+	err := sc.do(http.MethodDelete, "sys_journal_field/"+commentID, nil, nil, nil)
+	if errors.Is(err, errNotFound) {
+		// Already deleted
+		return nil
+	}
+	return err
+}
+
+// GetComments returns the comments of the record.
+// When since is set, only the comments created since are returned.
+func (sc *ServiceNowClient) GetComments(sysID string, since *time.Time) ([]api.Comment, error) {
+	filter := fmt.Sprintf("element_id=%s^element=comments", sysID)
+	if since != nil {
+		filter += "^sys_created_on>=" + since.UTC().Format(timeFormat)
+	}
+
+	query := url.Values{
+		"sysparm_query":  []string{filter + "^ORDERBYsys_created_on"},
+		"sysparm_fields": []string{"sys_id,value,sys_created_by,sys_created_on"},
+	}
+
+	var entries []tableRecord
+	if err := sc.do(http.MethodGet, "sys_journal_field", query, nil, &entries); err != nil {
+		return nil, err
+	}
+
+	var comments []api.Comment
+	for _, entry := range entries {
+		createdAt, _ := time.Parse(timeFormat, entry.SysCreatedOn)
+		comments = append(comments, api.Comment{
+			ExternalID:        entry.SysID,
+			Comment:           entry.Value,
+			ExternalCreatedBy: entry.SysCreatedBy,
+			CreatedAt:         createdAt,
+			UpdatedAt:         &createdAt,
+		})
+	}
+
+	return comments, nil
+}
+
+func (sc *ServiceNowClient) GetConfig() (ServiceNowConfig, error) {
+	config := ServiceNowConfig{
+		Tables:           []string{"incident", "problem"},
+		Priorities:       make(map[string]string),
+		AssignmentGroups: make(map[string]string),
+	}
+
+	var priorities []tableRecord
+	query := url.Values{
+		"sysparm_query":  []string{"name=incident^element=priority^inactive=false"},
+		"sysparm_fields": []string{"value,label"},
+	}
+	if err := sc.do(http.MethodGet, "sys_choice", query, nil, &priorities); err != nil {
+		return ServiceNowConfig{}, err
+	}
+	for _, priority := range priorities {
+		config.Priorities[priority.Label] = priority.Value
+	}
+
+	var groups []tableRecord
+	query = url.Values{
+		"sysparm_query":  []string{"active=true"},
+		"sysparm_fields": []string{"sys_id,name"},
+	}
+	if err := sc.do(http.MethodGet, "sys_user_group", query, nil, &groups); err != nil {
+		return ServiceNowConfig{}, err
+	}
+	for _, group := range groups {
+		config.AssignmentGroups[group.Name] = group.SysID
+	}
+
+	return config, nil
+}
+
+func (sc *ServiceNowClient) GetConfigJSON() (string, error) {
+	config, err := sc.GetConfig()
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(&config)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
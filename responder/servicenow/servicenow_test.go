@@ -0,0 +1,205 @@
+package servicenow
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/google/uuid"
+)
+
+// fakeTableAPI is a minimal in-memory stand-in for the ServiceNow Table API.
+type fakeTableAPI struct {
+	mu       sync.Mutex
+	records  map[string]map[string]string
+	journal  []map[string]string
+	requests []string
+}
+
+func newFakeTableAPI() *fakeTableAPI {
+	return &fakeTableAPI{records: make(map[string]map[string]string)}
+}
+
+func (f *fakeTableAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"message": "User Not Authenticated"}})
+		return
+	}
+
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/now/table/"), "/")
+	query := r.URL.Query().Get("sysparm_query")
+
+	var result any
+	switch {
+	case r.Method == http.MethodPost && len(path) == 1:
+		var fields map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&fields)
+		fields["sys_id"] = fmt.Sprintf("sys-%d", len(f.records)+1)
+		fields["number"] = fmt.Sprintf("INC%07d", len(f.records)+1)
+		f.records[fields["sys_id"]] = fields
+		w.WriteHeader(http.StatusCreated)
+		result = fields
+
+	case r.Method == http.MethodPatch && len(path) == 2:
+		var fields map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&fields)
+		if _, ok := f.records[path[1]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"message": "No Record found"}})
+			return
+		}
+		f.journal = append(f.journal, map[string]string{
+			"sys_id":         fmt.Sprintf("journal-%d", len(f.journal)+1),
+			"element_id":     path[1],
+			"value":          fields["comments"],
+			"sys_created_by": "admin",
+			"sys_created_on": fmt.Sprintf("2023-07-01 10:00:%02d", len(f.journal)),
+		})
+		result = f.records[path[1]]
+
+	case r.Method == http.MethodDelete && path[0] == "sys_journal_field":
+		// A missing record is answered with a page rather than json
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("<html><body>Not Found</body></html>"))
+		return
+
+	case r.Method == http.MethodGet && path[0] == "sys_journal_field":
+		elementID := strings.TrimPrefix(strings.Split(query, "^")[0], "element_id=")
+		entries := []map[string]string{}
+		for _, entry := range f.journal {
+			if entry["element_id"] == elementID {
+				entries = append(entries, entry)
+			}
+		}
+		if strings.Contains(query, "ORDERBYDESC") {
+			for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+				entries[i], entries[j] = entries[j], entries[i]
+			}
+		}
+		result = entries
+
+	case r.Method == http.MethodGet && len(path) == 1:
+		correlationID := strings.TrimPrefix(query, "correlation_id=")
+		records := []map[string]string{}
+		for _, record := range f.records {
+			if record["correlation_id"] == correlationID {
+				records = append(records, record)
+			}
+		}
+		result = records
+
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"message": "unexpected request"}})
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]any{"result": result})
+}
+
+func TestServiceNowClient(t *testing.T) {
+	fake := newFakeTableAPI()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := newClient("admin", "secret", server.URL+"/")
+	responder := api.Responder{
+		ID: uuid.New(),
+		Properties: map[string]string{
+			"responderType": ResponderType,
+			"summary":       "Database is down",
+			"description":   "Connections are being refused",
+			"priority":      "1",
+		},
+	}
+
+	externalID, err := client.NotifyResponder(nil, responder)
+	if err != nil {
+		t.Fatalf("NotifyResponder() error = %v", err)
+	}
+
+	record := fake.records[externalID]
+	if record == nil {
+		t.Fatalf("NotifyResponder() returned %q but no such record was created", externalID)
+	}
+	if record["short_description"] != "Database is down" || record["priority"] != "1" {
+		t.Errorf("NotifyResponder() created record with unexpected fields: %v", record)
+	}
+	if record["correlation_id"] != responder.IdempotencyKey() {
+		t.Errorf("NotifyResponder() correlation_id = %q, want %q", record["correlation_id"], responder.IdempotencyKey())
+	}
+
+	foundID, err := client.FindExternalID(nil, responder, api.ResponderReservation{IdempotencyKey: responder.IdempotencyKey()})
+	if err != nil {
+		t.Fatalf("FindExternalID() error = %v", err)
+	}
+	if foundID != externalID {
+		t.Errorf("FindExternalID() = %q, want %q", foundID, externalID)
+	}
+
+	missingID, err := client.FindExternalID(nil, responder, api.ResponderReservation{IdempotencyKey: "mission-control-unknown"})
+	if err != nil {
+		t.Fatalf("FindExternalID() error = %v", err)
+	}
+	if missingID != "" {
+		t.Errorf("FindExternalID() = %q for an unknown key, want empty", missingID)
+	}
+
+	responder.ExternalID = externalID
+	for _, comment := range []string{"first", "second"} {
+		if _, err := client.NotifyResponderAddComment(nil, responder, comment); err != nil {
+			t.Fatalf("NotifyResponderAddComment() error = %v", err)
+		}
+	}
+
+	comments, err := client.GetComments(externalID, nil)
+	if err != nil {
+		t.Fatalf("GetComments() error = %v", err)
+	}
+	if len(comments) != 2 || comments[0].Comment != "first" || comments[1].Comment != "second" {
+		t.Errorf("GetComments() = %v, want the two added comments", comments)
+	}
+	if comments[1].ExternalID != "journal-2" || comments[1].CreatedAt.IsZero() {
+		t.Errorf("GetComments() returned comment without id or timestamp: %+v", comments[1])
+	}
+}
+
+func TestServiceNowClientErrors(t *testing.T) {
+	fake := newFakeTableAPI()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	responder := api.Responder{
+		ID:         uuid.New(),
+		ExternalID: "sys-404",
+		Properties: map[string]string{"responderType": ResponderType},
+	}
+
+	if _, err := newClient("admin", "wrong", server.URL).NotifyResponder(nil, responder); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("NotifyResponder() with bad credentials error = %v, want 401", err)
+	}
+
+	if _, err := newClient("admin", "secret", server.URL).NotifyResponderAddComment(nil, responder, "hi"); err == nil || !strings.Contains(err.Error(), "No Record found") {
+		t.Errorf("NotifyResponderAddComment() on a missing record error = %v, want not found", err)
+	}
+
+	if err := newClient("admin", "secret", server.URL).RemoveComment("journal-404"); err != nil {
+		t.Errorf("RemoveComment() of a missing comment error = %v, want it to be treated as deleted", err)
+	}
+
+	responder.Properties["responderType"] = "jira"
+	if _, err := newClient("admin", "secret", server.URL).NotifyResponder(nil, responder); err == nil {
+		t.Errorf("NotifyResponder() with a jira responder should fail")
+	}
+}
@@ -0,0 +1,116 @@
+package responder
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/duty/models"
+	"gorm.io/gorm"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+)
+
+// responderStatusColumns are the columns of the responders table
+// that are set when the issue moves to a state mapped to the incident status.
+var responderStatusColumns = map[api.IncidentStatus]string{
+	api.IncidentStatusInvestigating: "acknowledged",
+	api.IncidentStatusMitigated:     "acknowledged",
+	api.IncidentStatusResolved:      "resolved",
+	api.IncidentStatusClosed:        "closed",
+	api.IncidentStatusCancelled:     "closed",
+}
+
+// SyncStatuses reads the state of the responders' issues back
+// and records the changes made on the external systems.
+func SyncStatuses() {
+	logger.Debugf("Syncing responder statuses")
+	ctx := api.NewContext(db.Gorm, nil)
+
+	var responders []api.Responder
+	err := db.Gorm.Where("external_id IS NOT NULL AND closed IS NULL").Preload("Team").Find(&responders).Error
+	if err != nil {
+		logger.Errorf("Error fetching responders from database: %v", err)
+		return
+	}
+
+	jobHistory := models.NewJobHistory("ResponderStatusSync", "", "")
+	_ = db.PersistJobHistory(ctx, jobHistory.Start())
+	for _, responder := range responders {
+		if !responder.Team.HasResponder() {
+			logger.Debugf("Skipping responder %s since it does not have a responder", responder.Team.Name)
+			continue
+		}
+
+		if err := syncResponderStatus(ctx, responder); err != nil {
+			logger.Errorf("Error syncing status of responder %s: %v", responder.ID, err)
+			jobHistory.AddError(err.Error())
+			continue
+		}
+		jobHistory.IncrSuccess()
+	}
+	_ = db.PersistJobHistory(ctx, jobHistory.End())
+}
+
+func syncResponderStatus(ctx *api.Context, responder api.Responder) error {
+	responderClient, clientConfig, err := GetResponderFor(ctx, responder)
+	if err != nil {
+		return err
+	}
+
+	state, err := responderClient.GetStatus(ctx, responder)
+	if err != nil {
+		return fmt.Errorf("error fetching status from responder: %w", err)
+	}
+
+	return applyResponderStatus(ctx, responder, clientConfig, state)
+}
+
+// applyResponderStatus records the state of the responder's issue
+// if it changed since it was last seen.
+func applyResponderStatus(ctx *api.Context, responder api.Responder, clientConfig api.ResponderClient, state string) error {
+	previous, err := db.GetResponderSyncState(ctx, responder.ID)
+	if err != nil {
+		return err
+	}
+	if previous != nil && strings.EqualFold(previous.ExternalStatus, state) {
+		return nil
+	}
+
+	status, mapped := clientConfig.StatusMapping().IncidentStatus(state)
+
+	return ctx.DB().Transaction(func(tx *gorm.DB) error {
+		txCtx := api.NewContext(tx, nil)
+		if err := db.SaveResponderExternalStatus(txCtx, responder.ID, state); err != nil {
+			return err
+		}
+
+		// The state of a newly synced issue is only interesting if it's mapped
+		if previous == nil && !mapped {
+			return nil
+		}
+
+		if column, ok := responderStatusColumns[status]; mapped && ok {
+			err := tx.Model(&api.Responder{}).Where("id = ?", responder.ID).Where(column+" IS NULL").
+				Update(column, gorm.Expr("NOW()")).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		description := fmt.Sprintf("%s moved to %s", responder.ExternalID, state)
+		if mapped {
+			description = fmt.Sprintf("%s (%s)", description, status)
+		}
+
+		history := api.IncidentHistory{
+			IncidentID:  responder.IncidentID,
+			Type:        "responder.status_updated",
+			Description: description,
+			ResponderID: &responder.ID,
+			CreatedBy:   api.SystemUserID,
+		}
+		return tx.Create(&history).Error
+	})
+}
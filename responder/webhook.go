@@ -0,0 +1,312 @@
+package responder
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+	"github.com/flanksource/incident-commander/responder/jira"
+	"github.com/flanksource/incident-commander/responder/msplanner"
+)
+
+// outboundCommentWindow is how long after a comment is added here
+// that the same comment pushed back by a webhook is taken to be the comment itself.
+var outboundCommentWindow = 5 * time.Minute
+
+// WebhookSyncEvent syncs the open responders of a team's client
+// for the webhooks that don't tell which responder changed.
+const WebhookSyncEvent = "responder.webhook.sync"
+
+// webhookPayload is used to tell the responder type of a webhook.
+type webhookPayload struct {
+	WebhookEvent string          `json:"webhookEvent"`
+	Value        json.RawMessage `json:"value"`
+}
+
+// HandleWebhook ingests the comments and status changes pushed by the team's responder clients.
+//
+// The client is picked by the "client" query param, or else by the payload.
+// Jira webhooks are verified by their signature or by the "secret" query param.
+// Microsoft Graph notifications are verified by their clientState.
+func HandleWebhook(c echo.Context) error {
+	ctx := c.(*api.Context)
+
+	// Microsoft Graph validates the notification url when the subscription is created
+	if token := c.QueryParam("validationToken"); token != "" {
+		return c.String(http.StatusOK, token)
+	}
+
+	teamID, err := uuid.Parse(c.Param("team_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, api.HTTPError{Error: err.Error(), Message: "invalid team id"})
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, api.HTTPError{Error: err.Error(), Message: "failed to read request body"})
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return c.JSON(http.StatusBadRequest, api.HTTPError{Error: err.Error(), Message: "invalid webhook payload"})
+	}
+
+	var responderType string
+	switch {
+	case payload.WebhookEvent != "":
+		responderType = jira.ResponderType
+	case payload.Value != nil:
+		responderType = msplanner.ResponderType
+	default:
+		return c.JSON(http.StatusBadRequest, api.HTTPError{Error: "unrecognized webhook payload", Message: "invalid webhook payload"})
+	}
+
+	// The same response is returned whether the team, its client or its secret is missing,
+	// or the webhook fails to be verified, so that an unverified caller can't tell the teams apart.
+	unauthorized := func(reason string) error {
+		logger.Debugf("Rejected %s webhook of team %s: %s", responderType, teamID, reason)
+		return c.JSON(http.StatusUnauthorized, api.HTTPError{Error: "unauthorized", Message: "failed to verify webhook"})
+	}
+
+	var team api.Team
+	if err := ctx.DB().Where("id = ? AND deleted_at IS NULL", teamID).First(&team).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return unauthorized("team not found")
+		}
+		return c.JSON(http.StatusInternalServerError, api.HTTPError{Error: err.Error(), Message: "failed to get team"})
+	}
+
+	client, err := getWebhookClient(team, c.QueryParam("client"), responderType)
+	if err != nil {
+		return unauthorized(err.Error())
+	}
+
+	secretVar, _ := client.WebhookSecret()
+	secret, err := ctx.GetEnvVarValue(secretVar)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, api.HTTPError{Error: err.Error(), Message: "failed to get webhook secret"})
+	}
+	if secret == "" {
+		return unauthorized(fmt.Sprintf("no webhook secret configured for responder client %s", client.Name))
+	}
+
+	switch responderType {
+	case jira.ResponderType:
+		var event jira.WebhookEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return c.JSON(http.StatusBadRequest, api.HTTPError{Error: err.Error(), Message: "invalid jira webhook payload"})
+		}
+		if !verifyJiraWebhook(c, secret, body) {
+			return unauthorized("invalid signature")
+		}
+		err = handleJiraWebhook(ctx, team, client, event)
+
+	case msplanner.ResponderType:
+		var notifications msplanner.ChangeNotifications
+		if err := json.Unmarshal(body, &notifications); err != nil {
+			return c.JSON(http.StatusBadRequest, api.HTTPError{Error: err.Error(), Message: "invalid graph notification payload"})
+		}
+		if !notifications.Verify(secret) {
+			return unauthorized("invalid clientState")
+		}
+		err = enqueueWebhookSync(ctx, team, client)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, api.HTTPError{Error: err.Error(), Message: "failed to process webhook"})
+	}
+
+	return c.JSON(http.StatusAccepted, api.HTTPSuccess{Message: "ok"})
+}
+
+func getWebhookClient(team api.Team, name, responderType string) (api.ResponderClient, error) {
+	clients, err := getResponderClients(team)
+	if err != nil {
+		return api.ResponderClient{}, err
+	}
+
+	var client api.ResponderClient
+	var ok bool
+	if name != "" {
+		client, ok = clients.Get(name)
+	} else {
+		client, ok = clients.ForType(responderType)
+	}
+	if !ok || client.Type() != responderType {
+		return client, fmt.Errorf("no %s responder client found for team %s", responderType, team.ID)
+	}
+	return client, nil
+}
+
+// verifyJiraWebhook checks the signature of webhooks registered with a secret.
+// Jira can't sign the webhooks registered by some of its versions, so the secret can be passed in the url instead.
+func verifyJiraWebhook(c echo.Context, secret string, body []byte) bool {
+	if signature := c.Request().Header.Get(jira.WebhookSignatureHeader); signature != "" {
+		return jira.VerifyWebhookSignature(secret, body, signature)
+	}
+	return subtle.ConstantTimeCompare([]byte(c.QueryParam("secret")), []byte(secret)) == 1
+}
+
+// getWebhookResponders returns the team's responders filed with the client.
+// An empty externalID matches all the open responders.
+func getWebhookResponders(ctx *api.Context, team api.Team, client api.ResponderClient, externalID string) ([]api.Responder, error) {
+	query := ctx.DB().Where("team_id = ? AND external_id IS NOT NULL", team.ID)
+	if externalID != "" {
+		query = query.Where("external_id = ?", externalID)
+	} else {
+		query = query.Where("closed IS NULL")
+	}
+
+	var responders []api.Responder
+	if err := query.Preload("Team").Find(&responders).Error; err != nil {
+		return nil, err
+	}
+
+	var filed []api.Responder
+	for _, responder := range responders {
+		if name := responder.ClientName(); name != "" && name != client.Name {
+			continue
+		}
+		if responder.Properties["responderType"] != client.Type() {
+			continue
+		}
+		filed = append(filed, responder)
+	}
+	return filed, nil
+}
+
+func handleJiraWebhook(ctx *api.Context, team api.Team, client api.ResponderClient, event jira.WebhookEvent) error {
+	responders, err := getWebhookResponders(ctx, team, client, event.Issue.Key)
+	if err != nil {
+		return err
+	}
+	if len(responders) == 0 {
+		logger.Debugf("Ignoring jira webhook %s for issue %s with no responder", event.Event(), event.Issue.Key)
+		return nil
+	}
+
+	var errs []string
+	for _, responder := range responders {
+		switch event.Event() {
+		case jira.WebhookCommentCreated, jira.WebhookCommentUpdated:
+			comment, ok := event.GetComment()
+			if !ok {
+				continue
+			}
+			if err := ingestResponderComment(ctx, responder, comment); err != nil {
+				errs = append(errs, fmt.Sprintf("responder:%s: %v", responder.ID, err))
+			}
+
+		case jira.WebhookIssueUpdated:
+			state, ok := event.StatusChange()
+			if !ok {
+				continue
+			}
+			if err := applyResponderStatus(ctx, responder, client, state); err != nil {
+				errs = append(errs, fmt.Sprintf("responder:%s: %v", responder.ID, err))
+			}
+		}
+	}
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// enqueueWebhookSync queues the sync of the team's open responders filed with the client.
+// Graph only notifies which conversation changed, which can't be mapped to a task without fetching it,
+// and a sync of all the responders takes longer than Graph waits for the response.
+// The syncs queued by the notifications that arrive before the sync runs are deduplicated by the queue.
+func enqueueWebhookSync(ctx *api.Context, team api.Team, client api.ResponderClient) error {
+	event := api.Event{
+		ID:   uuid.New(),
+		Name: WebhookSyncEvent,
+		Properties: map[string]string{
+			"team_id":       team.ID.String(),
+			"client":        client.Name,
+			"responderType": client.Type(),
+		},
+	}
+	if err := ctx.DB().Clauses(clause.OnConflict{DoNothing: true}).Create(&event).Error; err != nil {
+		return err
+	}
+	return ctx.DB().Exec("NOTIFY event_queue_updates, 'update'").Error
+}
+
+// SyncWebhookResponders syncs the comments and statuses of the team's open responders
+// filed with the client of the webhook sync event.
+func SyncWebhookResponders(ctx *api.Context, event api.Event) error {
+	var team api.Team
+	if err := ctx.DB().Where("id = ? AND deleted_at IS NULL", event.Properties["team_id"]).Find(&team).Error; err != nil {
+		return err
+	} else if team.ID == uuid.Nil {
+		logger.Debugf("Skipping webhook sync of deleted team %s", event.Properties["team_id"])
+		return nil
+	}
+
+	client, err := getWebhookClient(team, event.Properties["client"], event.Properties["responderType"])
+	if err != nil {
+		logger.Debugf("Skipping webhook sync of removed client: %v", err)
+		return nil
+	}
+
+	responders, err := getWebhookResponders(ctx, team, client, "")
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, responder := range responders {
+		if err := syncResponderComments(ctx, responder); err != nil {
+			errs = append(errs, fmt.Sprintf("responder:%s: %v", responder.ID, err))
+		}
+		if err := syncResponderStatus(ctx, responder); err != nil {
+			errs = append(errs, fmt.Sprintf("responder:%s: %v", responder.ID, err))
+		}
+	}
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ingestResponderComment saves a comment pushed by the responder.
+func ingestResponderComment(ctx *api.Context, responder api.Responder, comment api.Comment) error {
+	linked, err := db.GetResponderComments(ctx, responder.ID)
+	if err != nil {
+		return err
+	}
+	linkedByExternalID := make(map[string]db.ResponderComment, len(linked))
+	for _, c := range linked {
+		linkedByExternalID[c.ExternalID] = c
+	}
+
+	// The webhook of a comment added here can arrive before the comment is linked to the responder
+	if _, ok := linkedByExternalID[comment.ExternalID]; !ok {
+		var count int64
+		err := ctx.DB().Model(&api.Comment{}).
+			Where("incident_id = ? AND external_id IS NULL AND comment = ? AND created_at > ?", responder.IncidentID, comment.Comment, time.Now().Add(-outboundCommentWindow)).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			logger.Debugf("Skipping comment %s of responder %s since it was added here", comment.ExternalID, responder.ID)
+			return nil
+		}
+	}
+
+	return upsertResponderComment(responder, linkedByExternalID, comment)
+}
@@ -0,0 +1,99 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	"gorm.io/gorm"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+	"github.com/flanksource/incident-commander/db/models"
+)
+
+// autoClose describes how an incident is closed automatically.
+type autoClose struct {
+	status  api.IncidentStatus
+	column  string
+	history string
+	verb    string
+	// from are the statuses an incident is moved from
+	from []api.IncidentStatus
+}
+
+var (
+	autoResolveAction = autoClose{
+		status:  api.IncidentStatusResolved,
+		column:  "resolved",
+		history: "incident.auto_resolved",
+		verb:    "Resolved",
+		from:    []api.IncidentStatus{api.IncidentStatusOpen, api.IncidentStatusInvestigating, api.IncidentStatusMitigated},
+	}
+	autoCloseAction = autoClose{
+		status:  api.IncidentStatusClosed,
+		column:  "closed",
+		history: "incident.auto_closed",
+		verb:    "Closed",
+		from:    []api.IncidentStatus{api.IncidentStatusOpen, api.IncidentStatusInvestigating, api.IncidentStatusMitigated, api.IncidentStatusResolved},
+	}
+)
+
+// autoCloseIncidents resolves and closes the incidents opened by the rules
+// once all of their components have been healthy for the rule's timeout.
+func autoCloseIncidents(ctx context.Context) error {
+	for _, _rule := range Rules {
+		rule, err := _rule.GetSpec()
+		if err != nil {
+			logger.Errorf("error fetching rule spec: %s, %v", _rule.Name, err)
+			continue
+		}
+
+		if rule.AutoResolve != nil {
+			if err := autoCloseRuleIncidents(ctx, _rule, autoResolveAction, rule.AutoResolve.Timeout); err != nil {
+				return err
+			}
+		}
+
+		if rule.AutoClose != nil {
+			if err := autoCloseRuleIncidents(ctx, _rule, autoCloseAction, rule.AutoClose.Timeout); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func autoCloseRuleIncidents(ctx context.Context, rule models.IncidentRule, action autoClose, timeout time.Duration) error {
+	incidents, err := db.GetHealthyRuleIncidents(ctx, *rule.ID, action.from, time.Now().Add(-timeout))
+	if err != nil {
+		return fmt.Errorf("error fetching incidents of rule %s: %w", rule.Name, err)
+	}
+
+	for _, incident := range incidents {
+		logger.Infof("%s incident %s of rule %s", action.verb, incident.ID, rule.Name)
+
+		err := db.Gorm.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			err := tx.Model(&api.Incident{}).Where("id = ?", incident.ID).
+				Updates(map[string]any{"status": action.status, action.column: gorm.Expr("NOW()")}).Error
+			if err != nil {
+				return err
+			}
+
+			history := api.IncidentHistory{
+				IncidentID:  incident.ID,
+				Type:        action.history,
+				Description: fmt.Sprintf("%s by rule %s as %s have been healthy for %s", action.verb, rule.Name, incident.Resources, timeout),
+				CreatedBy:   api.SystemUserID,
+			}
+			return tx.Create(&history).Error
+		})
+		if err != nil {
+			return fmt.Errorf("error updating incident %s: %w", incident.ID, err)
+		}
+	}
+
+	return nil
+}
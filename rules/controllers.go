@@ -0,0 +1,31 @@
+package rules
+
+import (
+	"net/http"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/labstack/echo/v4"
+
+	"github.com/flanksource/incident-commander/api"
+)
+
+// DryRunHandler evaluates the rule of the request body without creating its incidents.
+func DryRunHandler(c echo.Context) error {
+	ctx := c.(*api.Context)
+
+	// Decoded like the stored rules, i.e. with durations such as "1h"
+	var request DryRunRequest
+	if err := jsoniter.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, api.HTTPError{Error: err.Error(), Message: "invalid request"})
+	}
+	if request.Days < 0 {
+		return c.JSON(http.StatusBadRequest, api.HTTPError{Message: "'days' cannot be negative"})
+	}
+
+	result, err := DryRun(ctx, request)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, api.HTTPError{Error: err.Error(), Message: "failed to dry run the incident rule"})
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
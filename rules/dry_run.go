@@ -0,0 +1,259 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/flanksource/duty"
+	dutyModels "github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/types"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+	"github.com/flanksource/incident-commander/db/models"
+)
+
+// MaxDryRunDays is the most days a rule can be simulated over.
+// The component status history is only kept for as long.
+const MaxDryRunDays = 30
+
+// DryRunRequest is a rule to evaluate without creating its incidents.
+type DryRunRequest struct {
+	// Name of the rule. The open incidents of an existing rule of the same name
+	// deduplicate the matches.
+	Name string               `json:"name,omitempty"`
+	Spec api.IncidentRuleSpec `json:"spec"`
+	// Days of component status history to simulate the rule over
+	Days int `json:"days,omitempty"`
+}
+
+// DryRunResult is what the rule would do.
+type DryRunResult struct {
+	// Matches are the evaluations of the resources the rule matches now
+	Matches Trace `json:"matches"`
+	// Simulation are the incidents the rule would have created over the last days
+	Simulation []SimulatedIncident `json:"simulation,omitempty"`
+}
+
+// SimulatedIncident is an incident the rule would have created for a component.
+type SimulatedIncident struct {
+	ResourceID string `json:"resource_id"`
+	Resource   string `json:"resource"`
+	// Status of the component when the incident would have been created
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	// Deduplicated is the number of later matches of the component while the incident was open
+	Deduplicated int           `json:"deduplicated,omitempty"`
+	Incident     *api.Incident `json:"incident,omitempty"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// DryRun evaluates the rule against the components, failing checks and open config analyses and,
+// if requested, simulates it over the status history of the components without writing to the database.
+// The components are matched with their current labels, namespace and analysis.
+func DryRun(ctx *api.Context, request DryRunRequest) (*DryRunResult, error) {
+	if request.Days < 0 {
+		return nil, errors.New("days must not be negative")
+	} else if request.Days > MaxDryRunDays {
+		return nil, fmt.Errorf("days must not be more than %d", MaxDryRunDays)
+	}
+
+	id := uuid.Nil
+	rule := models.IncidentRule{ID: &id, Name: request.Name}
+	if err := rule.SetSpec(request.Spec); err != nil {
+		return nil, err
+	}
+	if request.Name != "" {
+		var existing models.IncidentRule
+		if err := db.Gorm.WithContext(ctx).Where("name = ?", request.Name).Limit(1).Find(&existing).Error; err != nil {
+			return nil, err
+		} else if existing.ID != nil {
+			rule.ID = existing.ID
+		}
+	}
+
+	response, err := duty.QueryTopology(ctx, db.Pool, duty.TopologyOptions{Flatten: true})
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := loadEvaluationState(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	componentIDs := make([]uuid.UUID, 0, len(response.Components))
+	for _, component := range response.Components {
+		componentIDs = append(componentIDs, component.ID)
+	}
+	statusSince, err := db.GetComponentStatusSince(ctx, componentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing's written with a dry run session, e.g. the job history of failing expressions
+	dryRunCtx := api.NewContext(db.Gorm.Session(&gorm.Session{DryRun: true}), nil)
+	dryRunCtx.Context = ctx.Context
+
+	resources := componentResources(response.Components, statusSince)
+	if spec := request.Spec; len(spec.Checks) > 0 {
+		checks, err := db.GetFailingChecks(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, checkResources(checks)...)
+	}
+	if spec := request.Spec; len(spec.ConfigAnalysis) > 0 {
+		analyses, err := db.GetOpenConfigAnalyses(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, configAnalysisResources(analyses)...)
+	}
+
+	now := time.Now()
+	result := &DryRunResult{Matches: Trace{}}
+	for _, res := range resources {
+		evaluation, err := evaluate(dryRunCtx, rule, state, res, now, true)
+		if err != nil {
+			return nil, err
+		}
+		if evaluation.Matched {
+			result.Matches = append(result.Matches, evaluation)
+		}
+	}
+
+	if request.Days > 0 {
+		from := now.AddDate(0, 0, -request.Days)
+		changes, err := db.GetComponentStatusHistory(ctx, from)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Simulation, err = simulate(dryRunCtx, rule, response.Components, changes, from, now); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// simulate returns the incidents the rule would have created from the status changes of the components
+// between from and to. The incidents are created as the periodic evaluation would, once the component has
+// been in a matching status for the rule's age and within its hours of operation, and are resolved when
+// the component has been healthy for the rule's auto resolve or close timeout.
+// Every component is simulated on its own, i.e. the matches aren't grouped.
+func simulate(ctx *api.Context, _rule models.IncidentRule, components dutyModels.Components, changes []db.ComponentStatusChange, from, to time.Time) ([]SimulatedIncident, error) {
+	rule, err := _rule.GetSpec()
+	if err != nil {
+		return nil, err
+	}
+
+	var resolveAfter *time.Duration
+	for _, a := range []*api.AutoClose{rule.AutoResolve, rule.AutoClose} {
+		if a != nil && (resolveAfter == nil || a.Timeout < *resolveAfter) {
+			timeout := a.Timeout
+			resolveAfter = &timeout
+		}
+	}
+
+	byID := make(map[uuid.UUID]dutyModels.Component, len(components))
+	for _, component := range components {
+		byID[component.ID] = *component
+	}
+
+	var incidents []SimulatedIncident
+	for start := 0; start < len(changes); {
+		end := start
+		for end < len(changes) && changes[end].ComponentID == changes[start].ComponentID {
+			end++
+		}
+
+		if component, ok := byID[changes[start].ComponentID]; ok {
+			simulated, err := simulateComponent(ctx, _rule, rule, resolveAfter, component, changes[start:end], from, to)
+			if err != nil {
+				return nil, err
+			}
+			incidents = append(incidents, simulated...)
+		}
+		start = end
+	}
+
+	return incidents, nil
+}
+
+func simulateComponent(ctx *api.Context, _rule models.IncidentRule, rule *api.IncidentRuleSpec, resolveAfter *time.Duration, component dutyModels.Component, changes []db.ComponentStatusChange, from, to time.Time) ([]SimulatedIncident, error) {
+	// The age is applied to the status changes instead of the current time
+	withoutAge := *rule
+	withoutAge.Filter.Age = nil
+
+	var incidents []SimulatedIncident
+	open := -1
+	for i, change := range changes {
+		end := to
+		if i+1 < len(changes) {
+			end = changes[i+1].CreatedAt
+		}
+		component.Status = types.ComponentStatus(change.Status)
+
+		if open >= 0 && resolveAfter != nil && component.Status == types.ComponentStatusHealthy {
+			if resolvedAt := change.CreatedAt.Add(*resolveAfter); !resolvedAt.After(end) {
+				incidents[open].ResolvedAt = &resolvedAt
+				open = -1
+			}
+		}
+
+		if mismatch(&withoutAge, component, change.CreatedAt) != "" {
+			continue
+		}
+
+		at := change.CreatedAt
+		if rule.Filter.Age != nil {
+			at = at.Add(*rule.Filter.Age)
+		}
+		for ; at.Before(end); at = at.Add(Period) {
+			active, err := withinHoursOfOperation(rule.HoursOfOperation, at)
+			if err != nil {
+				return nil, err
+			}
+			if active {
+				break
+			}
+		}
+		if !at.Before(end) {
+			continue
+		}
+
+		if open >= 0 {
+			incidents[open].Deduplicated++
+			continue
+		}
+
+		simulated := SimulatedIncident{
+			ResourceID: component.ID.String(),
+			Resource:   component.Name,
+			Status:     change.Status,
+			CreatedAt:  at,
+		}
+		if incident, err := newIncident(ctx, _rule, rule, componentResource{component: component, since: change.CreatedAt}); err != nil {
+			simulated.Error = fmt.Sprintf("error rendering incident: %v", err)
+		} else {
+			simulated.Incident = &incident
+		}
+		incidents = append(incidents, simulated)
+		open = len(incidents) - 1
+	}
+
+	// Incidents created before the simulated period only deduplicate the later matches
+	var simulated []SimulatedIncident
+	for _, incident := range incidents {
+		if !incident.CreatedAt.Before(from) {
+			simulated = append(simulated, incident)
+		}
+	}
+	return simulated, nil
+}
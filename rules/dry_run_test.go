@@ -0,0 +1,100 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	dutyModels "github.com/flanksource/duty/models"
+	"github.com/google/uuid"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+)
+
+func TestSimulate(t *testing.T) {
+	component := &dutyModels.Component{ID: uuid.New(), Name: "payments", Namespace: "prod"}
+	start := time.Date(2023, 7, 3, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	changes := []db.ComponentStatusChange{
+		{ComponentID: component.ID, Status: "healthy", CreatedAt: at(-time.Hour)},
+		{ComponentID: component.ID, Status: "unhealthy", CreatedAt: at(time.Hour)},
+		{ComponentID: component.ID, Status: "healthy", CreatedAt: at(3 * time.Hour)},
+		{ComponentID: component.ID, Status: "unhealthy", CreatedAt: at(5 * time.Hour)},
+		{ComponentID: component.ID, Status: "unhealthy", CreatedAt: at(5*time.Hour + 10*time.Minute)},
+		{ComponentID: uuid.New(), Status: "unhealthy", CreatedAt: at(time.Hour)},
+	}
+	age := 30 * time.Minute
+
+	tests := []struct {
+		name string
+		spec api.IncidentRuleSpec
+		want []SimulatedIncident
+	}{
+		{
+			name: "resolved before the next match",
+			spec: api.IncidentRuleSpec{
+				Filter:      api.Filter{Status: []string{"unhealthy"}, Age: &age},
+				Components:  []api.ComponentSelector{{Namespace: "prod"}},
+				AutoResolve: &api.AutoClose{Timeout: time.Hour},
+			},
+			want: []SimulatedIncident{
+				{CreatedAt: at(90 * time.Minute), ResolvedAt: ptr(at(4 * time.Hour))},
+				{CreatedAt: at(5*time.Hour + 40*time.Minute)},
+			},
+		},
+		{
+			name: "deduplicated while open",
+			spec: api.IncidentRuleSpec{
+				Filter:     api.Filter{Status: []string{"unhealthy"}},
+				Components: []api.ComponentSelector{{Namespace: "prod"}},
+			},
+			want: []SimulatedIncident{
+				{CreatedAt: at(time.Hour), Deduplicated: 2},
+			},
+		},
+		{
+			name: "hours of operation",
+			spec: api.IncidentRuleSpec{
+				Filter:           api.Filter{Status: []string{"unhealthy"}},
+				Components:       []api.ComponentSelector{{Namespace: "prod"}},
+				HoursOfOperation: []api.HoursOfOperation{{Start: "02:00", End: "23:00"}},
+			},
+			want: []SimulatedIncident{
+				{CreatedAt: at(2 * time.Hour), Deduplicated: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := newRule(t, "simulated", tt.spec)
+			got, err := simulate(api.NewContext(nil, nil), rule, dutyModels.Components{component}, changes, start, at(10*time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("simulate() = %d incidents, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, want := range tt.want {
+				if !got[i].CreatedAt.Equal(want.CreatedAt) {
+					t.Errorf("incident %d created at %s, want %s", i, got[i].CreatedAt, want.CreatedAt)
+				}
+				if (got[i].ResolvedAt == nil) != (want.ResolvedAt == nil) || (want.ResolvedAt != nil && !got[i].ResolvedAt.Equal(*want.ResolvedAt)) {
+					t.Errorf("incident %d resolved at %v, want %v", i, got[i].ResolvedAt, want.ResolvedAt)
+				}
+				if got[i].Deduplicated != want.Deduplicated {
+					t.Errorf("incident %d deduplicated %d matches, want %d", i, got[i].Deduplicated, want.Deduplicated)
+				}
+				if got[i].Incident == nil || got[i].Incident.Title != "payments is unhealthy" {
+					t.Errorf("incident %d = %+v, want the title 'payments is unhealthy'", i, got[i].Incident)
+				}
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,44 @@
+package rules
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/flanksource/incident-commander/api"
+)
+
+const labelsPrefix = "labels."
+
+// groupFingerprint identifies the group of the resource, or is empty if the rule doesn't group its resources.
+// The attributes are part of the fingerprint so that only rules grouping by the same attributes share incidents.
+func groupFingerprint(group *api.IncidentGrouping, res resource) (string, error) {
+	if group == nil || len(group.By) == 0 {
+		return "", nil
+	}
+
+	by := make([]string, len(group.By))
+	copy(by, group.By)
+	sort.Strings(by)
+
+	var values []string
+	for _, attribute := range by {
+		var value string
+		switch {
+		case attribute == "namespace":
+			value = res.namespace()
+		case attribute == "parent":
+			value = res.parent()
+		case strings.HasPrefix(attribute, labelsPrefix):
+			value = res.labels()[strings.TrimPrefix(attribute, labelsPrefix)]
+		default:
+			return "", fmt.Errorf("unknown group by attribute %q, must be one of namespace, parent or labels.<key>", attribute)
+		}
+		values = append(values, attribute+"="+value)
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(values, "\n")))
+	return hex.EncodeToString(sum[:]), nil
+}
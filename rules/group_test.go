@@ -0,0 +1,145 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	dutyModels "github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/types"
+	"github.com/google/uuid"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+)
+
+func TestGroupFingerprint(t *testing.T) {
+	parent := uuid.New()
+	component := func(namespace, app string) resource {
+		return componentResource{component: dutyModels.Component{
+			ID:        uuid.New(),
+			Namespace: namespace,
+			ParentId:  &parent,
+			Labels:    types.JSONStringMap{"app": app},
+		}}
+	}
+	fingerprint := func(by []string, res resource) string {
+		fp, err := groupFingerprint(&api.IncidentGrouping{By: by}, res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fp
+	}
+
+	if fp, err := groupFingerprint(nil, component("prod", "payments")); err != nil || fp != "" {
+		t.Errorf("groupFingerprint() without grouping = %q, %v, want no fingerprint", fp, err)
+	}
+
+	byNamespace := []string{"namespace"}
+	if fingerprint(byNamespace, component("prod", "payments")) != fingerprint(byNamespace, component("prod", "orders")) {
+		t.Errorf("components of the same namespace have different fingerprints")
+	}
+	if fingerprint(byNamespace, component("prod", "payments")) == fingerprint(byNamespace, component("staging", "payments")) {
+		t.Errorf("components of different namespaces have the same fingerprint")
+	}
+
+	byLabel := []string{"namespace", "labels.app"}
+	if fingerprint(byLabel, component("prod", "payments")) == fingerprint(byLabel, component("prod", "orders")) {
+		t.Errorf("components of different apps have the same fingerprint")
+	}
+	if fingerprint(byLabel, component("prod", "payments")) != fingerprint([]string{"labels.app", "namespace"}, component("prod", "payments")) {
+		t.Errorf("the order of the attributes changes the fingerprint")
+	}
+
+	if fingerprint([]string{"parent"}, component("prod", "payments")) == fingerprint(byNamespace, component("prod", "payments")) {
+		t.Errorf("grouping by different attributes has the same fingerprint")
+	}
+
+	if _, err := groupFingerprint(&api.IncidentGrouping{By: []string{"team"}}, component("prod", "payments")); err == nil {
+		t.Errorf("groupFingerprint() of an unknown attribute didn't fail")
+	}
+}
+
+func TestEvaluateGrouping(t *testing.T) {
+	now := time.Now()
+	hour := time.Hour
+	newComponent := func(name, namespace string) resource {
+		return componentResource{component: dutyModels.Component{
+			ID:        uuid.New(),
+			Name:      name,
+			Namespace: namespace,
+			Status:    types.ComponentStatusUnhealthy,
+		}}
+	}
+	payments := newComponent("payments", "prod")
+	orders := newComponent("orders", "prod")
+	search := newComponent("search", "staging")
+
+	rule := newRule(t, "namespace-outage", api.IncidentRuleSpec{
+		Components: []api.ComponentSelector{{Types: api.Items{"*"}}},
+		Group:      &api.IncidentGrouping{By: []string{"namespace"}, Window: &hour},
+	})
+	spec, err := rule.GetSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prod, err := groupFingerprint(spec.Group, payments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	incidentID := uuid.New()
+	tests := []struct {
+		name   string
+		groups map[string]*db.GroupedIncident
+		want   []string
+	}{
+		{
+			name: "no open incident",
+			want: []string{ResultWouldCreate, ResultWouldGroup, ResultWouldCreate},
+		},
+		{
+			name: "open incident within the window",
+			groups: map[string]*db.GroupedIncident{
+				prod: {ID: incidentID, CreatedAt: now.Add(-30 * time.Minute), Resources: map[string]struct{}{payments.id().String(): {}}},
+			},
+			want: []string{ResultDeduplicated, ResultWouldGroup, ResultWouldCreate},
+		},
+		{
+			name: "open incident past the window",
+			groups: map[string]*db.GroupedIncident{
+				prod: {ID: incidentID, CreatedAt: now.Add(-2 * time.Hour), Resources: map[string]struct{}{payments.id().String(): {}}},
+			},
+			want: []string{ResultWouldCreate, ResultWouldGroup, ResultWouldCreate},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := map[string]*db.GroupedIncident{}
+			for fp, group := range tt.groups {
+				copied := *group
+				copied.Resources = map[string]struct{}{}
+				for id := range group.Resources {
+					copied.Resources[id] = struct{}{}
+				}
+				groups[fp] = &copied
+			}
+			state := evaluationState{byRule: map[string]map[string]struct{}{}, groups: groups}
+
+			var got []string
+			for _, res := range []resource{payments, orders, search} {
+				evaluation, err := evaluate(api.NewContext(nil, nil), rule, state, res, now, true)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, evaluation.Result)
+			}
+
+			for i := range tt.want {
+				if i >= len(got) || got[i] != tt.want[i] {
+					t.Fatalf("evaluate() results = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
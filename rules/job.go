@@ -2,6 +2,10 @@ package rules
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/flanksource/commons/logger"
@@ -10,12 +14,23 @@ import (
 	"github.com/flanksource/incident-commander/api"
 	"github.com/flanksource/incident-commander/db"
 	"github.com/flanksource/incident-commander/db/models"
+	"github.com/flanksource/incident-commander/silence"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-var Period = time.Second * 300
+var (
+	// Period of the evaluation of the rules against the whole topology
+	Period = 30 * time.Minute
+	// AutoClosePeriod is how often the incidents of recovered components are closed
+	AutoClosePeriod = 5 * time.Minute
+)
 
 var Rules []models.IncidentRule
 
+// evaluationLock prevents concurrent evaluations from opening the same incident twice
+var evaluationLock sync.Mutex
+
 func getAllStatii() []string {
 	var statii []string
 	for _, rule := range Rules {
@@ -27,12 +42,50 @@ func getAllStatii() []string {
 	return statii
 }
 
+func selectsChecks() bool {
+	for _, rule := range Rules {
+		if spec, _ := rule.GetSpec(); spec != nil && len(spec.Checks) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func selectsConfigAnalyses() bool {
+	for _, rule := range Rules {
+		if spec, _ := rule.GetSpec(); spec != nil && len(spec.ConfigAnalysis) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sortByPriority sorts the rules in the order they're evaluated,
+// i.e. by ascending priority. Rules of the same priority keep their order.
+func sortByPriority(rules []models.IncidentRule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		return priority(rules[i]) < priority(rules[j])
+	})
+}
+
+func priority(rule models.IncidentRule) int {
+	spec, err := rule.GetSpec()
+	if err != nil {
+		return 0
+	}
+	return spec.Priority
+}
+
+// Run evaluates the rules against the whole topology, the failing checks and the open
+// config analyses, and closes the incidents whose components have recovered. The rules are
+// evaluated against the resources as they change, so this only reconciles the changes that were missed.
 func Run() error {
 	ctx := context.Background()
 
-	if err := db.Gorm.
-		// .Order("priority ASC")
-		Find(&Rules).Error; err != nil {
+	evaluationLock.Lock()
+	defer evaluationLock.Unlock()
+
+	if err := loadRules(ctx); err != nil {
 		return err
 	}
 
@@ -46,132 +99,471 @@ func Run() error {
 	}
 	logger.Debugf("Found %d components with statuses: %v", len(response.Components), statuses)
 
-	autoCreatedOpenIncidents, err := getOpenIncidentsWithRules(ctx)
+	if err := evaluateComponents(api.NewContext(db.Gorm, nil), response.Components); err != nil {
+		return err
+	}
+
+	if selectsChecks() {
+		checks, err := db.GetFailingChecks(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := evaluateResources(api.NewContext(db.Gorm, nil), checkResources(checks)); err != nil {
+			return err
+		}
+	}
+
+	if selectsConfigAnalyses() {
+		analyses, err := db.GetOpenConfigAnalyses(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := evaluateResources(api.NewContext(db.Gorm, nil), configAnalysisResources(analyses)); err != nil {
+			return err
+		}
+	}
+
+	return autoCloseIncidents(ctx)
+}
+
+// EvaluateComponents evaluates the rules against the given components,
+// e.g. the components whose status changed.
+func EvaluateComponents(ctx *api.Context, componentIDs []uuid.UUID) error {
+	evaluationLock.Lock()
+	defer evaluationLock.Unlock()
+
+	if err := loadRules(ctx); err != nil {
+		return err
+	}
+
+	components, err := db.GetTopologyComponents(ctx, componentIDs)
+	if err != nil {
+		return err
+	}
+
+	return evaluateComponents(ctx, components)
+}
+
+// EvaluateChecks evaluates the rules against the given checks, e.g. the checks that just failed.
+// Checks that have passed since are skipped.
+func EvaluateChecks(ctx *api.Context, checkIDs []uuid.UUID) error {
+	if len(checkIDs) == 0 {
+		return nil
+	}
+
+	evaluationLock.Lock()
+	defer evaluationLock.Unlock()
+
+	if err := loadRules(ctx); err != nil {
+		return err
+	}
+
+	checks, err := db.GetFailingChecks(ctx, checkIDs)
 	if err != nil {
 		return err
 	}
 
-	return createIncidents(autoCreatedOpenIncidents, response.Components)
+	return evaluateResources(ctx, checkResources(checks))
 }
 
-// createIncidents creates incidents based on the components
-// and incident rules.
-func createIncidents(openIncidentsMap map[string]map[string]struct{}, components dutyModels.Components) error {
-outer:
+// EvaluateConfigAnalyses evaluates the rules against the given config analyses.
+// Analyses that aren't open anymore are skipped.
+func EvaluateConfigAnalyses(ctx *api.Context, analysisIDs []uuid.UUID) error {
+	if len(analysisIDs) == 0 {
+		return nil
+	}
+
+	evaluationLock.Lock()
+	defer evaluationLock.Unlock()
+
+	if err := loadRules(ctx); err != nil {
+		return err
+	}
+
+	analyses, err := db.GetOpenConfigAnalyses(ctx, analysisIDs)
+	if err != nil {
+		return err
+	}
+
+	return evaluateResources(ctx, configAnalysisResources(analyses))
+}
+
+// AutoClose closes the incidents of the rules whose components have recovered.
+func AutoClose() error {
+	ctx := context.Background()
+
+	evaluationLock.Lock()
+	defer evaluationLock.Unlock()
+
+	if err := loadRules(ctx); err != nil {
+		return err
+	}
+
+	return autoCloseIncidents(ctx)
+}
+
+func loadRules(ctx context.Context) error {
+	if err := db.Gorm.WithContext(ctx).Order("name").Find(&Rules).Error; err != nil {
+		return err
+	}
+	sortByPriority(Rules)
+	return nil
+}
+
+func evaluateComponents(ctx *api.Context, components dutyModels.Components) error {
+	componentIDs := make([]uuid.UUID, 0, len(components))
 	for _, component := range components {
+		componentIDs = append(componentIDs, component.ID)
+	}
+	statusSince, err := db.GetComponentStatusSince(ctx, componentIDs)
+	if err != nil {
+		return err
+	}
+
+	return evaluateResources(ctx, componentResources(components, statusSince))
+}
+
+func evaluateResources(ctx *api.Context, resources []resource) error {
+	if len(resources) == 0 {
+		return nil
+	}
+
+	state, err := loadEvaluationState(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	trace, err := createIncidents(ctx, state, resources)
+	persistTrace(ctx, trace)
+	return err
+}
+
+// createIncidents creates incidents based on the resources
+// and incident rules. The rules are evaluated in the order of their priority
+// and a matching rule with BreakOnMatch stops the evaluation of the remaining rules
+// for the resource, whether or not its incident already exists.
+// A resource whose incident fails to be created is skipped, with the failure in the trace,
+// and the error is returned once all the resources have been evaluated.
+func createIncidents(ctx *api.Context, state evaluationState, resources []resource) (Trace, error) {
+	var trace Trace
+	var errs []string
+	now := time.Now()
+	for _, res := range resources {
 		for _, _rule := range Rules {
-			rule, err := _rule.GetSpec()
+			evaluation, err := evaluate(ctx, _rule, state, res, now, false)
+			trace = append(trace, evaluation)
+			logger.Debugf("Rule %s evaluated against %s: %s %s", _rule.Name, res.id(), evaluation.Result, evaluation.Reason)
 			if err != nil {
-				logger.Errorf("error fetching rule spec: %s, %v", _rule.Name, err)
-				continue
+				logger.Errorf("error creating incident of rule %s for %s: %v", _rule.Name, res.id(), err)
+				errs = append(errs, fmt.Sprintf("rule %s for %s: %v", _rule.Name, res.id(), err))
+				break
 			}
 
-			if matches(rule, *component) {
-				logger.Infof("Rule %s matched component %s", rule, component)
-
-				incident := rule.Template.GenerateIncident()
-				incident.IncidentRuleID = _rule.ID
-				incident.Status = api.IncidentStatusOpen
-				incident.Severity = "Low" // TODO: make this configurable
-				if incident.Type == "" {
-					incident.Type = api.IncidentTypeAvailability
-				}
-				incident.Title = component.Name + " is " + string(component.Status)
-
-				if _, ok := openIncidentsMap[_rule.ID.String()][component.ID.String()]; ok {
-					logger.Debugf("Incident %s already exists", incident.Title)
-					continue // this rule already created this incident
-				}
-
-				if err := db.Gorm.Create(&incident).Error; err != nil {
-					return err
-				}
-
-				hypothesis := api.Hypothesis{
-					IncidentID: *incident.ID,
-					Title:      component.Name + " is " + string(component.Status),
-					Type:       "factor",
-				}
-
-				if err := db.Gorm.Create(&hypothesis).Error; err != nil {
-					return err
-				}
-
-				evidence := api.Evidence{
-					HypothesisID:     hypothesis.ID,
-					ComponentID:      &component.ID,
-					DefinitionOfDone: true,
-					Type:             "topology",
-					Description:      component.Name + " is " + string(component.Status),
-				}
-
-				if err := db.Gorm.Create(&evidence).Error; err != nil {
-					return err
-				}
-
-				// create incident
-				if rule.BreakOnMatch {
-					continue outer
-				}
+			if evaluation.Matched && evaluation.BreakOnMatch {
+				break
 			}
 		}
 	}
 
-	return nil
+	if len(errs) != 0 {
+		return trace, fmt.Errorf("error creating incidents: %s", strings.Join(errs, "; "))
+	}
+	return trace, nil
 }
 
-func matches(rule *api.IncidentRuleSpec, component dutyModels.Component) bool {
-	if len(rule.Filter.Status) > 0 && !contains(rule.Filter.Status, string(component.Status)) {
-		return false
+// evaluate evaluates the rule against the resource and creates its incident
+// if it matches and the rule hasn't already created one for the resource.
+// The resource is added to the open incident of its group instead, if the rule groups its resources,
+// and nothing's opened while the resource is silenced.
+// On a dry run, the incident that would be created is only added to the evaluation.
+func evaluate(ctx *api.Context, _rule models.IncidentRule, state evaluationState, res resource, now time.Time, dryRun bool) (Evaluation, error) {
+	evaluation := Evaluation{
+		RuleID:     _rule.ID.String(),
+		Rule:       _rule.Name,
+		ResourceID: res.id().String(),
+		Resource:   res.name(),
 	}
 
-	for _, selector := range rule.Components {
-		if matchesSelector(selector, component) {
-			return true
+	rule, err := _rule.GetSpec()
+	if err != nil {
+		logger.Errorf("error fetching rule spec: %s, %v", _rule.Name, err)
+		evaluation.Result, evaluation.Reason = ResultInvalid, err.Error()
+		return evaluation, nil
+	}
+	evaluation.Priority = rule.Priority
+	evaluation.BreakOnMatch = rule.BreakOnMatch
+
+	if active, err := withinHoursOfOperation(rule.HoursOfOperation, now); err != nil {
+		logger.Errorf("invalid hours of operation of rule %s: %v", _rule.Name, err)
+		evaluation.Result, evaluation.Reason = ResultInvalid, err.Error()
+		return evaluation, nil
+	} else if !active {
+		evaluation.Result, evaluation.Reason = ResultInactive, "outside of the hours of operation"
+		return evaluation, nil
+	}
+
+	fingerprint, err := groupFingerprint(rule.Group, res)
+	if err != nil {
+		evaluation.Result, evaluation.Reason = ResultInvalid, err.Error()
+		return evaluation, nil
+	}
+
+	if reason := res.mismatch(rule); reason != "" {
+		evaluation.Result, evaluation.Reason = ResultNotMatched, reason
+		return evaluation, nil
+	}
+
+	logger.Infof("Rule %s matched %s", rule, res.summary())
+	evaluation.Matched = true
+
+	incident, err := newIncident(ctx, _rule, rule, res)
+	if err != nil {
+		logger.Errorf("error rendering incident of rule %s for %s: %v", _rule.Name, res.id(), err)
+		evaluation.Result, evaluation.Reason = ResultError, err.Error()
+		return evaluation, nil
+	}
+	evaluation.Title, evaluation.Severity = incident.Title, incident.Severity
+	if dryRun {
+		evaluation.Incident = &incident
+	}
+
+	if _, ok := state.byRule[_rule.ID.String()][res.id().String()]; ok {
+		logger.Debugf("Incident %s already exists", incident.Title)
+		evaluation.Result, evaluation.Reason = ResultDeduplicated, "the rule's incident for the resource is already open"
+		return evaluation, nil
+	}
+
+	if silenced := state.silencedBy(rule, res); silenced != nil {
+		evaluation.Result, evaluation.Reason = ResultSilenced, fmt.Sprintf("silenced by %s", silenced.Name)
+		if !dryRun {
+			suppression := api.SilenceSuppression{
+				SilenceID:   silenced.ID,
+				Action:      silence.ActionIncidentRule,
+				SourceID:    _rule.ID.String(),
+				ResourceID:  res.id().String(),
+				Description: incident.Title,
+			}
+			if err := silence.Record(ctx.DB(), suppression); err != nil {
+				logger.Errorf("error recording the suppression of rule %s by silence %s: %v", _rule.Name, silenced.Name, err)
+			}
 		}
+		return evaluation, nil
 	}
 
-	return false
+	if group := state.group(fingerprint, rule.Group, now); group != nil {
+		if group.ID != uuid.Nil {
+			evaluation.IncidentID = group.ID.String()
+		}
+		if _, ok := group.Resources[res.id().String()]; ok {
+			evaluation.Result, evaluation.Reason = ResultDeduplicated, "the resource is already part of its group's open incident"
+			return evaluation, nil
+		}
+
+		if !dryRun {
+			if err := addToIncident(ctx, _rule, group, res); err != nil {
+				evaluation.Result, evaluation.Reason = ResultError, err.Error()
+				return evaluation, err
+			}
+		}
+		group.Resources[res.id().String()] = struct{}{}
+		evaluation.Result = ResultGrouped
+		if dryRun {
+			evaluation.Result = ResultWouldGroup
+		}
+		return evaluation, nil
+	}
+
+	if dryRun {
+		evaluation.Result = ResultWouldCreate
+		if fingerprint != "" {
+			state.groups[fingerprint] = &db.GroupedIncident{CreatedAt: now, Resources: map[string]struct{}{res.id().String(): {}}}
+		}
+		return evaluation, nil
+	}
+
+	hypothesisID, err := createIncident(ctx, rule, &incident, res, fingerprint)
+	if err != nil {
+		evaluation.Result, evaluation.Reason = ResultError, err.Error()
+		return evaluation, err
+	}
+	if fingerprint != "" {
+		state.groups[fingerprint] = &db.GroupedIncident{
+			ID:           *incident.ID,
+			CreatedAt:    now,
+			HypothesisID: hypothesisID,
+			Resources:    map[string]struct{}{res.id().String(): {}},
+		}
+	}
+	evaluation.Result, evaluation.IncidentID = ResultCreated, incident.ID.String()
+	return evaluation, nil
 }
 
-func matchesSelector(selector api.ComponentSelector, component dutyModels.Component) bool {
-	if selector.Name != "" && selector.Name != component.Name {
-		return false
+// newIncident returns the open incident of the rule for the resource.
+func newIncident(ctx *api.Context, _rule models.IncidentRule, rule *api.IncidentRuleSpec, res resource) (api.Incident, error) {
+	incident, err := renderIncident(ctx, _rule.ID.String(), rule.Template, res.env())
+	if err != nil {
+		return incident, err
 	}
 
-	if selector.Namespace != "" && selector.Namespace != component.Namespace {
-		return false
+	incident.IncidentRuleID = _rule.ID
+	incident.Status = api.IncidentStatusOpen
+	if incident.Type == "" {
+		incident.Type = api.IncidentTypeAvailability
+	}
+	if incident.Title == "" {
+		incident.Title = res.summary()
 	}
+	return incident, nil
+}
+
+// createIncident creates the incident with the resource as its evidence, and the fingerprint
+// of the resource's group if it has one, in a transaction. It returns the hypothesis of the evidence.
+func createIncident(ctx *api.Context, rule *api.IncidentRuleSpec, incident *api.Incident, res resource, fingerprint string) (uuid.UUID, error) {
+	var hypothesisID uuid.UUID
+	err := ctx.DB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(incident).Error; err != nil {
+			return err
+		}
 
-	if !selector.Types.Contains(component.Type) {
-		return false
+		if fingerprint != "" {
+			incidentFingerprint := api.IncidentFingerprint{
+				IncidentID:     *incident.ID,
+				Fingerprint:    fingerprint,
+				IncidentRuleID: incident.IncidentRuleID,
+			}
+			if err := tx.Create(&incidentFingerprint).Error; err != nil {
+				return err
+			}
+		}
+
+		hypothesis := api.Hypothesis{
+			IncidentID: *incident.ID,
+			Title:      res.summary(),
+			Type:       "factor",
+		}
+		if err := tx.Create(&hypothesis).Error; err != nil {
+			return err
+		}
+
+		evidence := res.evidence()
+		evidence.HypothesisID = hypothesis.ID
+		if err := tx.Create(&evidence).Error; err != nil {
+			return err
+		}
+
+		hypothesisID = hypothesis.ID
+		return nil
+	})
+	if err != nil {
+		return uuid.Nil, err
 	}
 
-	return true
+	// The incident is already open, so it's kept even if its responders can't be added
+	err = ctx.DB().Transaction(func(tx *gorm.DB) error {
+		return addRuleResponders(tx, *incident, rule.IncidentResponders)
+	})
+	if err != nil {
+		logger.Errorf("error adding responders of rule %s to incident %s: %v", incident.IncidentRuleID, incident.ID, err)
+	}
+
+	return hypothesisID, nil
 }
 
-func contains(list []string, item string) bool {
-	for _, i := range list {
-		if i == item {
-			return true
+// addToIncident adds the resource as evidence to the open incident of its group
+// and records the addition in the incident's history.
+func addToIncident(ctx *api.Context, _rule models.IncidentRule, group *db.GroupedIncident, res resource) error {
+	if group.HypothesisID == uuid.Nil {
+		return fmt.Errorf("incident %s of the group has no hypothesis to add the evidence to", group.ID)
+	}
+
+	logger.Infof("Adding %s to incident %s of its group", res.name(), group.ID)
+	return ctx.DB().Transaction(func(tx *gorm.DB) error {
+		evidence := res.evidence()
+		evidence.HypothesisID = group.HypothesisID
+		if err := tx.Create(&evidence).Error; err != nil {
+			return err
+		}
+
+		history := api.IncidentHistory{
+			IncidentID:   group.ID,
+			Type:         "incident.grouped",
+			Description:  fmt.Sprintf("%s added by rule %s", res.summary(), _rule.Name),
+			HypothesisID: &group.HypothesisID,
+			CreatedBy:    api.SystemUserID,
 		}
+		return tx.Create(&history).Error
+	})
+}
+
+// evaluationState is what the matches of the rules are checked against before opening their incidents.
+type evaluationState struct {
+	// byRule are the ids of the resources of the open incidents of each rule
+	byRule map[string]map[string]struct{}
+	// groups are the latest open incidents of each group fingerprint
+	groups map[string]*db.GroupedIncident
+	// silences are the active silences
+	silences []api.Silence
+}
+
+func loadEvaluationState(ctx context.Context, now time.Time) (evaluationState, error) {
+	byRule, err := getOpenIncidentsWithRules(ctx)
+	if err != nil {
+		return evaluationState{}, err
 	}
-	return false
+
+	groups, err := db.GetOpenGroupedIncidents(ctx)
+	if err != nil {
+		return evaluationState{}, err
+	}
+
+	silences, err := silence.Active(ctx, now)
+	if err != nil {
+		return evaluationState{}, err
+	}
+
+	return evaluationState{byRule: byRule, groups: groups, silences: silences}, nil
+}
+
+// silencedBy returns the silence of the resource or of the team of the rule's responders, if any.
+func (s evaluationState) silencedBy(rule *api.IncidentRuleSpec, res resource) *api.Silence {
+	target := res.target()
+	if team := rule.IncidentResponders.Team; team != "" {
+		target.Teams = append(target.Teams, silence.Ref{ID: team, Name: team})
+	}
+	return silence.Find(s.silences, target)
+}
+
+// group returns the open incident of the fingerprint, if resources can still be added to it.
+func (s evaluationState) group(fingerprint string, grouping *api.IncidentGrouping, now time.Time) *db.GroupedIncident {
+	if fingerprint == "" {
+		return nil
+	}
+
+	group, ok := s.groups[fingerprint]
+	if !ok {
+		return nil
+	}
+
+	if grouping.Window != nil && now.Sub(group.CreatedAt) > *grouping.Window {
+		return nil
+	}
+	return group
 }
 
-// getOpenIncidentsWithRules generates a map linking incident rules, which led to the creation of open incidents, to their respective involved component ids.
+// getOpenIncidentsWithRules generates a map linking incident rules, which led to the creation of open incidents,
+// to their respective involved component, check or config analysis ids.
 func getOpenIncidentsWithRules(ctx context.Context) (map[string]map[string]struct{}, error) {
 	query := `
 	SELECT
 		incidents.incident_rule_id,
-		evidences.component_id
+		COALESCE(evidences.component_id, evidences.check_id, evidences.config_analysis_id)
 	FROM
 		incidents
 		LEFT JOIN hypotheses ON hypotheses.incident_id = incidents.id
 		LEFT JOIN evidences ON evidences.hypothesis_id = hypotheses.id
 	WHERE
 		incidents.incident_rule_id IS NOT NULL
-		AND evidences.component_id IS NOT NULL
+		AND COALESCE(evidences.component_id, evidences.check_id, evidences.config_analysis_id) IS NOT NULL
 		AND incidents.status = ?`
 	rows, err := db.Gorm.WithContext(ctx).Raw(query, api.IncidentStatusOpen).Rows()
 	if err != nil {
@@ -182,17 +574,17 @@ func getOpenIncidentsWithRules(ctx context.Context) (map[string]map[string]struc
 	autoCreatedOpenIncidents := make(map[string]map[string]struct{})
 	for rows.Next() {
 		var (
-			ruleID      string
-			componentID string
+			ruleID     string
+			resourceID string
 		)
-		if err := rows.Scan(&ruleID, &componentID); err != nil {
+		if err := rows.Scan(&ruleID, &resourceID); err != nil {
 			return nil, err
 		}
 
 		if _, ok := autoCreatedOpenIncidents[ruleID]; !ok {
 			autoCreatedOpenIncidents[ruleID] = make(map[string]struct{})
 		}
-		autoCreatedOpenIncidents[ruleID][componentID] = struct{}{}
+		autoCreatedOpenIncidents[ruleID][resourceID] = struct{}{}
 	}
 	logger.Debugf("Found %d open incidents created by incident rules.", len(autoCreatedOpenIncidents))
 	return autoCreatedOpenIncidents, nil
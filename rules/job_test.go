@@ -0,0 +1,122 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	dutyModels "github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/types"
+	"github.com/google/uuid"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db/models"
+)
+
+func newRule(t *testing.T, name string, spec api.IncidentRuleSpec) models.IncidentRule {
+	id := uuid.New()
+	rule := models.IncidentRule{ID: &id, Name: name}
+	if err := rule.SetSpec(spec); err != nil {
+		t.Fatal(err)
+	}
+	return rule
+}
+
+func TestCreateIncidentsPriorityAndBreakOnMatch(t *testing.T) {
+	component := &dutyModels.Component{
+		ID:        uuid.New(),
+		Name:      "payments",
+		Namespace: "prod",
+		Status:    types.ComponentStatusUnhealthy,
+	}
+	selector := []api.ComponentSelector{{Namespace: "prod"}}
+
+	staging := newRule(t, "staging", api.IncidentRuleSpec{Priority: 3, Components: []api.ComponentSelector{{Namespace: "staging"}}})
+	page := newRule(t, "page", api.IncidentRuleSpec{Priority: 2, BreakOnMatch: true, Components: selector})
+	ticket := newRule(t, "ticket", api.IncidentRuleSpec{Priority: 4, Components: selector})
+	audit := newRule(t, "audit", api.IncidentRuleSpec{Priority: 1, Components: selector})
+
+	// The matching rules' incidents are already open, so they're only deduplicated
+	open := map[string]map[string]struct{}{}
+	for _, rule := range []models.IncidentRule{page, ticket, audit} {
+		open[rule.ID.String()] = map[string]struct{}{component.ID.String(): {}}
+	}
+
+	tests := []struct {
+		name  string
+		rules []models.IncidentRule
+		want  []string
+	}{
+		{
+			name:  "break on a deduplicated match",
+			rules: []models.IncidentRule{ticket, staging, page, audit},
+			want:  []string{"audit:" + ResultDeduplicated, "page:" + ResultDeduplicated},
+		},
+		{
+			name:  "no break",
+			rules: []models.IncidentRule{ticket, staging, audit},
+			want:  []string{"audit:" + ResultDeduplicated, "staging:" + ResultNotMatched, "ticket:" + ResultDeduplicated},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Rules = tt.rules
+			sortByPriority(Rules)
+
+			trace, err := createIncidents(api.NewContext(nil, nil), evaluationState{byRule: open}, componentResources(dutyModels.Components{component}, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, e := range trace {
+				got = append(got, e.Rule+":"+e.Result)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("createIncidents() trace = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("createIncidents() trace = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluateSilenced(t *testing.T) {
+	component := componentResource{component: dutyModels.Component{
+		ID:        uuid.New(),
+		Name:      "payments",
+		Namespace: "prod",
+		Status:    types.ComponentStatusUnhealthy,
+	}}
+	rule := newRule(t, "page", api.IncidentRuleSpec{
+		Components:         []api.ComponentSelector{{Namespace: "prod"}},
+		IncidentResponders: api.IncidentResponders{Team: "sre"},
+	})
+
+	tests := []struct {
+		name     string
+		silences []api.Silence
+		want     string
+	}{
+		{name: "no silences", want: ResultWouldCreate},
+		{name: "silenced component", silences: []api.Silence{{Name: "maintenance", Components: []string{"payments"}}}, want: ResultSilenced},
+		{name: "silenced team", silences: []api.Silence{{Name: "offsite", Teams: []string{"sre"}}}, want: ResultSilenced},
+		{name: "other component", silences: []api.Silence{{Name: "maintenance", Components: []string{"orders"}}}, want: ResultWouldCreate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := evaluationState{silences: tt.silences}
+			evaluation, err := evaluate(api.NewContext(nil, nil), rule, state, component, time.Now(), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if evaluation.Result != tt.want {
+				t.Errorf("evaluate() result = %s (%s), want %s", evaluation.Result, evaluation.Reason, tt.want)
+			}
+		})
+	}
+}
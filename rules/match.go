@@ -0,0 +1,127 @@
+package rules
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	dutyModels "github.com/flanksource/duty/models"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/flanksource/incident-commander/api"
+)
+
+// matches returns true if the component passes the rule's filter and any of its selectors.
+// statusSince is when the component entered its current status.
+func matches(rule *api.IncidentRuleSpec, component dutyModels.Component, statusSince time.Time) bool {
+	return mismatch(rule, component, statusSince) == ""
+}
+
+// mismatch returns why the component doesn't match the rule, or an empty string if it does.
+func mismatch(rule *api.IncidentRuleSpec, component dutyModels.Component, statusSince time.Time) string {
+	if len(rule.Filter.Status) > 0 && !contains(rule.Filter.Status, string(component.Status)) {
+		return fmt.Sprintf("status %s is not one of %v", component.Status, rule.Filter.Status)
+	}
+
+	if rule.Filter.Age != nil && time.Since(statusSince) < *rule.Filter.Age {
+		return fmt.Sprintf("%s for less than %s", component.Status, *rule.Filter.Age)
+	}
+
+	if !matchesAnalysis(rule.Filter, component) {
+		return "no matching config analysis"
+	}
+
+	for _, selector := range rule.Components {
+		if matchesSelector(selector, component) {
+			return ""
+		}
+	}
+
+	return "no matching component selector"
+}
+
+func matchesSelector(selector api.ComponentSelector, component dutyModels.Component) bool {
+	if selector.Name != "" && selector.Name != component.Name {
+		return false
+	}
+
+	if selector.Namespace != "" && selector.Namespace != component.Namespace {
+		return false
+	}
+
+	if !selector.Types.Contains(component.Type) {
+		return false
+	}
+
+	if len(selector.Labels) > 0 && !labels.SelectorFromSet(selector.Labels).Matches(labels.Set(component.Labels)) {
+		return false
+	}
+
+	// Selector is a kubernetes label selector, e.g. "env in (prod,staging),!canary"
+	if selector.Selector != "" {
+		parsed, err := labels.Parse(selector.Selector)
+		if err != nil {
+			logger.Errorf("invalid selector %q: %v", selector.Selector, err)
+			return false
+		}
+		if !parsed.Matches(labels.Set(component.Labels)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesAnalysis returns true if the component has a config analysis
+// of any of the filter's categories and severities.
+func matchesAnalysis(filter api.Filter, component dutyModels.Component) bool {
+	if len(filter.Severity) == 0 && len(filter.Category) == 0 {
+		return true
+	}
+
+	for category, severities := range component.Analysis {
+		if !api.Items(filter.Category).Contains(category) {
+			continue
+		}
+
+		for severity, count := range severities {
+			if count > 0 && api.Items(filter.Severity).Contains(severity) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withinHoursOfOperation returns true if t falls in any of the windows that aren't negated,
+// or if there are none, and in none of the negated windows.
+func withinHoursOfOperation(hours []api.HoursOfOperation, t time.Time) (bool, error) {
+	included, hasIncluded := false, false
+	for _, h := range hours {
+		contains, err := h.Contains(t)
+		if err != nil {
+			return false, err
+		}
+
+		if h.Negate {
+			if contains {
+				return false, nil
+			}
+			continue
+		}
+
+		hasIncluded = true
+		included = included || contains
+	}
+
+	return included || !hasIncluded, nil
+}
+
+func contains(list []string, item string) bool {
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	dutyModels "github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/types"
+
+	"github.com/flanksource/incident-commander/api"
+)
+
+func TestMatches(t *testing.T) {
+	component := dutyModels.Component{
+		Name:      "payments",
+		Namespace: "prod",
+		Type:      "Service",
+		Status:    types.ComponentStatusUnhealthy,
+		Labels:    types.JSONStringMap{"env": "prod", "team": "billing"},
+		Analysis: map[string]map[string]int{
+			"security": {"critical": 1},
+			"cost":     {"low": 0},
+		},
+	}
+
+	hour := time.Hour
+	tests := []struct {
+		name  string
+		rule  api.IncidentRuleSpec
+		since time.Duration
+		want  bool
+	}{
+		{
+			name: "namespace",
+			rule: api.IncidentRuleSpec{Components: []api.ComponentSelector{{Namespace: "prod"}}},
+			want: true,
+		},
+		{
+			name: "status filter",
+			rule: api.IncidentRuleSpec{
+				Filter:     api.Filter{Status: []string{string(types.ComponentStatusError)}},
+				Components: []api.ComponentSelector{{Namespace: "prod"}},
+			},
+		},
+		{
+			name: "labels",
+			rule: api.IncidentRuleSpec{Components: []api.ComponentSelector{{Labels: map[string]string{"env": "prod", "team": "billing"}}}},
+			want: true,
+		},
+		{
+			name: "labels mismatch",
+			rule: api.IncidentRuleSpec{Components: []api.ComponentSelector{{Labels: map[string]string{"env": "staging"}}}},
+		},
+		{
+			name: "set based selector",
+			rule: api.IncidentRuleSpec{Components: []api.ComponentSelector{{Selector: "env in (prod,staging),!canary"}}},
+			want: true,
+		},
+		{
+			name: "set based selector mismatch",
+			rule: api.IncidentRuleSpec{Components: []api.ComponentSelector{{Selector: "team notin (billing)"}}},
+		},
+		{
+			name: "invalid selector",
+			rule: api.IncidentRuleSpec{Components: []api.ComponentSelector{{Selector: "env in prod"}}},
+		},
+		{
+			name: "any of the selectors",
+			rule: api.IncidentRuleSpec{Components: []api.ComponentSelector{{Name: "orders"}, {Types: api.Items{"Service"}}}},
+			want: true,
+		},
+		{
+			name: "failing for long enough",
+			rule: api.IncidentRuleSpec{
+				Filter:     api.Filter{Age: &hour},
+				Components: []api.ComponentSelector{{Namespace: "prod"}},
+			},
+			since: 2 * time.Hour,
+			want:  true,
+		},
+		{
+			name: "not failing for long enough",
+			rule: api.IncidentRuleSpec{
+				Filter:     api.Filter{Age: &hour},
+				Components: []api.ComponentSelector{{Namespace: "prod"}},
+			},
+			since: 10 * time.Minute,
+		},
+		{
+			name: "analysis category and severity",
+			rule: api.IncidentRuleSpec{
+				Filter:     api.Filter{Category: []string{"security"}, Severity: []string{"critical", "high"}},
+				Components: []api.ComponentSelector{{Namespace: "prod"}},
+			},
+			want: true,
+		},
+		{
+			name: "analysis without findings",
+			rule: api.IncidentRuleSpec{
+				Filter:     api.Filter{Category: []string{"cost"}},
+				Components: []api.ComponentSelector{{Namespace: "prod"}},
+			},
+		},
+		{
+			name: "analysis of any category",
+			rule: api.IncidentRuleSpec{
+				Filter:     api.Filter{Category: []string{"*"}, Severity: []string{"!low"}},
+				Components: []api.ComponentSelector{{Namespace: "prod"}},
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matches(&tt.rule, component, time.Now().Add(-tt.since)); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithinHoursOfOperation(t *testing.T) {
+	// A Monday, 22:30 in UTC and 00:30 in Europe/Berlin
+	now := time.Date(2023, 7, 3, 22, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		hours   []api.HoursOfOperation
+		want    bool
+		wantErr bool
+	}{
+		{name: "no windows", want: true},
+		{name: "inside", hours: []api.HoursOfOperation{{Start: "09:00", End: "23:00"}}, want: true},
+		{name: "outside", hours: []api.HoursOfOperation{{Start: "09:00", End: "17:00"}}},
+		{name: "end is exclusive", hours: []api.HoursOfOperation{{Start: "09:00", End: "22:30"}}},
+		{name: "spans midnight", hours: []api.HoursOfOperation{{Start: "22:00", End: "06:00"}}, want: true},
+		{name: "timezone", hours: []api.HoursOfOperation{{Start: "00:00", End: "01:00", Timezone: "Europe/Berlin"}}, want: true},
+		{name: "any window", hours: []api.HoursOfOperation{{Start: "09:00", End: "12:00"}, {Start: "22:00", End: "23:00"}}, want: true},
+		{name: "negated", hours: []api.HoursOfOperation{{Start: "22:00", End: "23:00", Negate: true}}},
+		{name: "outside negated", hours: []api.HoursOfOperation{{Start: "09:00", End: "17:00", Negate: true}}, want: true},
+		{
+			name: "negation wins",
+			hours: []api.HoursOfOperation{
+				{Start: "00:00", End: "23:59"},
+				{Start: "22:00", End: "23:00", Negate: true},
+			},
+		},
+		{name: "invalid time", hours: []api.HoursOfOperation{{Start: "9am", End: "17:00"}}, wantErr: true},
+		{name: "invalid timezone", hours: []api.HoursOfOperation{{Start: "09:00", End: "17:00", Timezone: "Mars/Olympus"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := withinHoursOfOperation(tt.hours, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("withinHoursOfOperation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("withinHoursOfOperation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
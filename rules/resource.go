@@ -0,0 +1,246 @@
+package rules
+
+import (
+	"fmt"
+	"time"
+
+	dutyModels "github.com/flanksource/duty/models"
+	"github.com/google/uuid"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+	"github.com/flanksource/incident-commander/silence"
+)
+
+// resource is what the rules are evaluated against, e.g. a component.
+type resource interface {
+	id() uuid.UUID
+	name() string
+	// mismatch returns why the resource doesn't match the rule, or an empty string if it does.
+	mismatch(rule *api.IncidentRuleSpec) string
+	// env is the resource as it's exposed to the templates and expressions of the rule
+	env() map[string]any
+	// summary describes the failure of the resource, e.g. "payments is unhealthy"
+	summary() string
+	// evidence links the resource to the incident
+	evidence() api.Evidence
+	// labels, namespace and parent are what the resource is grouped by
+	labels() map[string]string
+	namespace() string
+	parent() string
+	// target is what the silences are matched against
+	target() silence.Target
+}
+
+type componentResource struct {
+	component dutyModels.Component
+	// since is when the component entered its current status
+	since time.Time
+}
+
+// componentResources returns the components as resources. Components without
+// a status history have been in their status since at least their last update.
+func componentResources(components dutyModels.Components, statusSince map[uuid.UUID]time.Time) []resource {
+	resources := make([]resource, 0, len(components))
+	for _, component := range components {
+		since, ok := statusSince[component.ID]
+		if !ok {
+			since = component.UpdatedAt
+		}
+		resources = append(resources, componentResource{component: *component, since: since})
+	}
+	return resources
+}
+
+func (c componentResource) id() uuid.UUID { return c.component.ID }
+
+func (c componentResource) name() string { return c.component.Name }
+
+func (c componentResource) mismatch(rule *api.IncidentRuleSpec) string {
+	return mismatch(rule, c.component, c.since)
+}
+
+func (c componentResource) env() map[string]any {
+	return map[string]any{"component": asMap(c.component)}
+}
+
+func (c componentResource) summary() string {
+	return c.component.Name + " is " + string(c.component.Status)
+}
+
+func (c componentResource) evidence() api.Evidence {
+	return api.Evidence{
+		ComponentID:      &c.component.ID,
+		DefinitionOfDone: true,
+		Type:             "topology",
+		Description:      c.summary(),
+	}
+}
+
+func (c componentResource) labels() map[string]string { return c.component.Labels }
+
+func (c componentResource) namespace() string { return c.component.Namespace }
+
+func (c componentResource) parent() string {
+	if c.component.ParentId == nil {
+		return ""
+	}
+	return c.component.ParentId.String()
+}
+
+func (c componentResource) target() silence.Target {
+	return silence.Target{
+		Components: []silence.Ref{{ID: c.component.ID.String(), Name: c.component.Name}},
+		Labels:     c.component.Labels,
+	}
+}
+
+type checkResource struct {
+	check db.FailingCheck
+}
+
+func checkResources(checks []db.FailingCheck) []resource {
+	resources := make([]resource, 0, len(checks))
+	for _, check := range checks {
+		resources = append(resources, checkResource{check: check})
+	}
+	return resources
+}
+
+func (c checkResource) id() uuid.UUID { return c.check.ID }
+
+func (c checkResource) name() string { return c.check.Name }
+
+func (c checkResource) mismatch(rule *api.IncidentRuleSpec) string {
+	for _, selector := range rule.Checks {
+		if matchesCheck(selector, c.check) {
+			return ""
+		}
+	}
+	return "no matching check selector"
+}
+
+func matchesCheck(selector api.CheckSelector, check db.FailingCheck) bool {
+	if selector.Canary != "" && selector.Canary != check.CanaryName && selector.Canary != check.CanaryID.String() {
+		return false
+	}
+
+	if selector.Name != "" && selector.Name != check.Name {
+		return false
+	}
+
+	if len(selector.Labels) > 0 && !labels.SelectorFromSet(selector.Labels).Matches(labels.Set(check.Labels)) {
+		return false
+	}
+
+	consecutiveFailures := selector.ConsecutiveFailures
+	if consecutiveFailures < 1 {
+		consecutiveFailures = 1
+	}
+	return check.ConsecutiveFailures >= consecutiveFailures
+}
+
+func (c checkResource) env() map[string]any {
+	check := asMap(c.check.Check)
+	check["consecutive_failures"] = c.check.ConsecutiveFailures
+	return map[string]any{
+		"check": check,
+		"canary": map[string]any{
+			"id":        c.check.CanaryID.String(),
+			"name":      c.check.CanaryName,
+			"namespace": c.check.Namespace,
+		},
+	}
+}
+
+func (c checkResource) summary() string {
+	return fmt.Sprintf("%s has failed %d times in a row", c.check.Name, c.check.ConsecutiveFailures)
+}
+
+func (c checkResource) evidence() api.Evidence {
+	return api.Evidence{
+		CheckID:          &c.check.ID,
+		DefinitionOfDone: true,
+		Type:             "check",
+		Description:      c.summary(),
+	}
+}
+
+func (c checkResource) labels() map[string]string { return c.check.Labels }
+
+func (c checkResource) namespace() string { return c.check.Namespace }
+
+// parent of a check is its canary
+func (c checkResource) parent() string { return c.check.CanaryID.String() }
+
+func (c checkResource) target() silence.Target {
+	return silence.Target{
+		Checks: []silence.Ref{{ID: c.check.ID.String(), Name: c.check.Name}},
+		Labels: c.check.Labels,
+	}
+}
+
+type configAnalysisResource struct {
+	analysis db.OpenConfigAnalysis
+}
+
+func configAnalysisResources(analyses []db.OpenConfigAnalysis) []resource {
+	resources := make([]resource, 0, len(analyses))
+	for _, analysis := range analyses {
+		resources = append(resources, configAnalysisResource{analysis: analysis})
+	}
+	return resources
+}
+
+func (c configAnalysisResource) id() uuid.UUID { return c.analysis.ID }
+
+func (c configAnalysisResource) name() string { return c.analysis.ConfigName }
+
+func (c configAnalysisResource) mismatch(rule *api.IncidentRuleSpec) string {
+	for _, selector := range rule.ConfigAnalysis {
+		if selector.Analyzer.Contains(c.analysis.Analyzer) &&
+			selector.Severity.Contains(string(c.analysis.Severity)) &&
+			selector.ConfigType.Contains(c.analysis.ConfigType) {
+			return ""
+		}
+	}
+	return "no matching config analysis selector"
+}
+
+func (c configAnalysisResource) env() map[string]any {
+	return map[string]any{
+		"config_analysis": asMap(c.analysis.ConfigAnalysis),
+		"config": map[string]any{
+			"id":   c.analysis.ConfigID.String(),
+			"name": c.analysis.ConfigName,
+			"type": c.analysis.ConfigType,
+		},
+	}
+}
+
+func (c configAnalysisResource) summary() string {
+	return fmt.Sprintf("%s has a %s %s analysis", c.analysis.ConfigName, c.analysis.Severity, c.analysis.Analyzer)
+}
+
+func (c configAnalysisResource) evidence() api.Evidence {
+	return api.Evidence{
+		ConfigID:         &c.analysis.ConfigID,
+		ConfigAnalysisID: &c.analysis.ID,
+		DefinitionOfDone: true,
+		Type:             "config_analysis",
+		Description:      c.summary(),
+	}
+}
+
+func (c configAnalysisResource) labels() map[string]string { return nil }
+
+func (c configAnalysisResource) namespace() string { return "" }
+
+// parent of a config analysis is its config item
+func (c configAnalysisResource) parent() string { return c.analysis.ConfigID.String() }
+
+// target of a config analysis is the tags of its config item
+func (c configAnalysisResource) target() silence.Target {
+	return silence.Target{Labels: c.analysis.ConfigTags}
+}
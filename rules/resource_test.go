@@ -0,0 +1,90 @@
+package rules
+
+import (
+	"testing"
+
+	dutyModels "github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/types"
+	"github.com/google/uuid"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+)
+
+func TestCheckResourceMismatch(t *testing.T) {
+	canaryID := uuid.New()
+	check := db.FailingCheck{
+		Check: dutyModels.Check{
+			ID:         uuid.New(),
+			CanaryID:   canaryID,
+			CanaryName: "http-checks",
+			Name:       "payments-api",
+			Labels:     types.JSONStringMap{"env": "prod"},
+		},
+		ConsecutiveFailures: 3,
+	}
+
+	tests := []struct {
+		name     string
+		selector api.CheckSelector
+		want     bool
+	}{
+		{name: "any check", selector: api.CheckSelector{}, want: true},
+		{name: "canary name", selector: api.CheckSelector{Canary: "http-checks"}, want: true},
+		{name: "canary id", selector: api.CheckSelector{Canary: canaryID.String()}, want: true},
+		{name: "other canary", selector: api.CheckSelector{Canary: "dns-checks"}, want: false},
+		{name: "name", selector: api.CheckSelector{Name: "payments-api"}, want: true},
+		{name: "other name", selector: api.CheckSelector{Name: "orders-api"}, want: false},
+		{name: "labels", selector: api.CheckSelector{Labels: map[string]string{"env": "prod"}}, want: true},
+		{name: "other labels", selector: api.CheckSelector{Labels: map[string]string{"env": "staging"}}, want: false},
+		{name: "enough failures", selector: api.CheckSelector{ConsecutiveFailures: 3}, want: true},
+		{name: "not enough failures", selector: api.CheckSelector{ConsecutiveFailures: 4}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &api.IncidentRuleSpec{Checks: []api.CheckSelector{tt.selector}}
+			if got := (checkResource{check: check}).mismatch(rule) == ""; got != tt.want {
+				t.Errorf("mismatch() matched = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if reason := (checkResource{check: check}).mismatch(&api.IncidentRuleSpec{}); reason == "" {
+		t.Errorf("mismatch() of a rule without check selectors matched")
+	}
+}
+
+func TestConfigAnalysisResourceMismatch(t *testing.T) {
+	analysis := db.OpenConfigAnalysis{
+		ConfigAnalysis: dutyModels.ConfigAnalysis{
+			ID:         uuid.New(),
+			ConfigID:   uuid.New(),
+			Analyzer:   "ec2-public-ip",
+			Severity:   "critical",
+			ConfigType: "AWS::EC2::Instance",
+		},
+		ConfigName: "payments",
+	}
+
+	tests := []struct {
+		name     string
+		selector api.ConfigAnalysisSelector
+		want     bool
+	}{
+		{name: "any analysis", selector: api.ConfigAnalysisSelector{}, want: true},
+		{name: "analyzer", selector: api.ConfigAnalysisSelector{Analyzer: api.Items{"ec2-public-ip"}}, want: true},
+		{name: "other analyzer", selector: api.ConfigAnalysisSelector{Analyzer: api.Items{"s3-public-bucket"}}, want: false},
+		{name: "severity", selector: api.ConfigAnalysisSelector{Severity: api.Items{"high", "critical"}}, want: true},
+		{name: "other severity", selector: api.ConfigAnalysisSelector{Severity: api.Items{"low"}}, want: false},
+		{name: "config type", selector: api.ConfigAnalysisSelector{ConfigType: api.Items{"AWS::EC2::Instance"}}, want: true},
+		{name: "other config type", selector: api.ConfigAnalysisSelector{ConfigType: api.Items{"AWS::S3::Bucket"}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &api.IncidentRuleSpec{ConfigAnalysis: []api.ConfigAnalysisSelector{tt.selector}}
+			if got := (configAnalysisResource{analysis: analysis}).mismatch(rule) == ""; got != tt.want {
+				t.Errorf("mismatch() matched = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
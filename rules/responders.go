@@ -0,0 +1,90 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/flanksource/commons/logger"
+	dutyModels "github.com/flanksource/duty/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/flanksource/incident-commander/api"
+	pkgResponder "github.com/flanksource/incident-commander/responder"
+)
+
+// defaultResponderProperties are the properties of the responders
+// that are taken from the incident when the rule doesn't set them.
+var defaultResponderProperties = map[string]map[string]func(api.Incident) string{
+	"jira": {
+		"summary":     func(i api.Incident) string { return i.Title },
+		"description": func(i api.Incident) string { return i.Description },
+	},
+	"servicenow": {
+		"summary":     func(i api.Incident) string { return i.Title },
+		"description": func(i api.Incident) string { return i.Description },
+	},
+	"github": {
+		"title": func(i api.Incident) string { return i.Title },
+		"body":  func(i api.Incident) string { return i.Description },
+	},
+}
+
+// addRuleResponders adds the responders of the rule to the incident.
+// Each new responder is picked up by the incident.responder.added event.
+func addRuleResponders(tx *gorm.DB, incident api.Incident, responders api.IncidentResponders) error {
+	ruleResponders := responders.All()
+	if len(ruleResponders) == 0 {
+		return nil
+	}
+
+	var team *api.Team
+	if responders.Team != "" {
+		var t api.Team
+		query := tx.Where("name = ?", responders.Team)
+		if id, err := uuid.Parse(responders.Team); err == nil {
+			query = tx.Where("id = ?", id)
+		}
+		if err := query.Where("deleted_at IS NULL").First(&t).Error; err != nil {
+			return fmt.Errorf("error fetching team %s: %w", responders.Team, err)
+		}
+		team = &t
+	}
+
+	for _, ruleResponder := range ruleResponders {
+		responderType := ruleResponder.Properties["responderType"]
+		if pkgResponder.HasClient(responderType) && team == nil {
+			logger.Warnf("Skipping %s responder of incident %s since the rule's responders have no team", ruleResponder.Type, incident.ID)
+			continue
+		}
+
+		for property, value := range defaultResponderProperties[responderType] {
+			if ruleResponder.Properties[property] == "" {
+				ruleResponder.Properties[property] = value(incident)
+			}
+		}
+
+		properties, err := json.Marshal(ruleResponder.Properties)
+		if err != nil {
+			return err
+		}
+		p := string(properties)
+
+		responder := dutyModels.Responder{
+			ID:         uuid.New(),
+			IncidentID: *incident.ID,
+			Type:       ruleResponder.Type,
+			Properties: &p,
+			CreatedBy:  *api.SystemUserID,
+		}
+		if team != nil {
+			responder.TeamID = &team.ID
+		}
+
+		if err := tx.Create(&responder).Error; err != nil {
+			return fmt.Errorf("error adding %s responder: %w", ruleResponder.Type, err)
+		}
+	}
+
+	return nil
+}
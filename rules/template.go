@@ -0,0 +1,71 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/flanksource/commons/template"
+
+	"github.com/flanksource/incident-commander/api"
+	pkgNotification "github.com/flanksource/incident-commander/notification"
+)
+
+// defaultSeverity is the severity of incidents whose template has none
+const defaultSeverity = "Low"
+
+// asMap converts the resource to the map it's exposed as to templates and expressions.
+func asMap(resource any) map[string]any {
+	m := make(map[string]any)
+	b, _ := json.Marshal(resource)
+	_ = json.Unmarshal(b, &m)
+	return m
+}
+
+// renderIncident generates the incident of the rule's template for the matched resource.
+// The fields are templated with the same delimiters as notifications and then
+// overridden by the template's CEL expressions.
+func renderIncident(ctx *api.Context, ruleID string, incidentTemplate api.IncidentTemplate, env map[string]any) (api.Incident, error) {
+	templater := template.StructTemplater{
+		Values:         env,
+		ValueFunctions: true,
+		RequiredTag:    "template",
+		DelimSets: []template.Delims{
+			{Left: "{{", Right: "}}"},
+			{Left: "$(", Right: ")"},
+		},
+	}
+	if err := templater.Walk(&incidentTemplate); err != nil {
+		return api.Incident{}, fmt.Errorf("error templating incident: %w", err)
+	}
+
+	expressionRunner := pkgNotification.ExpressionRunner{
+		ResourceID:   ruleID,
+		ResourceType: "incident_rule",
+		CelEnv:       env,
+	}
+	for _, field := range []struct {
+		expression string
+		value      *string
+	}{
+		{incidentTemplate.Expressions.Title, &incidentTemplate.Title},
+		{incidentTemplate.Expressions.Description, &incidentTemplate.Description},
+		{incidentTemplate.Expressions.Severity, &incidentTemplate.Severity},
+		{incidentTemplate.Expressions.Type, (*string)(&incidentTemplate.Type)},
+	} {
+		if field.expression == "" {
+			continue
+		}
+
+		value, err := expressionRunner.EvalString(ctx, field.expression)
+		if err != nil {
+			return api.Incident{}, fmt.Errorf("error evaluating %q: %w", field.expression, err)
+		}
+		*field.value = value
+	}
+
+	incident := incidentTemplate.GenerateIncident()
+	if incident.Severity == "" {
+		incident.Severity = defaultSeverity
+	}
+	return incident, nil
+}
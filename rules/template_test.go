@@ -0,0 +1,66 @@
+package rules
+
+import (
+	"testing"
+
+	dutyModels "github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/types"
+
+	"github.com/flanksource/incident-commander/api"
+)
+
+func TestRenderIncident(t *testing.T) {
+	component := dutyModels.Component{
+		Name:      "payments",
+		Namespace: "prod",
+		Status:    types.ComponentStatusUnhealthy,
+		Labels:    types.JSONStringMap{"tier": "1"},
+	}
+	env := map[string]any{"component": asMap(component)}
+
+	tests := []struct {
+		name     string
+		template api.IncidentTemplate
+		want     api.Incident
+	}{
+		{
+			name:     "defaults",
+			template: api.IncidentTemplate{Title: "static"},
+			want:     api.Incident{Title: "static", Severity: defaultSeverity},
+		},
+		{
+			name: "templated fields",
+			template: api.IncidentTemplate{
+				Title:       "{{ .component.name }} is {{ .component.status }}",
+				Description: "$(.component.namespace)/$(.component.name)",
+				Severity:    "High",
+			},
+			want: api.Incident{Title: "payments is unhealthy", Description: "prod/payments", Severity: "High"},
+		},
+		{
+			name: "expressions take precedence",
+			template: api.IncidentTemplate{
+				Title:    "{{ .component.name }}",
+				Severity: "Low",
+				Expressions: api.IncidentTemplateExpressions{
+					Title:    `component.name + " in " + component.namespace`,
+					Severity: `component.labels.tier == "1" ? "Critical" : "Low"`,
+				},
+			},
+			want: api.Incident{Title: "payments in prod", Severity: "Critical"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderIncident(api.NewContext(nil, nil), "rule", tt.template, env)
+			if err != nil {
+				t.Fatalf("renderIncident() error = %v", err)
+			}
+			if got.Title != tt.want.Title || got.Description != tt.want.Description || got.Severity != tt.want.Severity {
+				t.Errorf("renderIncident() = {%q %q %q}, want {%q %q %q}",
+					got.Title, got.Description, got.Severity, tt.want.Title, tt.want.Description, tt.want.Severity)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package rules
+
+import (
+	"fmt"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/duty/models"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+)
+
+// The results of evaluating a rule against a resource
+const (
+	ResultCreated      = "created"
+	ResultWouldCreate  = "would_create"
+	ResultDeduplicated = "deduplicated"
+	ResultGrouped      = "grouped"
+	ResultWouldGroup   = "would_group"
+	ResultSilenced     = "silenced"
+	ResultNotMatched   = "not_matched"
+	ResultInactive     = "inactive"
+	ResultInvalid      = "invalid"
+	ResultError        = "error"
+)
+
+// Evaluation is the outcome of evaluating a rule against a resource.
+type Evaluation struct {
+	RuleID       string `json:"rule_id"`
+	Rule         string `json:"rule"`
+	Priority     int    `json:"priority"`
+	ResourceID   string `json:"resource_id"`
+	Resource     string `json:"resource"`
+	Result       string `json:"result"`
+	Matched      bool   `json:"matched"`
+	Reason       string `json:"reason,omitempty"`
+	IncidentID   string `json:"incident_id,omitempty"`
+	Title        string `json:"title,omitempty"`
+	Severity     string `json:"severity,omitempty"`
+	BreakOnMatch bool   `json:"break_on_match,omitempty"`
+	// Incident is the incident that would be created on a dry run
+	Incident *api.Incident `json:"incident,omitempty"`
+}
+
+// Trace is the evaluations of a run, in the order they were made.
+type Trace []Evaluation
+
+// ForRule returns the evaluations of the rule.
+func (t Trace) ForRule(ruleID string) Trace {
+	var evaluations Trace
+	for _, e := range t {
+		if e.RuleID == ruleID {
+			evaluations = append(evaluations, e)
+		}
+	}
+	return evaluations
+}
+
+// persistTrace records a job history per rule with the evaluations of the rule.
+// Evaluations that didn't match are only counted since every rule is evaluated
+// against every resource, and nothing's recorded for the rules that matched nothing.
+func persistTrace(ctx *api.Context, trace Trace) {
+	for _, rule := range Rules {
+		evaluations := trace.ForRule(rule.ID.String())
+		if len(evaluations) == 0 {
+			continue
+		}
+
+		jobHistory := models.NewJobHistory("IncidentRuleEvaluation", "incident_rule", rule.ID.String()).Start()
+		results := make(map[string]int)
+		var matched Trace
+		for _, e := range evaluations {
+			results[e.Result]++
+			switch e.Result {
+			case ResultNotMatched, ResultInactive:
+				continue
+			case ResultInvalid, ResultError:
+				jobHistory.AddError(fmt.Sprintf("%s: %s", e.Resource, e.Reason))
+			default:
+				jobHistory.IncrSuccess()
+			}
+			matched = append(matched, e)
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		jobHistory.Details = map[string]any{"results": results, "evaluations": matched}
+
+		if err := db.PersistJobHistory(ctx, jobHistory.End()); err != nil {
+			logger.Errorf("error persisting evaluation trace of rule %s: %v", rule.Name, err)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package silence
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/flanksource/incident-commander/api"
+	"github.com/flanksource/incident-commander/db"
+)
+
+// The actions suppressed by silences
+const (
+	ActionIncidentRule = "incident_rule"
+	ActionNotification = "notification"
+)
+
+// Ref is a resource targeted by its name or id.
+type Ref struct {
+	ID   string
+	Name string
+}
+
+func (r Ref) matches(names []string) bool {
+	for _, name := range names {
+		if name != "" && (name == r.ID || name == r.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Target is what an action is for, e.g. the component matched by a rule or
+// the team of a notification.
+type Target struct {
+	Components []Ref
+	Checks     []Ref
+	Teams      []Ref
+	Labels     map[string]string
+}
+
+// Active returns the silences that are active at the given time.
+func Active(ctx context.Context, now time.Time) ([]api.Silence, error) {
+	var silences []api.Silence
+	if err := db.Gorm.WithContext(ctx).Where("deleted_at IS NULL").Find(&silences).Error; err != nil {
+		return nil, err
+	}
+
+	var active []api.Silence
+	for _, silence := range silences {
+		if ok, err := IsActive(silence, now); err != nil {
+			logger.Errorf("invalid silence %s: %v", silence.Name, err)
+		} else if ok {
+			active = append(active, silence)
+		}
+	}
+	return active, nil
+}
+
+// IsActive returns whether the window of the silence covers the given time.
+// A recurring window is active for its duration after each start of its schedule.
+func IsActive(silence api.Silence, now time.Time) (bool, error) {
+	if silence.From != nil && now.Before(*silence.From) {
+		return false, nil
+	}
+	if silence.Until != nil && !now.Before(*silence.Until) {
+		return false, nil
+	}
+	if silence.Schedule == "" {
+		return true, nil
+	}
+
+	schedule, err := cron.ParseStandard(silence.Schedule)
+	if err != nil {
+		return false, fmt.Errorf("invalid schedule %q: %w", silence.Schedule, err)
+	}
+
+	duration, err := time.ParseDuration(silence.Duration)
+	if err != nil {
+		return false, fmt.Errorf("invalid duration %q: %w", silence.Duration, err)
+	} else if duration <= 0 {
+		return false, fmt.Errorf("duration of a recurring window must be positive")
+	}
+
+	location := time.UTC
+	if silence.Timezone != "" {
+		if location, err = time.LoadLocation(silence.Timezone); err != nil {
+			return false, err
+		}
+	}
+
+	// The first start of a window that hasn't ended yet
+	start := schedule.Next(now.In(location).Add(-duration))
+	return !start.After(now), nil
+}
+
+// Matches returns whether the silence targets any of the resources of the target.
+// A silence without targets matches nothing.
+func Matches(silence api.Silence, target Target) bool {
+	for _, ref := range target.Components {
+		if ref.matches(silence.Components) {
+			return true
+		}
+	}
+	for _, ref := range target.Checks {
+		if ref.matches(silence.Checks) {
+			return true
+		}
+	}
+	for _, ref := range target.Teams {
+		if ref.matches(silence.Teams) {
+			return true
+		}
+	}
+
+	if len(silence.Labels) == 0 {
+		return false
+	}
+	for k, v := range silence.Labels {
+		if target.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Find returns the first of the silences that targets the target.
+func Find(silences []api.Silence, target Target) *api.Silence {
+	for i := range silences {
+		if Matches(silences[i], target) {
+			return &silences[i]
+		}
+	}
+	return nil
+}
+
+// Record records the suppressed action for audit. Repeated suppressions are counted.
+// It's recorded in a nested transaction so that a failure doesn't abort the caller's transaction.
+func Record(tx *gorm.DB, suppression api.SilenceSuppression) error {
+	now := time.Now()
+	suppression.Count = 1
+	suppression.FirstAt, suppression.LastAt = now, now
+	return tx.Transaction(func(savepoint *gorm.DB) error {
+		return savepoint.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "silence_id"}, {Name: "action"}, {Name: "source_id"}, {Name: "resource_id"}, {Name: "recipient"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"count":       gorm.Expr("silence_suppressions.count + 1"),
+				"last_at":     now,
+				"description": suppression.Description,
+			}),
+		}).Create(&suppression).Error
+	})
+}
+
+// ForIncident returns the components and checks of the incident's evidences as a target.
+func ForIncident(ctx context.Context, incidentID string) (Target, error) {
+	var refs []struct {
+		Kind string
+		ID   string
+		Name string
+	}
+	err := db.Gorm.WithContext(ctx).Raw(`
+        SELECT 'component' AS kind, components.id::text AS id, components.name FROM evidences
+        INNER JOIN hypotheses ON hypotheses.id = evidences.hypothesis_id
+        INNER JOIN components ON components.id = evidences.component_id
+        WHERE hypotheses.incident_id = ?
+        UNION
+        SELECT 'check' AS kind, checks.id::text AS id, checks.name FROM evidences
+        INNER JOIN hypotheses ON hypotheses.id = evidences.hypothesis_id
+        INNER JOIN checks ON checks.id = evidences.check_id
+        WHERE hypotheses.incident_id = ?`, incidentID, incidentID).Scan(&refs).Error
+	if err != nil {
+		return Target{}, err
+	}
+
+	var target Target
+	for _, ref := range refs {
+		if ref.Kind == "component" {
+			target.Components = append(target.Components, Ref{ID: ref.ID, Name: ref.Name})
+		} else {
+			target.Checks = append(target.Checks, Ref{ID: ref.ID, Name: ref.Name})
+		}
+	}
+	return target, nil
+}
@@ -0,0 +1,81 @@
+package silence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flanksource/duty/types"
+
+	"github.com/flanksource/incident-commander/api"
+)
+
+func TestIsActive(t *testing.T) {
+	// A Saturday
+	now := time.Date(2023, 9, 9, 23, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name    string
+		silence api.Silence
+		want    bool
+		wantErr bool
+	}{
+		{name: "unbounded", silence: api.Silence{}, want: true},
+		{name: "within the range", silence: api.Silence{From: at(-time.Hour), Until: at(time.Hour)}, want: true},
+		{name: "before the range", silence: api.Silence{From: at(time.Hour)}, want: false},
+		{name: "after the range", silence: api.Silence{Until: at(-time.Hour)}, want: false},
+		{name: "until is exclusive", silence: api.Silence{Until: at(0)}, want: false},
+		{name: "recurring window started", silence: api.Silence{Schedule: "0 22 * * SAT", Duration: "4h"}, want: true},
+		{name: "recurring window ended", silence: api.Silence{Schedule: "0 22 * * SAT", Duration: "30m"}, want: false},
+		{name: "recurring window not started", silence: api.Silence{Schedule: "0 22 * * SUN", Duration: "4h"}, want: false},
+		{name: "recurring window in a timezone", silence: api.Silence{Schedule: "0 0 * * SUN", Duration: "2h", Timezone: "Europe/Berlin"}, want: true},
+		{name: "recurring window outside of the range", silence: api.Silence{Schedule: "0 22 * * SAT", Duration: "4h", Until: at(-time.Hour)}, want: false},
+		{name: "invalid schedule", silence: api.Silence{Schedule: "every saturday", Duration: "4h"}, wantErr: true},
+		{name: "missing duration", silence: api.Silence{Schedule: "0 22 * * SAT"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := IsActive(tt.silence, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IsActive() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IsActive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	target := Target{
+		Components: []Ref{{ID: "018a4c8e-0000-0000-0000-000000000001", Name: "payments"}},
+		Teams:      []Ref{{ID: "018a4c8e-0000-0000-0000-000000000002", Name: "sre"}},
+		Labels:     map[string]string{"env": "prod", "team": "billing"},
+	}
+
+	tests := []struct {
+		name    string
+		silence api.Silence
+		want    bool
+	}{
+		{name: "no targets", silence: api.Silence{}, want: false},
+		{name: "component name", silence: api.Silence{Components: []string{"payments"}}, want: true},
+		{name: "component id", silence: api.Silence{Components: []string{"018a4c8e-0000-0000-0000-000000000001"}}, want: true},
+		{name: "other component", silence: api.Silence{Components: []string{"orders"}}, want: false},
+		{name: "check of the same name", silence: api.Silence{Checks: []string{"payments"}}, want: false},
+		{name: "team", silence: api.Silence{Teams: []string{"sre"}}, want: true},
+		{name: "labels", silence: api.Silence{Labels: types.JSONStringMap{"env": "prod"}}, want: true},
+		{name: "some of the labels", silence: api.Silence{Labels: types.JSONStringMap{"env": "prod", "team": "search"}}, want: false},
+		{name: "any of the targets", silence: api.Silence{Components: []string{"orders"}, Teams: []string{"sre"}}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Matches(tt.silence, target); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
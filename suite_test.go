@@ -34,6 +34,10 @@ var _ = ginkgo.BeforeSuite(func() {
 	if db.Gorm, db.Pool, err = duty.SetupDB(connection, nil); err != nil {
 		ginkgo.Fail(err.Error())
 	}
+
+	if err := db.RunMigrations(db.Gorm); err != nil {
+		ginkgo.Fail(err.Error())
+	}
 })
 
 var _ = ginkgo.AfterSuite(func() {